@@ -16,6 +16,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+
 	"github.com/fluxcd/pkg/apis/meta"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,21 +29,154 @@ const (
 	// HealthyCondition indicates that the KustomizationSet has created all its
 	// resources.
 	HealthyCondition string = "Healthy"
+
+	// GenerationFailedReason indicates that the generators failed to generate
+	// the parameters for the templates.
+	GenerationFailedReason string = "GenerationFailed"
+
+	// RenderFailedReason indicates that the templates could not be rendered
+	// with the generated parameters.
+	RenderFailedReason string = "RenderFailed"
+
+	// DuplicateResourcesReason indicates that more than one set of generated
+	// parameters rendered the same resource.
+	DuplicateResourcesReason string = "DuplicateResources"
+
+	// ApplyFailedReason indicates that the generated resources could not be
+	// applied to the cluster.
+	ApplyFailedReason string = "ApplyFailed"
+
+	// ApplyConflictReason indicates that the generated resources could not be
+	// applied because fields are owned by another field manager.
+	ApplyConflictReason string = "ApplyConflict"
+
+	// AdoptionRefusedReason indicates that a generated resource already
+	// exists and can't be adopted by the KustomizationSet.
+	AdoptionRefusedReason string = "AdoptionRefused"
+
+	// SourceNotReadyReason indicates that a source used by a generator, e.g.
+	// a GitRepository, is not ready.
+	SourceNotReadyReason string = "SourceNotReady"
+
+	// DryRunReason indicates that the changes to the generated resources were
+	// computed and recorded in the status without being made.
+	DryRunReason string = "DryRun"
+
+	// KustomizationsFailedReason indicates that more of the generated
+	// Kustomizations have failed than the KustomizationSet allows.
+	KustomizationsFailedReason string = "KustomizationsFailed"
+
+	// RolloutBlockedReason indicates that a step of the rolling sync can't
+	// complete because some of its up to date Kustomizations have failed.
+	RolloutBlockedReason string = "RolloutBlocked"
+
+	// DeletionsBlockedCondition indicates that resources that are no longer
+	// generated are not being deleted, until the deletions are approved or
+	// the generated resources change.
+	DeletionsBlockedCondition string = "DeletionsBlocked"
+
+	// MaxDeletionsExceededReason indicates that more resources would be
+	// deleted than the MaxDeletions of the SyncPolicy allows.
+	MaxDeletionsExceededReason string = "MaxDeletionsExceeded"
 )
 
 // KustomizationSetReady registers a successful apply attempt of the given Kustomization.
 func KustomizationSetReady(k KustomizationSet, inventory *ResourceInventory, reason, message string) KustomizationSet {
 	setKustomizationSetReadiness(&k, metav1.ConditionTrue, reason, message)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.ReconcilingCondition)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.StalledCondition)
+	k.Status.Inventory = inventory
+	k.Status.ObservedGeneration = k.Generation
+	return k
+}
+
+// KustomizationSetWaiting registers a successful apply attempt of the given
+// KustomizationSet where the generated Kustomizations are not yet Ready.
+func KustomizationSetWaiting(k KustomizationSet, inventory *ResourceInventory, message string) KustomizationSet {
+	setKustomizationSetReadiness(&k, metav1.ConditionUnknown, meta.ProgressingReason, message)
+	setKustomizationSetCondition(&k, meta.ReconcilingCondition, metav1.ConditionTrue, meta.ProgressingReason, message)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.StalledCondition)
+	k.Status.Inventory = inventory
+	k.Status.ObservedGeneration = k.Generation
+	return k
+}
+
+// KustomizationSetUnhealthy registers a successful apply attempt of the
+// given KustomizationSet where too many of the generated Kustomizations have
+// failed.
+func KustomizationSetUnhealthy(k KustomizationSet, inventory *ResourceInventory, reason, message string) KustomizationSet {
+	setKustomizationSetReadiness(&k, metav1.ConditionFalse, reason, message)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.ReconcilingCondition)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.StalledCondition)
 	k.Status.Inventory = inventory
+	k.Status.ObservedGeneration = k.Generation
+	return k
+}
+
+// KustomizationSetNotReady registers a failed attempt to reconcile the given
+// KustomizationSet, the controller will retry.
+func KustomizationSetNotReady(k KustomizationSet, reason, message string) KustomizationSet {
+	setKustomizationSetReadiness(&k, metav1.ConditionFalse, reason, message)
+	setKustomizationSetCondition(&k, meta.ReconcilingCondition, metav1.ConditionTrue, meta.ProgressingWithRetryReason, message)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.StalledCondition)
+	k.Status.ObservedGeneration = k.Generation
+	return k
+}
+
+// KustomizationSetStalled registers a failed attempt to reconcile the given
+// KustomizationSet that will not succeed until the KustomizationSet is
+// changed.
+func KustomizationSetStalled(k KustomizationSet, reason, message string) KustomizationSet {
+	setKustomizationSetReadiness(&k, metav1.ConditionFalse, reason, message)
+	setKustomizationSetCondition(&k, meta.StalledCondition, metav1.ConditionTrue, reason, message)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.ReconcilingCondition)
+	k.Status.ObservedGeneration = k.Generation
+	return k
+}
+
+// KustomizationSetProgressing registers that the controller has started
+// reconciling a new generation of the given KustomizationSet.
+func KustomizationSetProgressing(k KustomizationSet) KustomizationSet {
+	message := fmt.Sprintf("reconciling generation %d", k.Generation)
+	setKustomizationSetCondition(&k, meta.ReconcilingCondition, metav1.ConditionTrue, meta.ProgressingReason, message)
+	setKustomizationSetReadiness(&k, metav1.ConditionUnknown, meta.ProgressingReason, message)
+	return k
+}
+
+// KustomizationSetDryRun registers the changes that reconciling the given
+// KustomizationSet would make, the status of the generated resources is
+// cleared because the KustomizationSet is no longer applying them.
+func KustomizationSetDryRun(k KustomizationSet, message string) KustomizationSet {
+	setKustomizationSetReadiness(&k, metav1.ConditionUnknown, DryRunReason, message)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.ReconcilingCondition)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, meta.StalledCondition)
+	apimeta.RemoveStatusCondition(&k.Status.Conditions, DeletionsBlockedCondition)
+	k.Status.Kustomizations = nil
+	k.Status.Summary = nil
+	k.Status.RollingSync = nil
+	k.Status.PendingDeletions = nil
+	k.Status.ObservedGeneration = k.Generation
+	return k
+}
+
+// KustomizationSetDeletionsBlocked registers that resources that are no
+// longer generated are not being deleted.
+func KustomizationSetDeletionsBlocked(k KustomizationSet, reason, message string) KustomizationSet {
+	setKustomizationSetCondition(&k, DeletionsBlockedCondition, metav1.ConditionTrue, reason, message)
 	return k
 }
 
 func setKustomizationSetReadiness(k *KustomizationSet, status metav1.ConditionStatus, reason, message string) {
+	setKustomizationSetCondition(k, meta.ReadyCondition, status, reason, message)
+}
+
+func setKustomizationSetCondition(k *KustomizationSet, condType string, status metav1.ConditionStatus, reason, message string) {
 	newCondition := metav1.Condition{
-		Type:    meta.ReadyCondition,
-		Status:  status,
-		Reason:  reason,
-		Message: limitMessage(message),
+		Type:               condType,
+		Status:             status,
+		ObservedGeneration: k.Generation,
+		Reason:             reason,
+		Message:            limitMessage(message),
 	}
 	apimeta.SetStatusCondition(&k.Status.Conditions, newCondition)
 }
@@ -69,4 +204,13 @@ type ResourceRef struct {
 
 	// Version is the API version of the Kubernetes resource object's kind.
 	Version string `json:"v"`
+
+	// Policy is the policy of the generator that generated the resource.
+	// +optional
+	Policy string `json:"policy,omitempty"`
+
+	// Generator identifies the generator that generated the resource, by its
+	// index in the generators and the kind of generator, e.g. 1/PullRequest.
+	// +optional
+	Generator string `json:"generator,omitempty"`
 }
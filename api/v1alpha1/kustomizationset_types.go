@@ -18,9 +18,118 @@ package v1alpha1
 
 import (
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/pkg/apis/meta"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// The labels, annotations and finalizer use the API group of the
+// KustomizationSet as their prefix.
+const (
+	// SetNameLabel is the label applied to generated Kustomizations to record
+	// the name of the KustomizationSet that generated them.
+	SetNameLabel = "source.gitops.solutions/name"
+
+	// SetNamespaceLabel is the label applied to generated Kustomizations to
+	// record the namespace of the KustomizationSet that generated them.
+	SetNamespaceLabel = "source.gitops.solutions/namespace"
+
+	// KustomizationSetFinalizer is used to ensure that generated
+	// Kustomizations are cleaned up when a KustomizationSet is deleted.
+	KustomizationSetFinalizer = "source.gitops.solutions/finalizer"
+
+	// DeletionPolicyDelete deletes the generated resources when the
+	// KustomizationSet is deleted.
+	DeletionPolicyDelete = "Delete"
+
+	// DeletionPolicyOrphan leaves the generated resources in place when the
+	// KustomizationSet is deleted.
+	DeletionPolicyOrphan = "Orphan"
+
+	// GeneratorPolicyCreateOnly only creates the resources generated from a
+	// generator, existing resources are not updated or deleted.
+	GeneratorPolicyCreateOnly = "create-only"
+
+	// GeneratorPolicyCreateUpdate creates and updates the resources generated
+	// from a generator, resources that are no longer generated are not
+	// deleted.
+	GeneratorPolicyCreateUpdate = "create-update"
+
+	// GeneratorPolicySync creates, updates and deletes the resources
+	// generated from a generator.
+	GeneratorPolicySync = "sync"
+
+	// DuplicatePolicyFail fails to reconcile when more than one set of
+	// parameters generates the same resource.
+	DuplicatePolicyFail = "Fail"
+
+	// DuplicatePolicyFirstWins keeps the first generated resource when more
+	// than one set of parameters generates the same resource.
+	DuplicatePolicyFirstWins = "FirstWins"
+
+	// DuplicatePolicyLastWins keeps the last generated resource when more
+	// than one set of parameters generates the same resource.
+	DuplicatePolicyLastWins = "LastWins"
+
+	// AdoptAnnotation marks an existing resource as one that can be adopted
+	// by a KustomizationSet with the IfAnnotated adoption policy, the value
+	// is the <namespace>/<name> of the KustomizationSet.
+	AdoptAnnotation = "source.gitops.solutions/adopt"
+
+	// ApproveDeletionsAnnotation approves the deletions that were blocked by
+	// the MaxDeletions of the SyncPolicy, the value is the digest of the
+	// blocked deletions that is recorded in the DeletionsBlocked condition.
+	ApproveDeletionsAnnotation = "source.gitops.solutions/approve-deletions"
+
+	// AdoptionPolicyRefuse fails to reconcile when a generated resource
+	// already exists.
+	AdoptionPolicyRefuse = "Refuse"
+
+	// AdoptionPolicyIfAnnotated adopts existing resources that have the
+	// AdoptAnnotation.
+	AdoptionPolicyIfAnnotated = "IfAnnotated"
+
+	// AdoptionPolicyAlways adopts all existing resources.
+	AdoptionPolicyAlways = "Always"
+
+	// KustomizationReadyStatus is the status of a generated Kustomization
+	// that has a Ready condition of True.
+	KustomizationReadyStatus = "Ready"
+
+	// KustomizationProgressingStatus is the status of a generated
+	// Kustomization that has not yet reported the result of reconciling its
+	// current generation.
+	KustomizationProgressingStatus = "Progressing"
+
+	// KustomizationFailedStatus is the status of a generated Kustomization
+	// that has a Ready condition of False.
+	KustomizationFailedStatus = "Failed"
+
+	// RollingSyncStepPending is the status of a step that is waiting for
+	// earlier steps to complete.
+	RollingSyncStepPending = "Pending"
+
+	// RollingSyncStepProgressing is the status of the step that is being
+	// rolled out.
+	RollingSyncStepProgressing = "Progressing"
+
+	// RollingSyncStepBlocked is the status of a step where all the selected
+	// resources are up to date, but some have failed to become Ready.
+	RollingSyncStepBlocked = "Blocked"
+
+	// RollingSyncStepComplete is the status of a step where all the selected
+	// resources are up to date and Ready.
+	RollingSyncStepComplete = "Complete"
+
+	// KustomizationVersionV1 generates kustomize.toolkit.fluxcd.io/v1
+	// Kustomizations.
+	KustomizationVersionV1 = "v1"
+
+	// KustomizationVersionV1Beta2 generates kustomize.toolkit.fluxcd.io/v1beta2
+	// Kustomizations.
+	KustomizationVersionV1Beta2 = "v1beta2"
 )
 
 // KustomizationSetTemplateMeta represents the metadata  fields that may
@@ -40,6 +149,22 @@ type KustomizationSetTemplate struct {
 	Spec                         kustomizev1.KustomizationSpec `json:"spec"`
 }
 
+// KustomizationSetResourceTemplate is a named template for a resource to be
+// generated for each set of generated parameters.
+type KustomizationSetResourceTemplate struct {
+	// Name identifies the template within the KustomizationSet.
+	Name string `json:"name"`
+
+	// Condition is a Go template that is rendered with the generated
+	// parameters, the resource is only generated if it renders "true".
+	// e.g. '{{ eq .env "production" }}'
+	// +optional
+	Condition string `json:"condition,omitempty"`
+
+	// Resource is the template for the resource to be generated.
+	Resource apiextensionsv1.JSON `json:"resource"`
+}
+
 // ListGenerator generates from a hard-coded list.
 type ListGenerator struct {
 	Elements []apiextensionsv1.JSON `json:"elements"`
@@ -105,26 +230,402 @@ type KustomizationSetGenerator struct {
 	List          *ListGenerator          `json:"list,omitempty"`
 	PullRequest   *PullRequestGenerator   `json:"pullRequest,omitempty"`
 	GitRepository *GitRepositoryGenerator `json:"gitRepository,omitempty"`
+
+	// Policy determines which changes are made to the resources generated
+	// from this generator.
+	// create-only only creates resources, create-update creates and updates
+	// resources, and sync also deletes resources that are no longer
+	// generated.
+	// +kubebuilder:validation:Enum=create-only;create-update;sync
+	// +kubebuilder:default=sync
+	// +optional
+	Policy string `json:"policy,omitempty"`
 }
 
 // KustomizationSetSpec defines the desired state of KustomizationSet
 type KustomizationSetSpec struct {
 	Generators []KustomizationSetGenerator `json:"generators"`
-	Template   KustomizationSetTemplate    `json:"template"`
+
+	// Suspend tells the controller to suspend reconciliation of this
+	// KustomizationSet, no resources are created, updated or deleted while it
+	// is suspended.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DryRun tells the controller to compute the changes to the generated
+	// resources without making them, the changes are recorded in the status.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Template is the template used to generate a Kustomization for each set
+	// of generated parameters.
+	// Exactly one of Template, ResourceTemplate or Templates must be provided.
+	// +optional
+	Template *KustomizationSetTemplate `json:"template,omitempty"`
+
+	// ResourceTemplate is the template for an arbitrary Kubernetes resource to
+	// be generated for each set of generated parameters, e.g. a HelmRelease or
+	// GitRepository.
+	// Generator templates are only merged with the Template, they are not
+	// applied to the ResourceTemplate.
+	// Exactly one of Template, ResourceTemplate or Templates must be provided.
+	// +optional
+	ResourceTemplate *apiextensionsv1.JSON `json:"resourceTemplate,omitempty"`
+
+	// Templates is a list of named templates, each of which is rendered for
+	// each set of generated parameters, in the order they are listed.
+	// Generator templates are not applied to these templates.
+	// Exactly one of Template, ResourceTemplate or Templates must be provided.
+	// +optional
+	Templates []KustomizationSetResourceTemplate `json:"templates,omitempty"`
+
+	// KustomizationVersion is the version of the Kustomization API used for
+	// Kustomizations generated from the Template.
+	// If this is not provided, the controller uses v1 if the cluster serves
+	// it, and v1beta2 otherwise.
+	// +kubebuilder:validation:Enum=v1;v1beta2
+	// +optional
+	KustomizationVersion string `json:"kustomizationVersion,omitempty"`
+
+	// DeletionPolicy determines what happens to the generated resources when
+	// the KustomizationSet is deleted.
+	// Delete removes the generated resources, and waits for them to be
+	// removed before the KustomizationSet is removed, Orphan removes the owner
+	// references and KustomizationSet labels from the generated resources and
+	// leaves them in place.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// DuplicatePolicy determines what happens when more than one set of
+	// generated parameters renders the same resource.
+	// Fail fails to reconcile the KustomizationSet, FirstWins and LastWins
+	// keep the first or last resource that was rendered.
+	// +kubebuilder:validation:Enum=Fail;FirstWins;LastWins
+	// +kubebuilder:default=Fail
+	// +optional
+	DuplicatePolicy string `json:"duplicatePolicy,omitempty"`
+
+	// AdoptionPolicy determines what happens when a generated resource
+	// already exists and was not generated by this KustomizationSet.
+	// Refuse fails to reconcile the KustomizationSet, IfAnnotated adopts
+	// resources that have the source.gitops.solutions/adopt annotation with the
+	// <namespace>/<name> of this KustomizationSet, and Always adopts the
+	// resources.
+	// Resources generated by other KustomizationSets are never adopted.
+	// +kubebuilder:validation:Enum=Refuse;IfAnnotated;Always
+	// +kubebuilder:default=Refuse
+	// +optional
+	AdoptionPolicy string `json:"adoptionPolicy,omitempty"`
+
+	// AllowedNamespaces is the set of namespaces that generated
+	// Kustomizations can be created in.
+	// Kustomizations can always be created in the namespace of the
+	// KustomizationSet, templates that render other namespaces must be listed
+	// here.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+
+	// HealthCheck configures how the health of the generated Kustomizations
+	// is reflected in the Ready condition of the KustomizationSet.
+	// If this is not provided, all generated Kustomizations must be Ready.
+	// +optional
+	HealthCheck *KustomizationSetHealthCheck `json:"healthCheck,omitempty"`
+
+	// SyncPolicy configures safeguards for deleting resources that are no
+	// longer generated.
+	// +optional
+	SyncPolicy *KustomizationSetSyncPolicy `json:"syncPolicy,omitempty"`
+
+	// Strategy configures how changes to the generated resources are rolled
+	// out, by default all resources are updated at once.
+	// +optional
+	Strategy *KustomizationSetStrategy `json:"strategy,omitempty"`
+}
+
+// KustomizationSetSyncPolicy configures safeguards for deleting resources
+// that are no longer generated.
+//
+// Resources that are not deleted are kept in the inventory and recorded in
+// the pending deletions in the status.
+type KustomizationSetSyncPolicy struct {
+	// PreserveOnEmpty keeps the resources previously generated by a
+	// generator when it generates no resources, e.g. because of an error in
+	// an external API.
+	// Resources from generators that are removed from the KustomizationSet
+	// are not kept.
+	// +optional
+	PreserveOnEmpty bool `json:"preserveOnEmpty,omitempty"`
+
+	// MaxDeletions is the number (e.g. 2) or percentage (e.g. 10%) of the
+	// resources in the inventory that can be deleted at once, if more
+	// resources would be deleted none of them are deleted until the
+	// deletions are approved with the approve-deletions annotation, or the
+	// generated resources change.
+	// Percentages are of the resources that could be deleted, not counting
+	// resources retained by the generator policy or kept by PreserveOnEmpty
+	// or the DeletionGracePeriod, and are rounded up.
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Pattern="^[0-9]+%?$"
+	// +optional
+	MaxDeletions *intstr.IntOrString `json:"maxDeletions,omitempty"`
+
+	// DeletionGracePeriod is how long a resource must no longer be generated
+	// before it is deleted.
+	// This is a duration rather than a number of reconciles, the
+	// KustomizationSet is only reconciled when it or its sources change, so
+	// a number of reconciles might never be reached.
+	// +optional
+	DeletionGracePeriod *metav1.Duration `json:"deletionGracePeriod,omitempty"`
+}
+
+// KustomizationSetStrategy configures how changes to the generated resources
+// are rolled out.
+type KustomizationSetStrategy struct {
+	// RollingSync updates the generated resources in ordered steps, each step
+	// is only started when the generated Kustomizations selected by the
+	// previous steps are Ready.
+	// +optional
+	RollingSync *RollingSyncStrategy `json:"rollingSync,omitempty"`
+}
+
+// RollingSyncStrategy updates the generated resources in ordered steps.
+//
+// Only updates to existing resources are rolled out, new resources are
+// created and removed resources are deleted immediately.
+type RollingSyncStrategy struct {
+	// Steps are the ordered steps of the rollout, generated resources are
+	// updated in the first step that selects them, and resources that are not
+	// selected by any step are updated after the last step.
+	// +kubebuilder:validation:MinItems=1
+	Steps []RollingSyncStep `json:"steps"`
+}
+
+// RollingSyncStep selects the generated resources that are updated in a step
+// of a rollout.
+type RollingSyncStep struct {
+	// MatchExpressions selects generated resources by their labels, an empty
+	// list selects all resources.
+	// +optional
+	MatchExpressions []metav1.LabelSelectorRequirement `json:"matchExpressions,omitempty"`
+
+	// MaxUpdate is the number (e.g. 2) or percentage (e.g. 25%) of the
+	// resources selected by this step that can be updating at the same time.
+	// Percentages are rounded up, the default is 100%.
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Pattern="^[0-9]+%?$"
+	// +optional
+	MaxUpdate *intstr.IntOrString `json:"maxUpdate,omitempty"`
+}
+
+// KustomizationSetHealthCheck configures the thresholds used to determine
+// whether the KustomizationSet is Ready.
+type KustomizationSetHealthCheck struct {
+	// MinReady is the number (e.g. 2) or percentage (e.g. 80%) of generated
+	// Kustomizations that must be Ready for the KustomizationSet to be Ready.
+	// Percentages are rounded up, the default is 100%.
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Pattern="^[0-9]+%?$"
+	// +optional
+	MinReady *intstr.IntOrString `json:"minReady,omitempty"`
+
+	// MaxFailed is the number (e.g. 1) or percentage (e.g. 10%) of generated
+	// Kustomizations that can fail before the KustomizationSet is marked as
+	// failed.
+	// Percentages are rounded down, the default is 0.
+	// +kubebuilder:validation:XIntOrString
+	// +kubebuilder:validation:Pattern="^[0-9]+%?$"
+	// +optional
+	MaxFailed *intstr.IntOrString `json:"maxFailed,omitempty"`
 }
 
 // KustomizationSetStatus defines the observed state of KustomizationSet
 type KustomizationSetStatus struct {
+	meta.ReconcileRequestStatus `json:",inline"`
+
+	// ObservedGeneration is the last observed generation of the
+	// KustomizationSet.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 
 	// Inventory contains the list of Kubernetes resource object references that have been successfully applied.
 	// +optional
 	Inventory *ResourceInventory `json:"inventory,omitempty"`
+
+	// Kustomizations is the status of each generated Kustomization.
+	// +optional
+	Kustomizations []GeneratedKustomizationStatus `json:"kustomizations,omitempty"`
+
+	// Summary counts the generated Kustomizations by their status.
+	// +optional
+	Summary *KustomizationSetSummary `json:"summary,omitempty"`
+
+	// RollingSync is the progress of the rollout when the RollingSync
+	// strategy is used.
+	// +optional
+	RollingSync *RollingSyncStatus `json:"rollingSync,omitempty"`
+
+	// PendingDeletions are the resources that are no longer generated, and
+	// have not been deleted because of the SyncPolicy.
+	// +optional
+	PendingDeletions []PendingDeletion `json:"pendingDeletions,omitempty"`
+
+	// DryRun is the summary of the changes that would be made to the
+	// generated resources, this is only set when DryRun is enabled.
+	// +optional
+	DryRun *DryRunSummary `json:"dryRun,omitempty"`
+}
+
+// DryRunSummary is the summary of the changes that would be made to the
+// generated resources.
+type DryRunSummary struct {
+	// Create are the resources that would be created.
+	// +optional
+	Create []ResourceRef `json:"create,omitempty"`
+
+	// Adopt are the resources that already exist and would be adopted by the
+	// KustomizationSet, with the changes that would be made to them.
+	// +optional
+	Adopt []DryRunUpdate `json:"adopt,omitempty"`
+
+	// Refuse are the resources that already exist and the adoption policy
+	// doesn't allow the KustomizationSet to adopt, reconciling would fail.
+	// +optional
+	Refuse []DryRunRefusal `json:"refuse,omitempty"`
+
+	// Update are the existing resources that would be updated.
+	// +optional
+	Update []DryRunUpdate `json:"update,omitempty"`
+
+	// Delete are the resources that would be deleted because they are no
+	// longer generated.
+	// +optional
+	Delete []ResourceRef `json:"delete,omitempty"`
+}
+
+// DryRunUpdate is an existing resource that would be updated.
+type DryRunUpdate struct {
+	ResourceRef `json:",inline"`
+
+	// Changes are the fields that would be changed.
+	// +optional
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// DryRunRefusal is an existing resource that would not be adopted.
+type DryRunRefusal struct {
+	ResourceRef `json:",inline"`
+
+	// Reason is why the resource would not be adopted.
+	Reason string `json:"reason"`
+}
+
+// FieldChange is a change to a field of a resource.
+type FieldChange struct {
+	// Path is the path to the field, e.g. spec.path.
+	Path string `json:"path"`
+
+	// Old is the JSON encoded existing value of the field, this is empty if
+	// the field would be added.
+	// +optional
+	Old string `json:"old,omitempty"`
+
+	// New is the JSON encoded generated value of the field, this is empty if
+	// the field would be removed.
+	// +optional
+	New string `json:"new,omitempty"`
+}
+
+// PendingDeletion is a resource that is no longer generated and is waiting
+// to be deleted.
+type PendingDeletion struct {
+	ResourceRef `json:",inline"`
+
+	// Since is when the resource was first found to no longer be generated.
+	Since metav1.Time `json:"since"`
+}
+
+// RollingSyncStatus is the progress of a rollout.
+type RollingSyncStatus struct {
+	// CurrentStep is the step that is being rolled out, numbered from 1, this
+	// is not set when the rollout is complete.
+	// +optional
+	CurrentStep int `json:"currentStep,omitempty"`
+
+	// Steps is the progress of each step of the rollout, including the
+	// implicit final step for resources that are not selected by any step.
+	// +optional
+	Steps []RollingSyncStepStatus `json:"steps,omitempty"`
+}
+
+// RollingSyncStepStatus is the progress of a step of a rollout.
+type RollingSyncStepStatus struct {
+	// Step is the number of the step, numbered from 1.
+	Step int `json:"step"`
+
+	// Status is one of Pending, Progressing, Blocked or Complete.
+	// +kubebuilder:validation:Enum=Pending;Progressing;Blocked;Complete
+	Status string `json:"status"`
+
+	// Total is the number of generated resources selected by the step.
+	Total int `json:"total"`
+
+	// Updating is the number of resources that have been updated and are
+	// not yet Ready.
+	Updating int `json:"updating"`
+
+	// OutOfDate is the number of resources waiting to be updated.
+	OutOfDate int `json:"outOfDate"`
+
+	// Failed is the number of up to date resources that have failed to
+	// become Ready, these block the later steps but don't count towards
+	// maxUpdate.
+	// +optional
+	Failed int `json:"failed,omitempty"`
+}
+
+// GeneratedKustomizationStatus is the observed status of a generated
+// Kustomization.
+type GeneratedKustomizationStatus struct {
+	// Name of the generated Kustomization.
+	Name string `json:"name"`
+
+	// Namespace of the generated Kustomization.
+	Namespace string `json:"namespace"`
+
+	// Status is one of Ready, Progressing or Failed.
+	// +kubebuilder:validation:Enum=Ready;Progressing;Failed
+	Status string `json:"status"`
+
+	// Message is the message from the Ready condition of the Kustomization.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastAppliedRevision is the revision last applied by the Kustomization.
+	// +optional
+	LastAppliedRevision string `json:"lastAppliedRevision,omitempty"`
+}
+
+// KustomizationSetSummary counts the generated Kustomizations by their
+// status.
+type KustomizationSetSummary struct {
+	Ready       int `json:"ready"`
+	Progressing int `json:"progressing"`
+	Failed      int `json:"failed"`
+}
+
+// Total returns the number of generated Kustomizations.
+func (s KustomizationSetSummary) Total() int {
+	return s.Ready + s.Progressing + s.Failed
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:storageversion
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description=""
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description=""
 // +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].message",description=""
@@ -26,8 +26,120 @@ import (
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunRefusal) DeepCopyInto(out *DryRunRefusal) {
+	*out = *in
+	out.ResourceRef = in.ResourceRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunRefusal.
+func (in *DryRunRefusal) DeepCopy() *DryRunRefusal {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunRefusal)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunSummary) DeepCopyInto(out *DryRunSummary) {
+	*out = *in
+	if in.Create != nil {
+		in, out := &in.Create, &out.Create
+		*out = make([]ResourceRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.Adopt != nil {
+		in, out := &in.Adopt, &out.Adopt
+		*out = make([]DryRunUpdate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Refuse != nil {
+		in, out := &in.Refuse, &out.Refuse
+		*out = make([]DryRunRefusal, len(*in))
+		copy(*out, *in)
+	}
+	if in.Update != nil {
+		in, out := &in.Update, &out.Update
+		*out = make([]DryRunUpdate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Delete != nil {
+		in, out := &in.Delete, &out.Delete
+		*out = make([]ResourceRef, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunSummary.
+func (in *DryRunSummary) DeepCopy() *DryRunSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DryRunUpdate) DeepCopyInto(out *DryRunUpdate) {
+	*out = *in
+	out.ResourceRef = in.ResourceRef
+	if in.Changes != nil {
+		in, out := &in.Changes, &out.Changes
+		*out = make([]FieldChange, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DryRunUpdate.
+func (in *DryRunUpdate) DeepCopy() *DryRunUpdate {
+	if in == nil {
+		return nil
+	}
+	out := new(DryRunUpdate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FieldChange) DeepCopyInto(out *FieldChange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FieldChange.
+func (in *FieldChange) DeepCopy() *FieldChange {
+	if in == nil {
+		return nil
+	}
+	out := new(FieldChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GeneratedKustomizationStatus) DeepCopyInto(out *GeneratedKustomizationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeneratedKustomizationStatus.
+func (in *GeneratedKustomizationStatus) DeepCopy() *GeneratedKustomizationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GeneratedKustomizationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GitRepositoryGenerator) DeepCopyInto(out *GitRepositoryGenerator) {
 	*out = *in
@@ -125,6 +237,31 @@ func (in *KustomizationSetGenerator) DeepCopy() *KustomizationSetGenerator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetHealthCheck) DeepCopyInto(out *KustomizationSetHealthCheck) {
+	*out = *in
+	if in.MinReady != nil {
+		in, out := &in.MinReady, &out.MinReady
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxFailed != nil {
+		in, out := &in.MaxFailed, &out.MaxFailed
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetHealthCheck.
+func (in *KustomizationSetHealthCheck) DeepCopy() *KustomizationSetHealthCheck {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetHealthCheck)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KustomizationSetList) DeepCopyInto(out *KustomizationSetList) {
 	*out = *in
@@ -157,6 +294,22 @@ func (in *KustomizationSetList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetResourceTemplate) DeepCopyInto(out *KustomizationSetResourceTemplate) {
+	*out = *in
+	in.Resource.DeepCopyInto(&out.Resource)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetResourceTemplate.
+func (in *KustomizationSetResourceTemplate) DeepCopy() *KustomizationSetResourceTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetResourceTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KustomizationSetSpec) DeepCopyInto(out *KustomizationSetSpec) {
 	*out = *in
@@ -167,7 +320,43 @@ func (in *KustomizationSetSpec) DeepCopyInto(out *KustomizationSetSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	in.Template.DeepCopyInto(&out.Template)
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(KustomizationSetTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResourceTemplate != nil {
+		in, out := &in.ResourceTemplate, &out.ResourceTemplate
+		*out = new(v1.JSON)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make([]KustomizationSetResourceTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(KustomizationSetHealthCheck)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SyncPolicy != nil {
+		in, out := &in.SyncPolicy, &out.SyncPolicy
+		*out = new(KustomizationSetSyncPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Strategy != nil {
+		in, out := &in.Strategy, &out.Strategy
+		*out = new(KustomizationSetStrategy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetSpec.
@@ -183,6 +372,7 @@ func (in *KustomizationSetSpec) DeepCopy() *KustomizationSetSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KustomizationSetStatus) DeepCopyInto(out *KustomizationSetStatus) {
 	*out = *in
+	out.ReconcileRequestStatus = in.ReconcileRequestStatus
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
 		*out = make([]metav1.Condition, len(*in))
@@ -195,6 +385,33 @@ func (in *KustomizationSetStatus) DeepCopyInto(out *KustomizationSetStatus) {
 		*out = new(ResourceInventory)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Kustomizations != nil {
+		in, out := &in.Kustomizations, &out.Kustomizations
+		*out = make([]GeneratedKustomizationStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(KustomizationSetSummary)
+		**out = **in
+	}
+	if in.RollingSync != nil {
+		in, out := &in.RollingSync, &out.RollingSync
+		*out = new(RollingSyncStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingDeletions != nil {
+		in, out := &in.PendingDeletions, &out.PendingDeletions
+		*out = make([]PendingDeletion, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DryRun != nil {
+		in, out := &in.DryRun, &out.DryRun
+		*out = new(DryRunSummary)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetStatus.
@@ -207,6 +424,66 @@ func (in *KustomizationSetStatus) DeepCopy() *KustomizationSetStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetStrategy) DeepCopyInto(out *KustomizationSetStrategy) {
+	*out = *in
+	if in.RollingSync != nil {
+		in, out := &in.RollingSync, &out.RollingSync
+		*out = new(RollingSyncStrategy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetStrategy.
+func (in *KustomizationSetStrategy) DeepCopy() *KustomizationSetStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetSummary) DeepCopyInto(out *KustomizationSetSummary) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetSummary.
+func (in *KustomizationSetSummary) DeepCopy() *KustomizationSetSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KustomizationSetSyncPolicy) DeepCopyInto(out *KustomizationSetSyncPolicy) {
+	*out = *in
+	if in.MaxDeletions != nil {
+		in, out := &in.MaxDeletions, &out.MaxDeletions
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.DeletionGracePeriod != nil {
+		in, out := &in.DeletionGracePeriod, &out.DeletionGracePeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationSetSyncPolicy.
+func (in *KustomizationSetSyncPolicy) DeepCopy() *KustomizationSetSyncPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(KustomizationSetSyncPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KustomizationSetTemplate) DeepCopyInto(out *KustomizationSetTemplate) {
 	*out = *in
@@ -285,6 +562,23 @@ func (in *ListGenerator) DeepCopy() *ListGenerator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingDeletion) DeepCopyInto(out *PendingDeletion) {
+	*out = *in
+	out.ResourceRef = in.ResourceRef
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingDeletion.
+func (in *PendingDeletion) DeepCopy() *PendingDeletion {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingDeletion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PullRequestGenerator) DeepCopyInto(out *PullRequestGenerator) {
 	*out = *in
@@ -350,3 +644,87 @@ func (in *ResourceRef) DeepCopy() *ResourceRef {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingSyncStatus) DeepCopyInto(out *RollingSyncStatus) {
+	*out = *in
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]RollingSyncStepStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingSyncStatus.
+func (in *RollingSyncStatus) DeepCopy() *RollingSyncStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingSyncStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingSyncStep) DeepCopyInto(out *RollingSyncStep) {
+	*out = *in
+	if in.MatchExpressions != nil {
+		in, out := &in.MatchExpressions, &out.MatchExpressions
+		*out = make([]metav1.LabelSelectorRequirement, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MaxUpdate != nil {
+		in, out := &in.MaxUpdate, &out.MaxUpdate
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingSyncStep.
+func (in *RollingSyncStep) DeepCopy() *RollingSyncStep {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingSyncStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingSyncStepStatus) DeepCopyInto(out *RollingSyncStepStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingSyncStepStatus.
+func (in *RollingSyncStepStatus) DeepCopy() *RollingSyncStepStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingSyncStepStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollingSyncStrategy) DeepCopyInto(out *RollingSyncStrategy) {
+	*out = *in
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]RollingSyncStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollingSyncStrategy.
+func (in *RollingSyncStrategy) DeepCopy() *RollingSyncStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(RollingSyncStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,383 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// emptySyncPolicyAnnotation records that a v1alpha1 KustomizationSet has an
+// empty syncPolicy alongside a deletionPolicy or adoptionPolicy, v1alpha2
+// merges these into a single syncPolicy, so the empty syncPolicy can't be
+// told apart from a missing syncPolicy.
+const emptySyncPolicyAnnotation = "source.gitops.solutions/v1alpha1-empty-sync-policy"
+
+// ConvertTo converts this KustomizationSet to the hub version (v1alpha1).
+func (src *KustomizationSet) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*v1alpha1.KustomizationSet)
+	if !ok {
+		return fmt.Errorf("unsupported conversion to %T", dstRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = convertSpecToHub(src.Spec)
+	dst.Status = convertStatusToHub(src.Status)
+
+	if _, ok := src.GetAnnotations()[emptySyncPolicyAnnotation]; ok {
+		annotations := map[string]string{}
+		for k, v := range src.GetAnnotations() {
+			if k != emptySyncPolicyAnnotation {
+				annotations[k] = v
+			}
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		dst.SetAnnotations(annotations)
+		if dst.Spec.SyncPolicy == nil {
+			dst.Spec.SyncPolicy = &v1alpha1.KustomizationSetSyncPolicy{}
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1alpha1) to this version.
+func (dst *KustomizationSet) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*v1alpha1.KustomizationSet)
+	if !ok {
+		return fmt.Errorf("unsupported conversion from %T", srcRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = convertSpecFromHub(src.Spec)
+	dst.Status = convertStatusFromHub(src.Status)
+
+	if isEmptySyncPolicy(src.Spec.SyncPolicy) && (src.Spec.DeletionPolicy != "" || src.Spec.AdoptionPolicy != "") {
+		annotations := map[string]string{emptySyncPolicyAnnotation: "true"}
+		for k, v := range src.GetAnnotations() {
+			annotations[k] = v
+		}
+		dst.SetAnnotations(annotations)
+	}
+
+	return nil
+}
+
+func convertSpecToHub(src KustomizationSetSpec) v1alpha1.KustomizationSetSpec {
+	dst := v1alpha1.KustomizationSetSpec{
+		Generators:       convertSlice(src.Generators, convertGeneratorToHub),
+		Suspend:          src.Suspend,
+		DryRun:           src.DryRun,
+		Template:         convertTemplateToHub(src.Template),
+		ResourceTemplate: src.ResourceTemplate,
+		Templates: convertSlice(src.Templates, func(t KustomizationSetResourceTemplate) v1alpha1.KustomizationSetResourceTemplate {
+			return v1alpha1.KustomizationSetResourceTemplate(t)
+		}),
+		HealthCheck: (*v1alpha1.KustomizationSetHealthCheck)(src.HealthCheck),
+	}
+	if opts := src.TemplateOptions; opts != nil {
+		dst.KustomizationVersion = opts.KustomizationVersion
+		dst.DuplicatePolicy = opts.DuplicatePolicy
+		dst.AllowedNamespaces = opts.AllowedNamespaces
+	}
+	if policy := src.SyncPolicy; policy != nil {
+		dst.DeletionPolicy = policy.DeletionPolicy
+		dst.AdoptionPolicy = policy.AdoptionPolicy
+		// The v1alpha1 SyncPolicy only has the deletion safeguards, an empty
+		// SyncPolicy is kept as an empty v1alpha1 SyncPolicy.
+		if policy.PreserveOnEmpty || policy.MaxDeletions != nil || policy.DeletionGracePeriod != nil || *policy == (SyncPolicy{}) {
+			dst.SyncPolicy = &v1alpha1.KustomizationSetSyncPolicy{
+				PreserveOnEmpty:     policy.PreserveOnEmpty,
+				MaxDeletions:        policy.MaxDeletions,
+				DeletionGracePeriod: policy.DeletionGracePeriod,
+			}
+		}
+	}
+	if strategy := src.Strategy; strategy != nil {
+		dst.Strategy = &v1alpha1.KustomizationSetStrategy{}
+		if rs := strategy.RollingSync; rs != nil {
+			dst.Strategy.RollingSync = &v1alpha1.RollingSyncStrategy{
+				Steps: convertSlice(rs.Steps, func(s RollingSyncStep) v1alpha1.RollingSyncStep {
+					return v1alpha1.RollingSyncStep(s)
+				}),
+			}
+		}
+	}
+
+	return dst
+}
+
+func convertSpecFromHub(src v1alpha1.KustomizationSetSpec) KustomizationSetSpec {
+	dst := KustomizationSetSpec{
+		Generators:       convertSlice(src.Generators, convertGeneratorFromHub),
+		Suspend:          src.Suspend,
+		DryRun:           src.DryRun,
+		Template:         convertTemplateFromHub(src.Template),
+		ResourceTemplate: src.ResourceTemplate,
+		Templates: convertSlice(src.Templates, func(t v1alpha1.KustomizationSetResourceTemplate) KustomizationSetResourceTemplate {
+			return KustomizationSetResourceTemplate(t)
+		}),
+		HealthCheck: (*KustomizationSetHealthCheck)(src.HealthCheck),
+	}
+	if src.KustomizationVersion != "" || src.DuplicatePolicy != "" || src.AllowedNamespaces != nil {
+		dst.TemplateOptions = &TemplateOptions{
+			KustomizationVersion: src.KustomizationVersion,
+			DuplicatePolicy:      src.DuplicatePolicy,
+			AllowedNamespaces:    src.AllowedNamespaces,
+		}
+	}
+	if src.DeletionPolicy != "" || src.AdoptionPolicy != "" || src.SyncPolicy != nil {
+		dst.SyncPolicy = &SyncPolicy{
+			DeletionPolicy: src.DeletionPolicy,
+			AdoptionPolicy: src.AdoptionPolicy,
+		}
+		if policy := src.SyncPolicy; policy != nil {
+			dst.SyncPolicy.PreserveOnEmpty = policy.PreserveOnEmpty
+			dst.SyncPolicy.MaxDeletions = policy.MaxDeletions
+			dst.SyncPolicy.DeletionGracePeriod = policy.DeletionGracePeriod
+		}
+	}
+	if strategy := src.Strategy; strategy != nil {
+		dst.Strategy = &KustomizationSetStrategy{}
+		if rs := strategy.RollingSync; rs != nil {
+			dst.Strategy.RollingSync = &RollingSyncStrategy{
+				Steps: convertSlice(rs.Steps, func(s v1alpha1.RollingSyncStep) RollingSyncStep {
+					return RollingSyncStep(s)
+				}),
+			}
+		}
+	}
+
+	return dst
+}
+
+// isEmptySyncPolicy returns true if the v1alpha1 SyncPolicy is provided
+// without any deletion safeguards.
+func isEmptySyncPolicy(policy *v1alpha1.KustomizationSetSyncPolicy) bool {
+	return policy != nil && !policy.PreserveOnEmpty && policy.MaxDeletions == nil && policy.DeletionGracePeriod == nil
+}
+
+func convertGeneratorToHub(src KustomizationSetGenerator) v1alpha1.KustomizationSetGenerator {
+	dst := v1alpha1.KustomizationSetGenerator{Policy: src.Policy}
+	if list := src.List; list != nil {
+		dst.List = &v1alpha1.ListGenerator{
+			Elements: list.Elements,
+			Template: convertTemplateToHub(list.Template),
+		}
+	}
+	if pr := src.PullRequest; pr != nil {
+		dst.PullRequest = &v1alpha1.PullRequestGenerator{
+			Interval:  pr.Interval,
+			Template:  convertTemplateToHub(pr.Template),
+			Driver:    pr.Driver,
+			ServerURL: pr.ServerURL,
+			Repo:      pr.Repo,
+			SecretRef: pr.SecretRef,
+		}
+		if pr.Filters != nil {
+			dst.PullRequest.Labels = pr.Filters.Labels
+		}
+	}
+	if repo := src.GitRepository; repo != nil {
+		dst.GitRepository = &v1alpha1.GitRepositoryGenerator{
+			RepositoryRef: repo.SourceRef.Name,
+			Directories: convertSlice(repo.Directories, func(d GitRepositoryGeneratorDirectoryItem) v1alpha1.GitRepositoryGeneratorDirectoryItem {
+				return v1alpha1.GitRepositoryGeneratorDirectoryItem(d)
+			}),
+			Template: convertTemplateToHub(repo.Template),
+		}
+	}
+
+	return dst
+}
+
+func convertGeneratorFromHub(src v1alpha1.KustomizationSetGenerator) KustomizationSetGenerator {
+	dst := KustomizationSetGenerator{Policy: src.Policy}
+	if list := src.List; list != nil {
+		dst.List = &ListGenerator{
+			Elements: list.Elements,
+			Template: convertTemplateFromHub(list.Template),
+		}
+	}
+	if pr := src.PullRequest; pr != nil {
+		dst.PullRequest = &PullRequestGenerator{
+			Interval:  pr.Interval,
+			Driver:    pr.Driver,
+			ServerURL: pr.ServerURL,
+			Repo:      pr.Repo,
+			SecretRef: pr.SecretRef,
+			Template:  convertTemplateFromHub(pr.Template),
+		}
+		if pr.Labels != nil {
+			dst.PullRequest.Filters = &PullRequestFilters{Labels: pr.Labels}
+		}
+	}
+	if repo := src.GitRepository; repo != nil {
+		dst.GitRepository = &GitRepositoryGenerator{
+			SourceRef: GitRepositoryReference{Kind: GitRepositoryKind, Name: repo.RepositoryRef},
+			Directories: convertSlice(repo.Directories, func(d v1alpha1.GitRepositoryGeneratorDirectoryItem) GitRepositoryGeneratorDirectoryItem {
+				return GitRepositoryGeneratorDirectoryItem(d)
+			}),
+			Template: convertTemplateFromHub(repo.Template),
+		}
+	}
+
+	return dst
+}
+
+func convertTemplateToHub(src *KustomizationSetTemplate) *v1alpha1.KustomizationSetTemplate {
+	if src == nil {
+		return nil
+	}
+
+	return &v1alpha1.KustomizationSetTemplate{
+		KustomizationSetTemplateMeta: v1alpha1.KustomizationSetTemplateMeta(src.Metadata),
+		Spec:                         src.Spec,
+	}
+}
+
+func convertTemplateFromHub(src *v1alpha1.KustomizationSetTemplate) *KustomizationSetTemplate {
+	if src == nil {
+		return nil
+	}
+
+	return &KustomizationSetTemplate{
+		Metadata: KustomizationSetTemplateMeta(src.KustomizationSetTemplateMeta),
+		Spec:     src.Spec,
+	}
+}
+
+func convertStatusToHub(src KustomizationSetStatus) v1alpha1.KustomizationSetStatus {
+	dst := v1alpha1.KustomizationSetStatus{
+		ReconcileRequestStatus: src.ReconcileRequestStatus,
+		ObservedGeneration:     src.ObservedGeneration,
+		Conditions:             src.Conditions,
+		Kustomizations: convertSlice(src.Kustomizations, func(s GeneratedKustomizationStatus) v1alpha1.GeneratedKustomizationStatus {
+			return v1alpha1.GeneratedKustomizationStatus(s)
+		}),
+		Summary: (*v1alpha1.KustomizationSetSummary)(src.Summary),
+		PendingDeletions: convertSlice(src.PendingDeletions, func(p PendingDeletion) v1alpha1.PendingDeletion {
+			return v1alpha1.PendingDeletion{ResourceRef: v1alpha1.ResourceRef(p.ResourceRef), Since: p.Since}
+		}),
+	}
+	if inv := src.Inventory; inv != nil {
+		dst.Inventory = &v1alpha1.ResourceInventory{Entries: convertSlice(inv.Entries, convertResourceRefToHub)}
+	}
+	if rs := src.RollingSync; rs != nil {
+		dst.RollingSync = &v1alpha1.RollingSyncStatus{
+			CurrentStep: rs.CurrentStep,
+			Steps: convertSlice(rs.Steps, func(s RollingSyncStepStatus) v1alpha1.RollingSyncStepStatus {
+				return v1alpha1.RollingSyncStepStatus(s)
+			}),
+		}
+	}
+	if dr := src.DryRun; dr != nil {
+		dst.DryRun = &v1alpha1.DryRunSummary{
+			Create: convertSlice(dr.Create, convertResourceRefToHub),
+			Adopt:  convertSlice(dr.Adopt, convertDryRunUpdateToHub),
+			Refuse: convertSlice(dr.Refuse, func(r DryRunRefusal) v1alpha1.DryRunRefusal {
+				return v1alpha1.DryRunRefusal{ResourceRef: v1alpha1.ResourceRef(r.ResourceRef), Reason: r.Reason}
+			}),
+			Update: convertSlice(dr.Update, convertDryRunUpdateToHub),
+			Delete: convertSlice(dr.Delete, convertResourceRefToHub),
+		}
+	}
+
+	return dst
+}
+
+func convertStatusFromHub(src v1alpha1.KustomizationSetStatus) KustomizationSetStatus {
+	dst := KustomizationSetStatus{
+		ReconcileRequestStatus: src.ReconcileRequestStatus,
+		ObservedGeneration:     src.ObservedGeneration,
+		Conditions:             src.Conditions,
+		Kustomizations: convertSlice(src.Kustomizations, func(s v1alpha1.GeneratedKustomizationStatus) GeneratedKustomizationStatus {
+			return GeneratedKustomizationStatus(s)
+		}),
+		Summary: (*KustomizationSetSummary)(src.Summary),
+		PendingDeletions: convertSlice(src.PendingDeletions, func(p v1alpha1.PendingDeletion) PendingDeletion {
+			return PendingDeletion{ResourceRef: ResourceRef(p.ResourceRef), Since: p.Since}
+		}),
+	}
+	if inv := src.Inventory; inv != nil {
+		dst.Inventory = &ResourceInventory{Entries: convertSlice(inv.Entries, convertResourceRefFromHub)}
+	}
+	if rs := src.RollingSync; rs != nil {
+		dst.RollingSync = &RollingSyncStatus{
+			CurrentStep: rs.CurrentStep,
+			Steps: convertSlice(rs.Steps, func(s v1alpha1.RollingSyncStepStatus) RollingSyncStepStatus {
+				return RollingSyncStepStatus(s)
+			}),
+		}
+	}
+	if dr := src.DryRun; dr != nil {
+		dst.DryRun = &DryRunSummary{
+			Create: convertSlice(dr.Create, convertResourceRefFromHub),
+			Adopt:  convertSlice(dr.Adopt, convertDryRunUpdateFromHub),
+			Refuse: convertSlice(dr.Refuse, func(r v1alpha1.DryRunRefusal) DryRunRefusal {
+				return DryRunRefusal{ResourceRef: ResourceRef(r.ResourceRef), Reason: r.Reason}
+			}),
+			Update: convertSlice(dr.Update, convertDryRunUpdateFromHub),
+			Delete: convertSlice(dr.Delete, convertResourceRefFromHub),
+		}
+	}
+
+	return dst
+}
+
+func convertResourceRefToHub(src ResourceRef) v1alpha1.ResourceRef {
+	return v1alpha1.ResourceRef(src)
+}
+
+func convertResourceRefFromHub(src v1alpha1.ResourceRef) ResourceRef {
+	return ResourceRef(src)
+}
+
+func convertDryRunUpdateToHub(src DryRunUpdate) v1alpha1.DryRunUpdate {
+	return v1alpha1.DryRunUpdate{
+		ResourceRef: v1alpha1.ResourceRef(src.ResourceRef),
+		Changes: convertSlice(src.Changes, func(c FieldChange) v1alpha1.FieldChange {
+			return v1alpha1.FieldChange(c)
+		}),
+	}
+}
+
+func convertDryRunUpdateFromHub(src v1alpha1.DryRunUpdate) DryRunUpdate {
+	return DryRunUpdate{
+		ResourceRef: ResourceRef(src.ResourceRef),
+		Changes: convertSlice(src.Changes, func(c v1alpha1.FieldChange) FieldChange {
+			return FieldChange(c)
+		}),
+	}
+}
+
+// convertSlice converts each item in the slice, a nil slice is converted to
+// a nil slice so that empty and missing fields round-trip.
+func convertSlice[S, D any](src []S, convert func(S) D) []D {
+	if src == nil {
+		return nil
+	}
+	dst := make([]D, len(src))
+	for i := range src {
+		dst[i] = convert(src[i])
+	}
+
+	return dst
+}
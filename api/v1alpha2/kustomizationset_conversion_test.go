@@ -0,0 +1,253 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha2
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	fuzz "github.com/google/gofuzz"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+const fuzzIterations = 100
+
+// fuzzSeeds are the seeds that the round-trip tests always fuzz with, so that
+// a failure can be reproduced.
+var fuzzSeeds = []int64{1, 2, 3, 5, 8}
+
+var fuzzRandom = flag.Bool("fuzz-random", false, "also fuzz the round-trip tests with a random seed")
+
+var _ conversion.Convertible = (*KustomizationSet)(nil)
+
+func TestKustomizationSet_round_trip_from_spoke(t *testing.T) {
+	for _, seed := range testSeeds() {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			f := newFuzzer(seed)
+			for i := 0; i < fuzzIterations; i++ {
+				want := &KustomizationSet{}
+				f.Fuzz(want)
+
+				hub := &v1alpha1.KustomizationSet{}
+				if err := want.ConvertTo(hub); err != nil {
+					t.Fatal(err)
+				}
+				got := &KustomizationSet{}
+				if err := got.ConvertFrom(hub); err != nil {
+					t.Fatal(err)
+				}
+
+				if !apiequality.Semantic.DeepEqual(want, got) {
+					t.Fatalf("failed to round-trip v1alpha2 through v1alpha1:\n%s", cmp.Diff(want, got))
+				}
+			}
+		})
+	}
+}
+
+func TestKustomizationSet_round_trip_from_hub(t *testing.T) {
+	for _, seed := range testSeeds() {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			f := newFuzzer(seed)
+			for i := 0; i < fuzzIterations; i++ {
+				want := &v1alpha1.KustomizationSet{}
+				f.Fuzz(want)
+
+				spoke := &KustomizationSet{}
+				if err := spoke.ConvertFrom(want); err != nil {
+					t.Fatal(err)
+				}
+				got := &v1alpha1.KustomizationSet{}
+				if err := spoke.ConvertTo(got); err != nil {
+					t.Fatal(err)
+				}
+
+				if !apiequality.Semantic.DeepEqual(want, got) {
+					t.Fatalf("failed to round-trip v1alpha1 through v1alpha2:\n%s", cmp.Diff(want, got))
+				}
+			}
+		})
+	}
+}
+
+// The empty templateOptions and filters that only exist in v1alpha2 can't be
+// stored as v1alpha1, they are documented as equivalent to missing blocks.
+func TestKustomizationSet_round_trip_empty_blocks(t *testing.T) {
+	spoke := &KustomizationSet{
+		Spec: KustomizationSetSpec{
+			Generators: []KustomizationSetGenerator{
+				{PullRequest: &PullRequestGenerator{Filters: &PullRequestFilters{}}},
+			},
+			TemplateOptions: &TemplateOptions{},
+			SyncPolicy:      &SyncPolicy{},
+		},
+	}
+
+	hub := &v1alpha1.KustomizationSet{}
+	if err := spoke.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	got := &KustomizationSet{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+
+	want := KustomizationSetSpec{
+		Generators: []KustomizationSetGenerator{
+			{PullRequest: &PullRequestGenerator{}},
+		},
+		SyncPolicy: &SyncPolicy{},
+	}
+	if diff := cmp.Diff(want, got.Spec); diff != "" {
+		t.Fatalf("failed to round-trip empty blocks:\n%s", diff)
+	}
+}
+
+func TestKustomizationSet_round_trip_empty_sync_policy(t *testing.T) {
+	want := &v1alpha1.KustomizationSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-set", Namespace: "default"},
+		Spec: v1alpha1.KustomizationSetSpec{
+			DeletionPolicy: v1alpha1.DeletionPolicyOrphan,
+			SyncPolicy:     &v1alpha1.KustomizationSetSyncPolicy{},
+		},
+	}
+
+	spoke := &KustomizationSet{}
+	if err := spoke.ConvertFrom(want); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&SyncPolicy{DeletionPolicy: v1alpha1.DeletionPolicyOrphan}, spoke.Spec.SyncPolicy); diff != "" {
+		t.Fatalf("failed to convert the sync policy:\n%s", diff)
+	}
+	if want.GetAnnotations() != nil {
+		t.Fatalf("the hub annotations were modified: %v", want.GetAnnotations())
+	}
+	got := &v1alpha1.KustomizationSet{}
+	if err := spoke.ConvertTo(got); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to round-trip the empty sync policy:\n%s", diff)
+	}
+}
+
+func TestKustomizationSet_ConvertFrom(t *testing.T) {
+	hub := &v1alpha1.KustomizationSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-set", Namespace: "default"},
+		Spec: v1alpha1.KustomizationSetSpec{
+			Generators: []v1alpha1.KustomizationSetGenerator{
+				{
+					GitRepository: &v1alpha1.GitRepositoryGenerator{RepositoryRef: "demo-repo"},
+				},
+				{
+					PullRequest: &v1alpha1.PullRequestGenerator{
+						Interval: metav1.Duration{Duration: 5 * time.Minute},
+						Driver:   "github",
+						Repo:     "test-org/test-repo",
+						Labels:   []string{"deploy"},
+					},
+				},
+			},
+			DeletionPolicy:  v1alpha1.DeletionPolicyOrphan,
+			DuplicatePolicy: v1alpha1.DuplicatePolicyFirstWins,
+			SyncPolicy:      &v1alpha1.KustomizationSetSyncPolicy{PreserveOnEmpty: true},
+		},
+	}
+
+	got := &KustomizationSet{}
+	if err := got.ConvertFrom(hub); err != nil {
+		t.Fatal(err)
+	}
+
+	want := KustomizationSetSpec{
+		Generators: []KustomizationSetGenerator{
+			{
+				GitRepository: &GitRepositoryGenerator{
+					SourceRef: GitRepositoryReference{Kind: "GitRepository", Name: "demo-repo"},
+				},
+			},
+			{
+				PullRequest: &PullRequestGenerator{
+					Interval: metav1.Duration{Duration: 5 * time.Minute},
+					Driver:   "github",
+					Repo:     "test-org/test-repo",
+					Filters:  &PullRequestFilters{Labels: []string{"deploy"}},
+				},
+			},
+		},
+		TemplateOptions: &TemplateOptions{DuplicatePolicy: v1alpha1.DuplicatePolicyFirstWins},
+		SyncPolicy:      &SyncPolicy{DeletionPolicy: v1alpha1.DeletionPolicyOrphan, PreserveOnEmpty: true},
+	}
+	if diff := cmp.Diff(want, got.Spec); diff != "" {
+		t.Fatalf("failed to convert from v1alpha1:\n%s", diff)
+	}
+}
+
+// testSeeds returns the seeds to fuzz the round-trip tests with, the fixed
+// seeds and a random seed if -fuzz-random is set.
+func testSeeds() []int64 {
+	if *fuzzRandom {
+		return append(fuzzSeeds, time.Now().UnixNano())
+	}
+
+	return fuzzSeeds
+}
+
+// newFuzzer returns a fuzzer that only generates values that are meaningful
+// in both versions, and the GitRepository generator only references
+// GitRepositories.
+//
+// v1alpha1 KustomizationSets are not normalised, they must round-trip
+// losslessly. Empty v1alpha2 templateOptions and filters are documented as
+// equivalent to missing blocks because v1alpha1 has nowhere to store them, see
+// TestKustomizationSet_round_trip_empty_blocks.
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.NewWithSeed(seed).NilChance(0.3).NumElements(0, 3).Funcs(
+		func(ks *KustomizationSet, c fuzz.Continue) {
+			c.FuzzNoCustom(ks)
+			ks.TypeMeta = metav1.TypeMeta{}
+		},
+		func(ks *v1alpha1.KustomizationSet, c fuzz.Continue) {
+			c.FuzzNoCustom(ks)
+			ks.TypeMeta = metav1.TypeMeta{}
+		},
+		func(spec *KustomizationSetSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			if spec.TemplateOptions != nil && apiequality.Semantic.DeepEqual(*spec.TemplateOptions, TemplateOptions{}) {
+				spec.TemplateOptions = nil
+			}
+		},
+		func(pr *PullRequestGenerator, c fuzz.Continue) {
+			c.FuzzNoCustom(pr)
+			if pr.Filters != nil && pr.Filters.Labels == nil {
+				pr.Filters = nil
+			}
+		},
+		func(ref *GitRepositoryReference, c fuzz.Continue) {
+			c.FuzzNoCustom(ref)
+			ref.Kind = GitRepositoryKind
+		},
+	)
+}
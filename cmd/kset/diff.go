@@ -0,0 +1,187 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+// deletionsExitCode is the exit code when resources would be removed.
+const deletionsExitCode = 3
+
+// resourceDiff is the difference between the resources generated from two
+// revisions of a KustomizationSet, resources are identified by their kind,
+// group, namespace and name, see resourceKey.
+type resourceDiff struct {
+	added   []string
+	removed []string
+	changed []changedResource
+}
+
+// changedResource is a resource that is generated from both revisions with
+// different fields.
+type changedResource struct {
+	key  string
+	diff string
+}
+
+func runDiff(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	var oldFilename, newFilename string
+	var gens generatorFlags
+	fs.StringVar(&oldFilename, "old", "", "The file containing the old revision of the KustomizationSet.")
+	fs.StringVar(&newFilename, "new", "", "The file containing the new revision of the KustomizationSet.")
+	gens.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if oldFilename == "" || newFilename == "" {
+		return errors.New("both --old and --new KustomizationSet files must be provided")
+	}
+
+	oldResources, err := generateFromFile(oldFilename, &gens)
+	if err != nil {
+		return fmt.Errorf("failed to generate from %s: %w", oldFilename, err)
+	}
+	newResources, err := generateFromFile(newFilename, &gens)
+	if err != nil {
+		return fmt.Errorf("failed to generate from %s: %w", newFilename, err)
+	}
+
+	diff, err := diffResources(oldResources, newResources)
+	if err != nil {
+		return err
+	}
+	if err := writeDiff(stdout, diff); err != nil {
+		return err
+	}
+	if len(diff.removed) > 0 {
+		return &exitError{code: deletionsExitCode, message: fmt.Sprintf("%d resources would be removed", len(diff.removed))}
+	}
+
+	return nil
+}
+
+func diffResources(oldResources, newResources []*unstructured.Unstructured) (*resourceDiff, error) {
+	oldDocs, err := resourceDocuments(oldResources)
+	if err != nil {
+		return nil, err
+	}
+	newDocs, err := resourceDocuments(newResources)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &resourceDiff{}
+	for _, key := range sortedKeys(oldDocs) {
+		newDoc, ok := newDocs[key]
+		if !ok {
+			diff.removed = append(diff.removed, key)
+			continue
+		}
+		if newDoc == oldDocs[key] {
+			continue
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(oldDocs[key]),
+			B:        difflib.SplitLines(newDoc),
+			FromFile: "old/" + key,
+			ToFile:   "new/" + key,
+			Context:  3,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", key, err)
+		}
+		diff.changed = append(diff.changed, changedResource{key: key, diff: text})
+	}
+	for _, key := range sortedKeys(newDocs) {
+		if _, ok := oldDocs[key]; !ok {
+			diff.added = append(diff.added, key)
+		}
+	}
+
+	return diff, nil
+}
+
+func writeDiff(w io.Writer, diff *resourceDiff) error {
+	for _, key := range diff.removed {
+		if _, err := fmt.Fprintf(w, "- %s\n", key); err != nil {
+			return err
+		}
+	}
+	for _, key := range diff.added {
+		if _, err := fmt.Fprintf(w, "+ %s\n", key); err != nil {
+			return err
+		}
+	}
+	for _, changed := range diff.changed {
+		if _, err := fmt.Fprintf(w, "~ %s\n%s", changed.key, changed.diff); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed, %d changed\n", len(diff.added), len(diff.removed), len(diff.changed))
+
+	return err
+}
+
+// resourceDocuments returns the resources as YAML documents keyed by
+// resourceKey.
+func resourceDocuments(resources []*unstructured.Unstructured) (map[string]string, error) {
+	docs := map[string]string{}
+	for _, v := range resources {
+		b, err := yaml.Marshal(v.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", v.GetKind(), err)
+		}
+		docs[resourceKey(v)] = string(b)
+	}
+
+	return docs, nil
+}
+
+// resourceKey identifies a resource by its kind, group, namespace and name,
+// e.g. Kustomization.kustomize.toolkit.fluxcd.io/default/demo.
+//
+// The API version is not part of the key, like the inventory of a
+// KustomizationSet, so that changing the version of a generated resource is
+// reported as a change rather than a removal.
+func resourceKey(u *unstructured.Unstructured) string {
+	key := u.GroupVersionKind().GroupKind().String()
+	if ns := u.GetNamespace(); ns != "" {
+		key += "/" + ns
+	}
+
+	return key + "/" + u.GetName()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+const wantChangedDiff = `- Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-prod-demo
++ Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-preprod-demo
+~ Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-dev-demo
+--- old/Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-dev-demo
++++ new/Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-dev-demo
+@@ -5,7 +5,7 @@
+   namespace: default
+ spec:
+   interval: 5m0s
+-  path: ./clusters/engineering-dev/
++  path: ./clusters/dev/
+   prune: true
+   sourceRef:
+     kind: GitRepository
+1 added, 1 removed, 1 changed
+`
+
+func TestDiff_deletions(t *testing.T) {
+	var out bytes.Buffer
+	err := runDiff([]string{"--old", "testdata/list_set.yaml", "--new", "testdata/list_set_changed.yaml"}, &out)
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("got error %v, want an exitError", err)
+	}
+	if exitErr.code != deletionsExitCode {
+		t.Fatalf("got exit code %d, want %d", exitErr.code, deletionsExitCode)
+	}
+	if diff := cmp.Diff(wantChangedDiff, out.String()); diff != "" {
+		t.Fatalf("failed to diff:\n%s", diff)
+	}
+}
+
+func TestDiff_additions(t *testing.T) {
+	var out bytes.Buffer
+	err := runDiff([]string{"--old", "testdata/list_set.yaml", "--new", "testdata/list_set_added.yaml"}, &out)
+	test.AssertNoError(t, err)
+
+	want := "+ Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-preprod-demo\n1 added, 0 removed, 0 changed\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Fatalf("failed to diff:\n%s", diff)
+	}
+}
+
+func TestDiff_resource_template_deletions(t *testing.T) {
+	var out bytes.Buffer
+	err := runDiff([]string{"--old", "testdata/resource_template_set.yaml", "--new", "testdata/resource_template_set_removed.yaml"}, &out)
+
+	var exitErr *exitError
+	if !errors.As(err, &exitErr) || exitErr.code != deletionsExitCode {
+		t.Fatalf("got error %v, want an exitError with code %d", err, deletionsExitCode)
+	}
+	want := "- ConfigMap/default/engineering-prod-config\n0 added, 1 removed, 0 changed\n"
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Fatalf("failed to diff:\n%s", diff)
+	}
+}
+
+func TestDiff_kustomization_version(t *testing.T) {
+	var out bytes.Buffer
+	err := runDiff([]string{"--old", "testdata/list_set.yaml", "--new", "testdata/list_set_v1.yaml"}, &out)
+	test.AssertNoError(t, err)
+
+	want := `~ Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-dev-demo
+--- old/Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-dev-demo
++++ new/Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-dev-demo
+@@ -1,4 +1,4 @@
+-apiVersion: kustomize.toolkit.fluxcd.io/v1beta2
++apiVersion: kustomize.toolkit.fluxcd.io/v1
+ kind: Kustomization
+ metadata:
+   name: engineering-dev-demo
+~ Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-prod-demo
+--- old/Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-prod-demo
++++ new/Kustomization.kustomize.toolkit.fluxcd.io/default/engineering-prod-demo
+@@ -1,4 +1,4 @@
+-apiVersion: kustomize.toolkit.fluxcd.io/v1beta2
++apiVersion: kustomize.toolkit.fluxcd.io/v1
+ kind: Kustomization
+ metadata:
+   name: engineering-prod-demo
+0 added, 0 removed, 2 changed
+`
+	if diff := cmp.Diff(want, out.String()); diff != "" {
+		t.Fatalf("failed to diff:\n%s", diff)
+	}
+}
+
+func TestDiff_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no new file", []string{"--old", "testdata/list_set.yaml"}, "both --old and --new KustomizationSet files must be provided"},
+		{"failing old file", []string{"--old", "testdata/set.yaml", "--new", "testdata/list_set.yaml"}, "failed to generate from testdata/set.yaml: .* no local directory provided"},
+		{"failing new file", []string{"--old", "testdata/list_set.yaml", "--new", "testdata/missing.yaml"}, "failed to generate from testdata/missing.yaml: failed to read KustomizationSet"},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runDiff(tt.args, &bytes.Buffer{})
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	kustomizesetv1alpha2 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha2"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/gitrepository"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/list"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/pullrequest"
+)
+
+const defaultNamespace = "default"
+
+// generatorFlags configures the generators used to render KustomizationSets
+// without a cluster.
+type generatorFlags struct {
+	repoDir              string
+	pullRequests         string
+	kustomizationVersion string
+}
+
+func (g *generatorFlags) bind(fs *flag.FlagSet) {
+	fs.StringVar(&g.repoDir, "repo", "", "A local checkout of the repository used by GitRepository generators.")
+	fs.StringVar(&g.pullRequests, "pull-requests", "", "A YAML or JSON file of recorded pull requests used by PullRequest generators.")
+	fs.StringVar(&g.kustomizationVersion, "kustomization-version", kustomizesetv1.KustomizationVersionV1Beta2,
+		"The version of the Kustomization API to generate when the KustomizationSet doesn't specify a version, this should match the controller.")
+}
+
+// generators returns the generators for rendering without a cluster, the
+// GitRepository generator parses the local checkout, and the PullRequest
+// generator uses the recorded pull requests.
+func (g *generatorFlags) generators() (map[string]generators.Generator, error) {
+	var fixtures []pullrequest.PullRequestFixture
+	if g.pullRequests != "" {
+		var err error
+		fixtures, err = pullrequest.LoadFixtures(g.pullRequests)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return map[string]generators.Generator{
+		"List":          list.NewGenerator(),
+		"GitRepository": gitrepository.NewLocalGenerator(g.repoDir),
+		"PullRequest":   pullrequest.NewFixtureGenerator(fixtures),
+	}, nil
+}
+
+func runGenerate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	var filename, output string
+	var gens generatorFlags
+	fs.StringVar(&filename, "f", "", "The file containing the KustomizationSet.")
+	fs.StringVar(&output, "o", "yaml", "The output format (yaml or json).")
+	gens.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if filename == "" {
+		return errors.New("a KustomizationSet file must be provided with -f")
+	}
+	if output != "yaml" && output != "json" {
+		return fmt.Errorf("unsupported output format %q", output)
+	}
+
+	resources, err := generateFromFile(filename, &gens)
+	if err != nil {
+		return err
+	}
+
+	return writeResources(stdout, resources, output)
+}
+
+// generateFromFile loads the KustomizationSet from the file and generates its
+// resources, in the same way as the controller.
+func generateFromFile(filename string, gens *generatorFlags) ([]*unstructured.Unstructured, error) {
+	set, err := loadKustomizationSet(filename)
+	if err != nil {
+		return nil, err
+	}
+	configured, err := gens.generators()
+	if err != nil {
+		return nil, err
+	}
+
+	generated, err := reconciler.GenerateResources(context.Background(), set, configured, reconciler.GenerateOptions{
+		IsNamespaced:         isNamespaced,
+		KustomizationVersion: gens.kustomizationVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resources := []*unstructured.Unstructured{}
+	for _, v := range generated {
+		resources = append(resources, v.Resource)
+	}
+
+	return resources, nil
+}
+
+// isNamespaced is a reconciler.NamespacedFunc that looks up the scope of a
+// kind without a cluster, all kinds that are not known to be cluster-scoped
+// are namespaced.
+func isNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	return !clusterScopedKinds[gvk.Kind], nil
+}
+
+// loadKustomizationSet parses a KustomizationSet from a YAML or JSON file,
+// the KustomizationSet is in the default namespace if it doesn't have one.
+func loadKustomizationSet(filename string) (*kustomizesetv1.KustomizationSet, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KustomizationSet: %w", err)
+	}
+	typeMeta := &metav1.TypeMeta{}
+	if err := yaml.Unmarshal(b, typeMeta); err != nil {
+		return nil, fmt.Errorf("failed to parse KustomizationSet from %s: %w", filename, err)
+	}
+	set, err := decodeKustomizationSet(typeMeta.APIVersion, func(obj any) error {
+		return yaml.UnmarshalStrict(b, obj)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse KustomizationSet from %s: %w", filename, err)
+	}
+	if set.Kind != "KustomizationSet" {
+		return nil, fmt.Errorf("%s does not contain a KustomizationSet, got kind %q", filename, set.Kind)
+	}
+	if set.GetNamespace() == "" {
+		set.SetNamespace(defaultNamespace)
+	}
+
+	return set, nil
+}
+
+// decodeKustomizationSet decodes a KustomizationSet of the API version with
+// the decode function, v1alpha2 KustomizationSets are converted to v1alpha1,
+// which is the version that is generated from.
+func decodeKustomizationSet(apiVersion string, decode func(any) error) (*kustomizesetv1.KustomizationSet, error) {
+	set := &kustomizesetv1.KustomizationSet{}
+	if apiVersion != kustomizesetv1alpha2.GroupVersion.String() {
+		return set, decode(set)
+	}
+
+	spoke := &kustomizesetv1alpha2.KustomizationSet{}
+	if err := decode(spoke); err != nil {
+		return nil, err
+	}
+	if err := spoke.ConvertTo(set); err != nil {
+		return nil, err
+	}
+	set.TypeMeta = metav1.TypeMeta{Kind: spoke.Kind, APIVersion: kustomizesetv1.GroupVersion.String()}
+
+	return set, nil
+}
+
+// writeResources writes the resources as YAML documents, or as a JSON List.
+func writeResources(w io.Writer, resources []*unstructured.Unstructured, output string) error {
+	items := []any{}
+	for _, v := range resources {
+		items = append(items, v.Object)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]any{"apiVersion": "v1", "kind": "List", "items": items})
+	}
+
+	for i, item := range items {
+		b, err := yaml.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %w", resources[i].GetKind(), err)
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
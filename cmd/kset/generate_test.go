@@ -0,0 +1,237 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/yaml"
+
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+const wantListSetYAML = `apiVersion: kustomize.toolkit.fluxcd.io/v1beta2
+kind: Kustomization
+metadata:
+  name: engineering-dev-demo
+  namespace: default
+spec:
+  interval: 5m0s
+  path: ./clusters/engineering-dev/
+  prune: true
+  sourceRef:
+    kind: GitRepository
+    name: demo-repo
+---
+apiVersion: kustomize.toolkit.fluxcd.io/v1beta2
+kind: Kustomization
+metadata:
+  name: engineering-prod-demo
+  namespace: default
+spec:
+  interval: 5m0s
+  path: ./clusters/engineering-prod/
+  prune: true
+  sourceRef:
+    kind: GitRepository
+    name: demo-repo
+`
+
+func TestGenerate_yaml(t *testing.T) {
+	var out bytes.Buffer
+	err := runGenerate([]string{"-f", "testdata/list_set.yaml"}, &out)
+	test.AssertNoError(t, err)
+
+	if diff := cmp.Diff(wantListSetYAML, out.String()); diff != "" {
+		t.Fatalf("failed to generate:\n%s", diff)
+	}
+}
+
+func TestGenerate_json(t *testing.T) {
+	var out bytes.Buffer
+	err := runGenerate([]string{"-f", "testdata/list_set.yaml", "-o", "json"}, &out)
+	test.AssertNoError(t, err)
+
+	var list struct {
+		Kind  string           `json:"kind"`
+		Items []map[string]any `json:"items"`
+	}
+	test.AssertNoError(t, json.Unmarshal(out.Bytes(), &list))
+	if list.Kind != "List" {
+		t.Fatalf("got kind %q, want List", list.Kind)
+	}
+	if diff := cmp.Diff(parseDocuments(t, []byte(wantListSetYAML)), list.Items); diff != "" {
+		t.Fatalf("failed to generate:\n%s", diff)
+	}
+}
+
+func TestGenerate_offline_generators(t *testing.T) {
+	var out bytes.Buffer
+	err := runGenerate([]string{
+		"-f", "testdata/set.yaml",
+		"--repo", "testdata/repo",
+		"--pull-requests", "testdata/pull_requests.yaml",
+	}, &out)
+	test.AssertNoError(t, err)
+
+	if diff := cmp.Diff([]string{"demo/staging-demo", "demo/dev-demo", "demo/production-demo", "demo/pr-1"}, generatedNames(t, out.Bytes())); diff != "" {
+		t.Fatalf("failed to generate:\n%s", diff)
+	}
+}
+
+func TestGenerate_v1alpha2(t *testing.T) {
+	var out bytes.Buffer
+	err := runGenerate([]string{
+		"-f", "testdata/v1alpha2_set.yaml",
+		"--repo", "testdata/repo",
+		"--pull-requests", "testdata/pull_requests.yaml",
+	}, &out)
+	test.AssertNoError(t, err)
+
+	if diff := cmp.Diff([]string{"demo/staging-demo", "demo/dev-demo", "demo/production-demo", "demo/pr-1"}, generatedNames(t, out.Bytes())); diff != "" {
+		t.Fatalf("failed to generate:\n%s", diff)
+	}
+}
+
+func TestGenerate_resource_templates(t *testing.T) {
+	generateTests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "resource template",
+			args: []string{"-f", "testdata/resource_template_set.yaml"},
+			want: `apiVersion: v1
+data:
+  cluster: engineering-dev
+kind: ConfigMap
+metadata:
+  name: engineering-dev-config
+  namespace: default
+---
+apiVersion: v1
+data:
+  cluster: engineering-prod
+kind: ConfigMap
+metadata:
+  name: engineering-prod-config
+  namespace: default
+`,
+		},
+		{
+			name: "multiple templates",
+			args: []string{"-f", "testdata/templates_set.yaml"},
+			want: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: engineering-dev
+---
+apiVersion: v1
+data:
+  cluster: engineering-dev
+kind: ConfigMap
+metadata:
+  name: engineering-dev-config
+  namespace: default
+`,
+		},
+		{
+			name: "v1 Kustomizations",
+			args: []string{"-f", "testdata/v1_set.yaml"},
+			want: `apiVersion: kustomize.toolkit.fluxcd.io/v1
+kind: Kustomization
+metadata:
+  name: engineering-dev-demo
+  namespace: default
+spec:
+  interval: 5m0s
+  path: ./clusters/engineering-dev/
+  prune: true
+  sourceRef:
+    kind: GitRepository
+    name: demo-repo
+`,
+		},
+		{
+			name: "default Kustomization version",
+			args: []string{"-f", "testdata/list_set.yaml", "--kustomization-version", "v1"},
+			want: strings.ReplaceAll(wantListSetYAML, "fluxcd.io/v1beta2", "fluxcd.io/v1"),
+		},
+	}
+
+	for _, tt := range generateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runGenerate(tt.args, &out)
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.want, out.String()); diff != "" {
+				t.Fatalf("failed to generate:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerate_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no file", []string{}, "a KustomizationSet file must be provided with -f"},
+		{"missing file", []string{"-f", "testdata/missing.yaml"}, "failed to read KustomizationSet"},
+		{"not a set", []string{"-f", "testdata/pull_requests.yaml"}, "failed to parse KustomizationSet from testdata/pull_requests.yaml"},
+		{"unknown output", []string{"-f", "testdata/list_set.yaml", "-o", "xml"}, `unsupported output format "xml"`},
+		{"no checkout", []string{"-f", "testdata/set.yaml"}, "no local directory provided for GitRepository demo-repo"},
+		{"no pull requests", []string{"-f", "testdata/set.yaml", "--repo", "testdata/repo"}, "no pull request fixtures provided for repo test-org/test-repo"},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runGenerate(tt.args, &bytes.Buffer{})
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
+
+// generatedNames returns the namespace/name of the generated Kustomizations.
+func generatedNames(t *testing.T, b []byte) []string {
+	t.Helper()
+	names := []string{}
+	for _, doc := range parseDocuments(t, b) {
+		metadata := doc["metadata"].(map[string]any)
+		names = append(names, metadata["namespace"].(string)+"/"+metadata["name"].(string))
+	}
+
+	return names
+}
+
+func parseDocuments(t *testing.T, b []byte) []map[string]any {
+	t.Helper()
+	docs := []map[string]any{}
+	for _, doc := range bytes.Split(b, []byte("---\n")) {
+		parsed := map[string]any{}
+		test.AssertNoError(t, yaml.Unmarshal(doc, &parsed))
+		docs = append(docs, parsed)
+	}
+
+	return docs
+}
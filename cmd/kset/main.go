@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kset works with KustomizationSets without a cluster.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `kset works with KustomizationSets without a cluster.
+
+Usage:
+  kset <command> [flags]
+
+Commands:
+  generate    Print the resources generated from a KustomizationSet
+  diff        Compare the resources generated from two revisions of a KustomizationSet
+  validate    Check a KustomizationSet for problems
+
+Use "kset <command> -h" for the flags of a command.
+`
+
+// command runs a kset command with its arguments.
+type command func(args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"generate": runGenerate,
+	"diff":     runDiff,
+	"validate": runValidate,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := cmd(args[1:], stdout); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			fmt.Fprintln(stderr, exitErr.message)
+			return exitErr.code
+		}
+		fmt.Fprintf(stderr, "Error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// exitError is returned by commands that complete, but need to exit with a
+// specific code, e.g. when a diff contains deletions.
+type exitError struct {
+	code    int
+	message string
+}
+
+func (e *exitError) Error() string {
+	return e.message
+}
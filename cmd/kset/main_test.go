@@ -0,0 +1,43 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	runTests := []struct {
+		name     string
+		args     []string
+		wantCode int
+	}{
+		{"no command", []string{}, 2},
+		{"unknown command", []string{"unknown"}, 2},
+		{"failing command", []string{"generate"}, 1},
+		{"successful command", []string{"generate", "-f", "testdata/list_set.yaml"}, 0},
+		{"diff with deletions", []string{"diff", "--old", "testdata/list_set.yaml", "--new", "testdata/list_set_changed.yaml"}, deletionsExitCode},
+	}
+
+	for _, tt := range runTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := run(tt.args, &bytes.Buffer{}, &bytes.Buffer{}); code != tt.wantCode {
+				t.Fatalf("got exit code %d, want %d", code, tt.wantCode)
+			}
+		})
+	}
+}
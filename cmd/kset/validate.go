@@ -0,0 +1,325 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	apivalidation "k8s.io/apiextensions-apiserver/pkg/apiserver/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/pullrequest"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/validation"
+)
+
+const defaultCRDPath = "config/crd/bases/source.gitops.solutions_kustomizationsets.yaml"
+
+// clusterScopedKinds are common kinds that are not namespaced, the namespace
+// of generated resources of these kinds is ignored, and they are rendered
+// without a namespace.
+var clusterScopedKinds = map[string]bool{
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"CustomResourceDefinition": true,
+	"Namespace":                true,
+	"PersistentVolume":         true,
+	"PriorityClass":            true,
+	"StorageClass":             true,
+}
+
+// validationResult is the problems found in a KustomizationSet, errors would
+// prevent the KustomizationSet from being reconciled.
+type validationResult struct {
+	errors   []string
+	warnings []string
+}
+
+func (v *validationResult) errorf(format string, a ...any) {
+	v.errors = append(v.errors, fmt.Sprintf(format, a...))
+}
+
+func (v *validationResult) warnf(format string, a ...any) {
+	v.warnings = append(v.warnings, fmt.Sprintf(format, a...))
+}
+
+func runValidate(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	var filename, crdFilename string
+	fs.StringVar(&filename, "f", "", "The file containing the KustomizationSet.")
+	fs.StringVar(&crdFilename, "crd", defaultCRDPath, "The file containing the KustomizationSet CustomResourceDefinition.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if filename == "" {
+		return errors.New("a KustomizationSet file must be provided with -f")
+	}
+
+	crd, err := loadCRD(crdFilename)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read KustomizationSet: %w", err)
+	}
+	result, err := validateKustomizationSet(b, crd)
+	if err != nil {
+		return fmt.Errorf("failed to validate %s: %w", filename, err)
+	}
+
+	for _, v := range result.errors {
+		fmt.Fprintf(stdout, "error: %s\n", v)
+	}
+	for _, v := range result.warnings {
+		fmt.Fprintf(stdout, "warning: %s\n", v)
+	}
+	if len(result.errors) > 0 {
+		return fmt.Errorf("%s is not valid: %d error(s) found", filename, len(result.errors))
+	}
+	_, err = fmt.Fprintf(stdout, "%s is valid\n", filename)
+
+	return err
+}
+
+func loadCRD(filename string) (*apiextensionsv1.CustomResourceDefinition, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CustomResourceDefinition: %w", err)
+	}
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := yaml.Unmarshal(b, crd); err != nil {
+		return nil, fmt.Errorf("failed to parse CustomResourceDefinition from %s: %w", filename, err)
+	}
+
+	return crd, nil
+}
+
+// validateKustomizationSet checks the KustomizationSet against the schema in
+// the CRD, and then checks the generators and templates.
+func validateKustomizationSet(b []byte, crd *apiextensionsv1.CustomResourceDefinition) (*validationResult, error) {
+	result := &validationResult{}
+	raw, err := yaml.YAMLToJSON(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse KustomizationSet: %w", err)
+	}
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(raw); err != nil {
+		return nil, fmt.Errorf("failed to parse KustomizationSet: %w", err)
+	}
+	if err := validateSchema(u, crd, result); err != nil {
+		return nil, err
+	}
+	if len(result.errors) > 0 {
+		return result, nil
+	}
+
+	set, err := decodeKustomizationSet(u.GetAPIVersion(), func(obj any) error {
+		return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, obj)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse KustomizationSet: %w", err)
+	}
+	if set.GetNamespace() == "" {
+		set.SetNamespace(defaultNamespace)
+	}
+	// These are the same checks that the validating webhook makes.
+	for _, v := range validation.ValidateKustomizationSet(set) {
+		result.errorf("%s", v.Error())
+	}
+	if len(result.errors) > 0 {
+		return result, nil
+	}
+	validateTemplates(set, result)
+	validateNamespaces(set, result)
+
+	return result, nil
+}
+
+// validateSchema validates the KustomizationSet against the OpenAPI schema of
+// the matching version in the CRD, fields that are not in the schema are
+// reported as errors.
+func validateSchema(u *unstructured.Unstructured, crd *apiextensionsv1.CustomResourceDefinition, result *validationResult) error {
+	gvk := u.GroupVersionKind()
+	if gvk.Group != crd.Spec.Group || gvk.Kind != crd.Spec.Names.Kind {
+		result.errorf("got %s, want a %s in the %s group", gvk.GroupKind(), crd.Spec.Names.Kind, crd.Spec.Group)
+		return nil
+	}
+	var version *apiextensionsv1.CustomResourceDefinitionVersion
+	for i := range crd.Spec.Versions {
+		if crd.Spec.Versions[i].Name == gvk.Version {
+			version = &crd.Spec.Versions[i]
+		}
+	}
+	if version == nil || version.Schema == nil {
+		result.errorf("version %s of %s is not supported", gvk.Version, gvk.Kind)
+		return nil
+	}
+
+	internal := &apiextensions.CustomResourceValidation{}
+	if err := apiextensionsv1.Convert_v1_CustomResourceValidation_To_apiextensions_CustomResourceValidation(version.Schema, internal, nil); err != nil {
+		return fmt.Errorf("failed to convert schema: %w", err)
+	}
+	validator, _, err := apivalidation.NewSchemaValidator(internal)
+	if err != nil {
+		return fmt.Errorf("failed to create schema validator: %w", err)
+	}
+	for _, v := range apivalidation.ValidateCustomResource(nil, u.Object, validator) {
+		result.errorf("%s", v.Error())
+	}
+
+	structural, err := structuralschema.NewStructural(internal.OpenAPIV3Schema)
+	if err != nil {
+		return fmt.Errorf("failed to create structural schema: %w", err)
+	}
+	unknown := pruning.PruneWithOptions(runtime.DeepCopyJSON(u.Object), structural, true, structuralschema.UnknownFieldPathOptions{TrackUnknownFieldPaths: true})
+	for _, v := range unknown {
+		result.errorf("%s: unknown field", v)
+	}
+
+	return nil
+}
+
+// validateTemplates checks that the parameters the templates reference are
+// generated, where the generated keys are known without generating them.
+func validateTemplates(set *kustomizesetv1.KustomizationSet, result *validationResult) {
+	params, err := reconciler.TemplateParams(set)
+	if err != nil {
+		// The templates have already been parsed when validating the set.
+		result.errorf("%s", err)
+		return
+	}
+
+	for i, gen := range set.Spec.Generators {
+		if set.Spec.Template == nil && reconciler.GeneratorTemplate(gen) != nil {
+			result.warnf("the template of generator %d is ignored when generating from resource templates", i)
+		}
+
+		generated, known, err := generatedParams(gen)
+		if err != nil {
+			result.errorf("generator %d: %s", i, err)
+			continue
+		}
+		if !known {
+			continue
+		}
+		for _, key := range params[i] {
+			all, ok := generated[key]
+			switch {
+			case !ok:
+				result.errorf("the template for generator %d references parameter %q, which is not generated by the %s generator", i, key, validation.GeneratorKinds(gen)[0])
+			case !all:
+				result.errorf("the template for generator %d references parameter %q, which is not generated for every element of the %s generator", i, key, validation.GeneratorKinds(gen)[0])
+			}
+		}
+	}
+}
+
+// validateNamespaces checks the namespaces of the generated resources where
+// they are not templated.
+func validateNamespaces(set *kustomizesetv1.KustomizationSet, result *validationResult) {
+	checkAllowed := func(ns string) {
+		if ns == "" || strings.Contains(ns, "{{") || ns == set.GetNamespace() {
+			return
+		}
+		for _, v := range set.Spec.AllowedNamespaces {
+			if v == ns {
+				return
+			}
+		}
+		result.errorf("namespace %q is not permitted, it must be listed in allowedNamespaces", ns)
+	}
+
+	if set.Spec.Template != nil {
+		checkAllowed(set.Spec.Template.Namespace)
+		for i, gen := range set.Spec.Generators {
+			tmpl := reconciler.GeneratorTemplate(gen)
+			if tmpl == nil || tmpl.Namespace == "" {
+				continue
+			}
+			checkAllowed(tmpl.Namespace)
+			if ns := set.Spec.Template.Namespace; ns != "" && ns != tmpl.Namespace {
+				result.warnf("the template namespace %q is ignored for generator %d, which sets namespace %q", ns, i, tmpl.Namespace)
+			}
+		}
+		return
+	}
+
+	templates := set.Spec.Templates
+	if set.Spec.ResourceTemplate != nil {
+		templates = []kustomizesetv1.KustomizationSetResourceTemplate{{Name: "resource", Resource: *set.Spec.ResourceTemplate}}
+	}
+	for _, tmpl := range templates {
+		var resource unstructured.Unstructured
+		// Templates that are not valid JSON before rendering can't be checked.
+		if err := resource.UnmarshalJSON(tmpl.Resource.Raw); err != nil {
+			continue
+		}
+		if clusterScopedKinds[resource.GetKind()] && resource.GetNamespace() != "" {
+			result.warnf("the namespace %q of the %s in template %s is ignored, %s is cluster-scoped", resource.GetNamespace(), resource.GetKind(), tmpl.Name, resource.GetKind())
+			continue
+		}
+		checkAllowed(resource.GetNamespace())
+	}
+}
+
+// generatedParams returns the parameter keys the generator generates, and
+// false if these aren't known without generating them.
+//
+// A key is true if it is generated in every set of parameters, and false if
+// it is only generated in some, e.g. by some of the elements of a List
+// generator.
+func generatedParams(gen kustomizesetv1.KustomizationSetGenerator) (map[string]bool, bool, error) {
+	keys := map[string]bool{}
+	switch {
+	case gen.List != nil:
+		counts := map[string]int{}
+		for i, element := range gen.List.Elements {
+			params := map[string]any{}
+			if err := json.Unmarshal(element.Raw, &params); err != nil {
+				return nil, false, fmt.Errorf("element %d is not an object: %w", i, err)
+			}
+			for k := range params {
+				counts[k]++
+			}
+		}
+		for k, count := range counts {
+			keys[k] = count == len(gen.List.Elements)
+		}
+		return keys, true, nil
+	case gen.PullRequest != nil:
+		for _, k := range pullrequest.ParamKeys {
+			keys[k] = true
+		}
+		return keys, true, nil
+	}
+
+	return nil, false, nil
+}
@@ -0,0 +1,270 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+const testCRDPath = "../../" + defaultCRDPath
+
+const validationHeader = `apiVersion: source.gitops.solutions/v1alpha1
+kind: KustomizationSet
+metadata:
+  name: test-set
+`
+
+const validationTemplate = `  template:
+    metadata:
+      name: "{{.cluster}}-demo"
+    spec:
+      interval: 5m
+      path: "./clusters/{{.cluster}}/"
+      prune: true
+      sourceRef:
+        kind: GitRepository
+        name: demo-repo
+`
+
+func TestValidateKustomizationSet(t *testing.T) {
+	crd, err := loadCRD(testCRDPath)
+	test.AssertNoError(t, err)
+
+	validationTests := []struct {
+		name         string
+		spec         string
+		wantErrors   []string
+		wantWarnings []string
+	}{
+		{
+			name: "valid set",
+			spec: `spec:
+  generators:
+    - list:
+        elements:
+          - cluster: engineering-dev
+` + validationTemplate,
+		},
+		{
+			name: "schema violations",
+			spec: `spec:
+  unknownField: true
+  generators:
+    - list:
+        elements:
+          - cluster: engineering-dev
+      policy: delete-only
+` + validationTemplate,
+			wantErrors: []string{
+				`spec.generators[0].policy: Unsupported value: "delete-only": supported values: "create-only", "create-update", "sync"`,
+				"spec.unknownField: unknown field",
+			},
+		},
+		{
+			name: "unsupported generator combinations",
+			spec: `spec:
+  generators:
+    - {}
+    - list:
+        elements:
+          - cluster: engineering-dev
+      pullRequest:
+        interval: 5m
+        driver: github
+        repo: test-org/test-repo
+` + validationTemplate,
+			wantErrors: []string{
+				"spec.generators[0]: Required value: one of list, pullRequest or gitRepository must be configured",
+				"spec.generators[1]: Forbidden: only one of list, pullRequest or gitRepository can be configured, got list and pullRequest",
+			},
+		},
+		{
+			name: "template that can't be parsed",
+			spec: `spec:
+  generators:
+    - list:
+        elements:
+          - cluster: engineering-dev
+  resourceTemplate:
+    apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: "{{ .cluster }"
+`,
+			wantErrors: []string{
+				`spec.resourceTemplate: Invalid value: failed to parse template resource for set test-set: failed to parse template: template: kustomization:1: unexpected "}" in operand`,
+			},
+		},
+		{
+			name: "parameters that are not generated",
+			spec: `spec:
+  generators:
+    - list:
+        elements:
+          - name: engineering-dev
+    - pullRequest:
+        interval: 5m
+        driver: github
+        repo: test-org/test-repo
+    - gitRepository:
+        repositoryRef: demo-repo
+` + validationTemplate,
+			wantErrors: []string{
+				`the template for generator 0 references parameter "cluster", which is not generated by the list generator`,
+				`the template for generator 1 references parameter "cluster", which is not generated by the pullRequest generator`,
+			},
+		},
+		{
+			name: "parameters that are not generated for every element",
+			spec: `spec:
+  generators:
+    - list:
+        elements:
+          - cluster: engineering-dev
+          - name: engineering-prod
+` + validationTemplate,
+			wantErrors: []string{
+				`the template for generator 0 references parameter "cluster", which is not generated for every element of the list generator`,
+			},
+		},
+		{
+			name: "namespaces",
+			spec: `spec:
+  allowedNamespaces:
+    - staging
+  generators:
+    - list:
+        elements:
+          - cluster: engineering-dev
+        template:
+          metadata:
+            namespace: staging
+          spec:
+            interval: 5m
+            prune: true
+            sourceRef:
+              kind: GitRepository
+              name: demo-repo
+  template:
+    metadata:
+      name: "{{.cluster}}-demo"
+      namespace: production
+    spec:
+      interval: 5m
+      path: "./clusters/{{.cluster}}/"
+      prune: true
+      sourceRef:
+        kind: GitRepository
+        name: demo-repo
+`,
+			wantErrors: []string{
+				`namespace "production" is not permitted, it must be listed in allowedNamespaces`,
+			},
+			wantWarnings: []string{
+				`the template namespace "production" is ignored for generator 0, which sets namespace "staging"`,
+			},
+		},
+		{
+			name: "resource templates",
+			spec: `spec:
+  generators:
+    - list:
+        elements:
+          - cluster: engineering-dev
+        template:
+          metadata:
+            name: ignored
+          spec:
+            interval: 5m
+            prune: true
+            sourceRef:
+              kind: GitRepository
+              name: demo-repo
+  templates:
+    - name: namespace
+      resource:
+        apiVersion: v1
+        kind: Namespace
+        metadata:
+          name: "{{ .cluster }}"
+          namespace: default
+`,
+			wantWarnings: []string{
+				"the template of generator 0 is ignored when generating from resource templates",
+				`the namespace "default" of the Namespace in template namespace is ignored, Namespace is cluster-scoped`,
+			},
+		},
+	}
+
+	for _, tt := range validationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := validateKustomizationSet([]byte(validationHeader+tt.spec), crd)
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.wantErrors, result.errors); diff != "" {
+				t.Errorf("failed to get errors:\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantWarnings, result.warnings); diff != "" {
+				t.Errorf("failed to get warnings:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	var out bytes.Buffer
+	err := runValidate([]string{"-f", "testdata/set.yaml", "--crd", testCRDPath}, &out)
+	test.AssertNoError(t, err)
+
+	if want := "testdata/set.yaml is valid\n"; out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestValidate_v1alpha2(t *testing.T) {
+	var out bytes.Buffer
+	err := runValidate([]string{"-f", "testdata/v1alpha2_set.yaml", "--crd", testCRDPath}, &out)
+	test.AssertNoError(t, err)
+
+	if want := "testdata/v1alpha2_set.yaml is valid\n"; out.String() != want {
+		t.Fatalf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestValidate_errors(t *testing.T) {
+	errorTests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"no file", []string{"--crd", testCRDPath}, "a KustomizationSet file must be provided with -f"},
+		{"missing CRD", []string{"-f", "testdata/set.yaml", "--crd", "testdata/missing.yaml"}, "failed to read CustomResourceDefinition"},
+		{"not a set", []string{"-f", "testdata/pull_requests.yaml", "--crd", testCRDPath}, "failed to validate testdata/pull_requests.yaml: failed to parse KustomizationSet"},
+		{"invalid set", []string{"-f", "testdata/invalid_set.yaml", "--crd", testCRDPath}, `testdata/invalid_set.yaml is not valid: 1 error\(s\) found`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runValidate(tt.args, &bytes.Buffer{})
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
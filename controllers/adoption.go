@@ -0,0 +1,90 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// adoptionRefusedError is returned when a generated resource already exists
+// and the adoption policy of the KustomizationSet doesn't allow it to be
+// adopted.
+type adoptionRefusedError struct {
+	kind   string
+	key    client.ObjectKey
+	reason string
+}
+
+func (e *adoptionRefusedError) Error() string {
+	return fmt.Sprintf("%s %s already exists and %s", e.kind, e.key, e.reason)
+}
+
+// adoptResource applies the generated resource over an existing resource if
+// the adoption policy of the KustomizationSet allows it.
+func (r *KustomizationSetReconciler) adoptResource(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet, resource, existing *unstructured.Unstructured) error {
+	if reason := adoptionRefusal(kustomizationSet, existing); reason != "" {
+		return &adoptionRefusedError{kind: resource.GetKind(), key: client.ObjectKeyFromObject(resource), reason: reason}
+	}
+
+	// Adopting a resource always changes it, at least to add the
+	// KustomizationSet labels.
+	return r.applyResource(ctx, kustomizationSet, &existingResource{generated: resource, existing: existing, outOfDate: true})
+}
+
+// adoptionRefusal returns the reason that the existing resource can't be
+// adopted by the KustomizationSet, or an empty string if it can be adopted.
+//
+// Resources that are controlled by another object are never adopted, the
+// other controller would fight over the resource.
+//
+// Resources that are labelled as generated by the KustomizationSet can always
+// be adopted, these were created by the KustomizationSet but not recorded in
+// the inventory.
+func adoptionRefusal(kustomizationSet *kustomizesetv1.KustomizationSet, existing *unstructured.Unstructured) string {
+	if ref := metav1.GetControllerOfNoCopy(existing); ref != nil && ref.UID != kustomizationSet.GetUID() {
+		return fmt.Sprintf("is controlled by %s %s", ref.Kind, ref.Name)
+	}
+
+	labels := existing.GetLabels()
+	name, namespace := labels[kustomizesetv1.SetNameLabel], labels[kustomizesetv1.SetNamespaceLabel]
+	if name != "" || namespace != "" {
+		if name == kustomizationSet.GetName() && namespace == kustomizationSet.GetNamespace() {
+			return ""
+		}
+		return fmt.Sprintf("is managed by KustomizationSet %s/%s", namespace, name)
+	}
+
+	switch kustomizationSet.Spec.AdoptionPolicy {
+	case kustomizesetv1.AdoptionPolicyAlways:
+		return ""
+	case kustomizesetv1.AdoptionPolicyIfAnnotated:
+		want := client.ObjectKeyFromObject(kustomizationSet).String()
+		if existing.GetAnnotations()[kustomizesetv1.AdoptAnnotation] == want {
+			return ""
+		}
+		return fmt.Sprintf("is not annotated with %s=%s", kustomizesetv1.AdoptAnnotation, want)
+	}
+
+	return "is not managed by this KustomizationSet"
+}
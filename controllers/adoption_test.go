@@ -0,0 +1,114 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sourcev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+func TestAdoptionRefusal(t *testing.T) {
+	isController := true
+	adoptionTests := []struct {
+		name        string
+		policy      string
+		labels      map[string]string
+		annotations map[string]string
+		owners      []metav1.OwnerReference
+		want        string
+	}{
+		{
+			name: "default policy",
+			want: "is not managed by this KustomizationSet",
+		},
+		{
+			name:   "refuse policy",
+			policy: sourcev1alpha1.AdoptionPolicyRefuse,
+			want:   "is not managed by this KustomizationSet",
+		},
+		{
+			name:   "always policy",
+			policy: sourcev1alpha1.AdoptionPolicyAlways,
+		},
+		{
+			name:        "annotated for this set",
+			policy:      sourcev1alpha1.AdoptionPolicyIfAnnotated,
+			annotations: map[string]string{sourcev1alpha1.AdoptAnnotation: "default/demo-set"},
+		},
+		{
+			name:        "annotated for another set",
+			policy:      sourcev1alpha1.AdoptionPolicyIfAnnotated,
+			annotations: map[string]string{sourcev1alpha1.AdoptAnnotation: "default/other-set"},
+			want:        "is not annotated with source.gitops.solutions/adopt=default/demo-set",
+		},
+		{
+			name:   "not annotated",
+			policy: sourcev1alpha1.AdoptionPolicyIfAnnotated,
+			want:   "is not annotated with source.gitops.solutions/adopt=default/demo-set",
+		},
+		{
+			name:   "generated by this set",
+			labels: map[string]string{sourcev1alpha1.SetNameLabel: "demo-set", sourcev1alpha1.SetNamespaceLabel: "default"},
+		},
+		{
+			name:   "generated by another set",
+			policy: sourcev1alpha1.AdoptionPolicyAlways,
+			labels: map[string]string{sourcev1alpha1.SetNameLabel: "other-set", sourcev1alpha1.SetNamespaceLabel: "default"},
+			want:   "is managed by KustomizationSet default/other-set",
+		},
+		{
+			name:   "controlled by another object",
+			policy: sourcev1alpha1.AdoptionPolicyAlways,
+			owners: []metav1.OwnerReference{
+				{APIVersion: "helm.toolkit.fluxcd.io/v2beta1", Kind: "HelmRelease", Name: "other", UID: "other-uid", Controller: &isController},
+			},
+			want: "is controlled by HelmRelease other",
+		},
+		{
+			name:   "controlled by this set",
+			labels: map[string]string{sourcev1alpha1.SetNameLabel: "demo-set", sourcev1alpha1.SetNamespaceLabel: "default"},
+			owners: []metav1.OwnerReference{
+				{APIVersion: sourcev1alpha1.GroupVersion.String(), Kind: "KustomizationSet", Name: "demo-set", UID: "test-uid", Controller: &isController},
+			},
+		},
+		{
+			name:   "owned by another object",
+			policy: sourcev1alpha1.AdoptionPolicyAlways,
+			owners: []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "other", UID: "other-uid"},
+			},
+		},
+	}
+
+	for _, tt := range adoptionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+				ks.ObjectMeta.UID = "test-uid"
+				ks.Spec.AdoptionPolicy = tt.policy
+			})
+			existing := newTestUnstructured("test", tt.labels)
+			existing.SetAnnotations(tt.annotations)
+			existing.SetOwnerReferences(tt.owners)
+
+			if got := adoptionRefusal(ks, existing); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+)
+
+// removedFields returns the paths of the fields that the controller applied
+// to the existing resource that are not in the generated resource, applying
+// the generated resource removes these fields.
+//
+// Fields within a removed field are not returned.
+func removedFields(generated, existing *unstructured.Unstructured) ([]fieldpath.Path, error) {
+	applied, err := appliedFields(existing, fieldManager)
+	if err != nil || applied == nil {
+		return nil, err
+	}
+
+	missing := []fieldpath.Path{}
+	applied.Iterate(func(p fieldpath.Path) {
+		if _, ok := fieldValue(generated.Object, p); !ok {
+			missing = append(missing, p.Copy())
+		}
+	})
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Compare(missing[j]) < 0
+	})
+
+	removed := []fieldpath.Path{}
+	for _, p := range missing {
+		if n := len(removed); n > 0 && isWithin(p, removed[n-1]) {
+			continue
+		}
+		removed = append(removed, p)
+	}
+
+	return removed, nil
+}
+
+// appliedFields returns the fields that the field manager applied to the
+// object, or nil if the field manager hasn't applied the object.
+func appliedFields(obj metav1.Object, manager string) (*fieldpath.Set, error) {
+	for _, v := range obj.GetManagedFields() {
+		if v.Manager != manager || v.Operation != metav1.ManagedFieldsOperationApply || v.FieldsV1 == nil {
+			continue
+		}
+		applied := &fieldpath.Set{}
+		if err := applied.FromJSON(bytes.NewReader(v.FieldsV1.Raw)); err != nil {
+			return nil, fmt.Errorf("failed to parse the fields applied by %s: %w", manager, err)
+		}
+		return applied, nil
+	}
+
+	return nil, nil
+}
+
+// fieldValue returns the value at the path in an unstructured object.
+func fieldValue(obj any, path fieldpath.Path) (any, bool) {
+	current := obj
+	for _, pe := range path {
+		switch {
+		case pe.FieldName != nil:
+			fields, ok := current.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if current, ok = fields[*pe.FieldName]; !ok {
+				return nil, false
+			}
+		case pe.Index != nil:
+			items, ok := current.([]any)
+			if !ok || *pe.Index >= len(items) {
+				return nil, false
+			}
+			current = items[*pe.Index]
+		default:
+			items, ok := current.([]any)
+			if !ok {
+				return nil, false
+			}
+			if current, ok = findListItem(items, pe); !ok {
+				return nil, false
+			}
+		}
+	}
+
+	return current, true
+}
+
+// findListItem returns the item in a list identified by its keys, or by its
+// value for lists that are sets.
+func findListItem(items []any, pe fieldpath.PathElement) (any, bool) {
+	for _, item := range items {
+		if pe.Value != nil {
+			if value.Equals(value.NewValueInterface(item), *pe.Value) {
+				return item, true
+			}
+			continue
+		}
+		fields, ok := item.(map[string]any)
+		if !ok || pe.Key == nil {
+			continue
+		}
+		matches := true
+		for _, key := range *pe.Key {
+			v, ok := fields[key.Name]
+			if !ok || !value.Equals(value.NewValueInterface(v), key.Value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return item, true
+		}
+	}
+
+	return nil, false
+}
+
+func isWithin(p, parent fieldpath.Path) bool {
+	return len(p) > len(parent) && p[:len(parent)].Equals(parent)
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// planDryRun summarises the changes that reconciling the generated resources
+// would make.
+//
+// Resources that are not in the inventory are created if they don't exist,
+// otherwise they are reported as adopted or refused depending on the
+// adoption policy.
+//
+// All out of date resources are reported as updates, and all the resources
+// that are no longer generated as deletions, regardless of the rollout
+// strategy and sync policy.
+func planDryRun(kustomizationSet *kustomizesetv1.KustomizationSet, created, existing []*existingResource, deletions []kustomizesetv1.ResourceRef) (*kustomizesetv1.DryRunSummary, error) {
+	summary := &kustomizesetv1.DryRunSummary{Delete: deletions}
+	for _, resource := range created {
+		ref, err := resourceRefFromUnstructured(resource.generated)
+		if err != nil {
+			return nil, err
+		}
+		if resource.existing == nil {
+			summary.Create = append(summary.Create, ref)
+			continue
+		}
+		if reason := adoptionRefusal(kustomizationSet, resource.existing); reason != "" {
+			summary.Refuse = append(summary.Refuse, kustomizesetv1.DryRunRefusal{ResourceRef: ref, Reason: reason})
+			continue
+		}
+		changes, err := fieldChanges(resource.generated, resource.existing)
+		if err != nil {
+			return nil, err
+		}
+		summary.Adopt = append(summary.Adopt, kustomizesetv1.DryRunUpdate{ResourceRef: ref, Changes: changes})
+	}
+
+	for _, resource := range existing {
+		if !resource.outOfDate {
+			continue
+		}
+		ref, err := resourceRefFromUnstructured(resource.generated)
+		if err != nil {
+			return nil, err
+		}
+		changes, err := fieldChanges(resource.generated, resource.existing)
+		if err != nil {
+			return nil, err
+		}
+		summary.Update = append(summary.Update, kustomizesetv1.DryRunUpdate{ResourceRef: ref, Changes: changes})
+	}
+
+	return summary, nil
+}
+
+// fieldChanges returns the fields of the generated resource that differ from
+// the existing resource.
+//
+// Like isOutOfDate, fields that are only set in the existing resource are
+// ignored unless the controller applied them, and only the labels and
+// annotations are compared from the metadata.
+func fieldChanges(generated, existing *unstructured.Unstructured) ([]kustomizesetv1.FieldChange, error) {
+	changes := []kustomizesetv1.FieldChange{}
+	removed, err := removedFields(generated, existing)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range removed {
+		path := strings.TrimPrefix(p.String(), ".")
+		existingValue, _ := fieldValue(existing.Object, p)
+		oldValue, err := json.Marshal(existingValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode field %s: %w", path, err)
+		}
+		changes = append(changes, kustomizesetv1.FieldChange{Path: path, Old: string(oldValue)})
+	}
+
+	for k, v := range generated.Object {
+		switch k {
+		case "apiVersion", "kind", "status":
+			continue
+		case "metadata":
+			for _, field := range []string{"labels", "annotations"} {
+				generatedValue, ok, _ := unstructured.NestedFieldNoCopy(generated.Object, "metadata", field)
+				if !ok {
+					continue
+				}
+				existingValue, _, _ := unstructured.NestedFieldNoCopy(existing.Object, "metadata", field)
+				if err := appendFieldChanges(&changes, "metadata."+field, generatedValue, existingValue); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := appendFieldChanges(&changes, k, v, existing.Object[k]); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+
+	return changes, nil
+}
+
+// appendFieldChanges compares maps field by field, and all other values
+// (including lists) as a whole.
+func appendFieldChanges(changes *[]kustomizesetv1.FieldChange, path string, generated, existing any) error {
+	if generatedMap, ok := generated.(map[string]any); ok {
+		existingMap, _ := existing.(map[string]any)
+		for k, v := range generatedMap {
+			if err := appendFieldChanges(changes, path+"."+k, v, existingMap[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if existing != nil && equality.Semantic.DeepDerivative(generated, existing) {
+		return nil
+	}
+
+	change := kustomizesetv1.FieldChange{Path: path}
+	newValue, err := json.Marshal(generated)
+	if err != nil {
+		return fmt.Errorf("failed to encode field %s: %w", path, err)
+	}
+	change.New = string(newValue)
+	if existing != nil {
+		oldValue, err := json.Marshal(existing)
+		if err != nil {
+			return fmt.Errorf("failed to encode field %s: %w", path, err)
+		}
+		change.Old = string(oldValue)
+	}
+	*changes = append(*changes, change)
+
+	return nil
+}
+
+// dryRunMessage summarises the DryRunSummary for the Ready condition.
+func dryRunMessage(summary *kustomizesetv1.DryRunSummary) string {
+	message := fmt.Sprintf("dry run: %d to create, %d to adopt, %d to update, %d to delete",
+		len(summary.Create), len(summary.Adopt), len(summary.Update), len(summary.Delete))
+	if len(summary.Refuse) > 0 {
+		message += fmt.Sprintf(", %d already exist and would not be adopted", len(summary.Refuse))
+	}
+
+	return message
+}
+
+func resourceRefFromUnstructured(u *unstructured.Unstructured) (kustomizesetv1.ResourceRef, error) {
+	objMeta, err := object.RuntimeToObjMeta(u)
+	if err != nil {
+		return kustomizesetv1.ResourceRef{}, fmt.Errorf("failed to identify %s: %w", u.GetKind(), err)
+	}
+
+	return kustomizesetv1.ResourceRef{
+		ID:      objMeta.String(),
+		Version: u.GroupVersionKind().GroupVersion().String(),
+	}, nil
+}
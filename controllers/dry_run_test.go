@@ -0,0 +1,185 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	sourcev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+func TestFieldChanges(t *testing.T) {
+	existing := newTestUnstructured("test", map[string]string{"env": "dev"})
+	existing.SetAnnotations(map[string]string{"testing": "existing"})
+	existing.Object["spec"] = map[string]any{"path": "./dev", "force": false, "components": []any{"a"}}
+
+	changeTests := []struct {
+		name   string
+		labels map[string]string
+		spec   map[string]any
+		want   []sourcev1alpha1.FieldChange
+	}{
+		{
+			name:   "matching fields",
+			labels: map[string]string{"env": "dev"},
+			spec:   map[string]any{"path": "./dev"},
+			want:   []sourcev1alpha1.FieldChange{},
+		},
+		{
+			name:   "changed fields",
+			labels: map[string]string{"env": "prod"},
+			spec:   map[string]any{"path": "./prod", "components": []any{"a", "b"}},
+			want: []sourcev1alpha1.FieldChange{
+				{Path: "metadata.labels.env", Old: `"dev"`, New: `"prod"`},
+				{Path: "spec.components", Old: `["a"]`, New: `["a","b"]`},
+				{Path: "spec.path", Old: `"./dev"`, New: `"./prod"`},
+			},
+		},
+		{
+			name:   "new field",
+			labels: map[string]string{"env": "dev"},
+			spec:   map[string]any{"path": "./dev", "prune": true},
+			want: []sourcev1alpha1.FieldChange{
+				{Path: "spec.prune", New: `true`},
+			},
+		},
+	}
+
+	for _, tt := range changeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated := newTestUnstructured("test", tt.labels)
+			generated.Object["spec"] = tt.spec
+
+			changes, err := fieldChanges(generated, existing)
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.want, changes); diff != "" {
+				t.Fatalf("failed to get changes:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFieldChanges_removed_fields(t *testing.T) {
+	existing := newTestUnstructured("test", map[string]string{"env": "dev", "team": "a"})
+	existing.Object["spec"] = map[string]any{
+		"path":      "./dev",
+		"force":     false,
+		"postBuild": map[string]any{"substitute": map[string]any{"cluster": "dev"}},
+	}
+	setAppliedFields(existing, fieldManager, `{"f:metadata":{"f:labels":{"f:env":{},"f:team":{}}},"f:spec":{"f:path":{},"f:postBuild":{"f:substitute":{".":{},"f:cluster":{}}}}}`)
+	generated := newTestUnstructured("test", map[string]string{"env": "dev"})
+	generated.Object["spec"] = map[string]any{"path": "./dev"}
+
+	changes, err := fieldChanges(generated, existing)
+	test.AssertNoError(t, err)
+
+	want := []sourcev1alpha1.FieldChange{
+		{Path: "metadata.labels.team", Old: `"a"`},
+		{Path: "spec.postBuild.substitute", Old: `{"cluster":"dev"}`},
+	}
+	if diff := cmp.Diff(want, changes); diff != "" {
+		t.Fatalf("failed to get changes:\n%s", diff)
+	}
+}
+
+func TestPlanDryRun(t *testing.T) {
+	ks := newKustomizationSet()
+	created := &existingResource{generated: newTestUnstructured("created", nil)}
+	setLabels := map[string]string{sourcev1alpha1.SetNameLabel: "demo-set", sourcev1alpha1.SetNamespaceLabel: "default"}
+	adopted := &existingResource{
+		generated: newTestUnstructured("adopted", map[string]string{"env": "dev"}),
+		existing:  newTestUnstructured("adopted", setLabels),
+	}
+	refused := &existingResource{
+		generated: newTestUnstructured("refused", nil),
+		existing:  newTestUnstructured("refused", nil),
+	}
+	unchanged := newTestResource("unchanged", "dev", false, true)
+	changed := newTestResource("changed", "dev", true, true)
+	changed.generated.SetLabels(map[string]string{"env": "prod"})
+	deleted := sourcev1alpha1.ResourceRef{
+		ID:      "default_deleted_kustomize.toolkit.fluxcd.io_Kustomization",
+		Version: "kustomize.toolkit.fluxcd.io/v1beta2",
+	}
+
+	summary, err := planDryRun(ks, []*existingResource{created, adopted, refused}, []*existingResource{unchanged, changed}, []sourcev1alpha1.ResourceRef{deleted})
+	test.AssertNoError(t, err)
+
+	want := &sourcev1alpha1.DryRunSummary{
+		Create: []sourcev1alpha1.ResourceRef{
+			{ID: "default_created_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2"},
+		},
+		Adopt: []sourcev1alpha1.DryRunUpdate{
+			{
+				ResourceRef: sourcev1alpha1.ResourceRef{ID: "default_adopted_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2"},
+				Changes: []sourcev1alpha1.FieldChange{
+					{Path: "metadata.labels.env", New: `"dev"`},
+				},
+			},
+		},
+		Refuse: []sourcev1alpha1.DryRunRefusal{
+			{
+				ResourceRef: sourcev1alpha1.ResourceRef{ID: "default_refused_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2"},
+				Reason:      "is not managed by this KustomizationSet",
+			},
+		},
+		Update: []sourcev1alpha1.DryRunUpdate{
+			{
+				ResourceRef: sourcev1alpha1.ResourceRef{ID: "default_changed_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2"},
+				Changes: []sourcev1alpha1.FieldChange{
+					{Path: "metadata.labels.env", Old: `"dev"`, New: `"prod"`},
+				},
+			},
+		},
+		Delete: []sourcev1alpha1.ResourceRef{deleted},
+	}
+	if diff := cmp.Diff(want, summary); diff != "" {
+		t.Fatalf("failed to plan dry run:\n%s", diff)
+	}
+	wantMsg := "dry run: 1 to create, 1 to adopt, 1 to update, 1 to delete, 1 already exist and would not be adopted"
+	if msg := dryRunMessage(summary); msg != wantMsg {
+		t.Fatalf("got message %q, want %q", msg, wantMsg)
+	}
+}
+
+func TestPlanDryRun_adoption_policy(t *testing.T) {
+	ks := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+		ks.Spec.AdoptionPolicy = sourcev1alpha1.AdoptionPolicyAlways
+	})
+	existing := &existingResource{
+		generated: newTestUnstructured("test", nil),
+		existing:  newTestUnstructured("test", nil),
+	}
+
+	summary, err := planDryRun(ks, []*existingResource{existing}, nil, nil)
+	test.AssertNoError(t, err)
+
+	want := &sourcev1alpha1.DryRunSummary{
+		Adopt: []sourcev1alpha1.DryRunUpdate{
+			{
+				ResourceRef: sourcev1alpha1.ResourceRef{ID: "default_test_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2"},
+				Changes:     []sourcev1alpha1.FieldChange{},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, summary); diff != "" {
+		t.Fatalf("failed to plan dry run:\n%s", diff)
+	}
+}
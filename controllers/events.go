@@ -0,0 +1,97 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+const (
+	// createdReason is the reason for events recorded when a generated
+	// resource is created.
+	createdReason = "Created"
+
+	// updatedReason is the reason for events recorded when a generated
+	// resource is changed.
+	updatedReason = "Updated"
+
+	// deletedReason is the reason for events recorded when a resource that
+	// is no longer generated is deleted.
+	deletedReason = "Deleted"
+
+	// deletionDeferredReason is the reason for events recorded when the
+	// SyncPolicy prevents resources that are no longer generated from being
+	// deleted.
+	deletionDeferredReason = "DeletionDeferred"
+)
+
+// recordResourceEvent records a Normal event on the KustomizationSet for a
+// change to a generated resource.
+func (r *KustomizationSetReconciler) recordResourceEvent(kustomizationSet *kustomizesetv1.KustomizationSet, reason string, obj client.Object, kind string) {
+	r.EventRecorder.Eventf(kustomizationSet, corev1.EventTypeNormal, reason,
+		"%s %s %s", kind, client.ObjectKeyFromObject(obj), strings.ToLower(reason))
+}
+
+// recordErrorEvent records a Warning event on the KustomizationSet when
+// reconciling fails.
+func (r *KustomizationSetReconciler) recordErrorEvent(obj runtime.Object, reason string, err error) {
+	r.EventRecorder.Eventf(obj, corev1.EventTypeWarning, reason, "%s", err)
+}
+
+// recordDeferredDeletions records a Warning event on the KustomizationSet
+// when the SyncPolicy defers the deletion of resources that weren't already
+// pending deletion.
+//
+// Resources stay pending across reconciliations, and are only reported when
+// they are first deferred, deletions blocked by the maximum number of
+// deletions are reported when the blocked deletions change.
+func (r *KustomizationSetReconciler) recordDeferredDeletions(kustomizationSet *kustomizesetv1.KustomizationSet, plan *deletionPlan) {
+	blocked := map[string]bool{}
+	if message := blockedDeletionsMessage(plan); message != "" {
+		for _, v := range plan.blocked {
+			blocked[v.ID] = true
+		}
+		condition := apimeta.FindStatusCondition(kustomizationSet.Status.Conditions, kustomizesetv1.DeletionsBlockedCondition)
+		if condition == nil || condition.Message != message {
+			r.EventRecorder.Eventf(kustomizationSet, corev1.EventTypeWarning, kustomizesetv1.MaxDeletionsExceededReason, "%s", message)
+		}
+	}
+
+	previous := map[string]bool{}
+	for _, v := range kustomizationSet.Status.PendingDeletions {
+		previous[v.ID] = true
+	}
+	deferred := []string{}
+	for _, v := range plan.pending {
+		if !previous[v.ID] && !blocked[v.ID] {
+			deferred = append(deferred, v.ID)
+		}
+	}
+	if len(deferred) == 0 {
+		return
+	}
+
+	r.EventRecorder.Eventf(kustomizationSet, corev1.EventTypeWarning, deletionDeferredReason,
+		"sync policy deferred the deletion of %d resources that are no longer generated: %s", len(deferred), strings.Join(deferred, ", "))
+}
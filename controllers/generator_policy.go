@@ -0,0 +1,52 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// policyAllowsUpdate returns true if resources generated with the generator
+// policy can be updated.
+//
+// Resources recorded before generators had a policy are synced.
+func policyAllowsUpdate(policy string) bool {
+	return policy != kustomizesetv1.GeneratorPolicyCreateOnly
+}
+
+// policyAllowsDeletion returns true if resources generated with the generator
+// policy can be deleted when they are no longer generated.
+func policyAllowsDeletion(policy string) bool {
+	return policy == "" || policy == kustomizesetv1.GeneratorPolicySync
+}
+
+// splitByDeletionPolicy splits the resources that are no longer generated
+// into those that can be deleted, and those that are left in place because
+// of the policy of the generator that generated them.
+func splitByDeletionPolicy(refs []kustomizesetv1.ResourceRef) (deletable, retained []kustomizesetv1.ResourceRef) {
+	deletable = []kustomizesetv1.ResourceRef{}
+	retained = []kustomizesetv1.ResourceRef{}
+	for _, ref := range refs {
+		if policyAllowsDeletion(ref.Policy) {
+			deletable = append(deletable, ref)
+			continue
+		}
+		retained = append(retained, ref)
+	}
+
+	return deletable, retained
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	sourcev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+func TestGeneratorPolicy(t *testing.T) {
+	policyTests := []struct {
+		policy      string
+		allowUpdate bool
+		allowDelete bool
+	}{
+		{"", true, true},
+		{sourcev1alpha1.GeneratorPolicySync, true, true},
+		{sourcev1alpha1.GeneratorPolicyCreateUpdate, true, false},
+		{sourcev1alpha1.GeneratorPolicyCreateOnly, false, false},
+	}
+
+	for _, tt := range policyTests {
+		t.Run(tt.policy, func(t *testing.T) {
+			if got := policyAllowsUpdate(tt.policy); got != tt.allowUpdate {
+				t.Errorf("policyAllowsUpdate() got %v, want %v", got, tt.allowUpdate)
+			}
+			if got := policyAllowsDeletion(tt.policy); got != tt.allowDelete {
+				t.Errorf("policyAllowsDeletion() got %v, want %v", got, tt.allowDelete)
+			}
+		})
+	}
+}
+
+func TestSplitByDeletionPolicy(t *testing.T) {
+	refs := []sourcev1alpha1.ResourceRef{
+		{ID: "default_legacy_kustomize.toolkit.fluxcd.io_Kustomization"},
+		{ID: "default_synced_kustomize.toolkit.fluxcd.io_Kustomization", Policy: sourcev1alpha1.GeneratorPolicySync},
+		{ID: "default_updated_kustomize.toolkit.fluxcd.io_Kustomization", Policy: sourcev1alpha1.GeneratorPolicyCreateUpdate},
+		{ID: "default_created_kustomize.toolkit.fluxcd.io_Kustomization", Policy: sourcev1alpha1.GeneratorPolicyCreateOnly},
+	}
+
+	deletable, retained := splitByDeletionPolicy(refs)
+
+	if diff := cmp.Diff(refs[:2], deletable); diff != "" {
+		t.Errorf("failed to get deletable resources:\n%s", diff)
+	}
+	if diff := cmp.Diff(refs[2:], retained); diff != "" {
+		t.Errorf("failed to get retained resources:\n%s", diff)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/pkg/apis/meta"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+var (
+	defaultMinReady  = intstr.FromString("100%")
+	defaultMaxFailed = intstr.FromInt(0)
+)
+
+// healthReconciler refreshes the health of the generated Kustomizations in
+// the status of a KustomizationSet when they change, without generating the
+// resources again.
+type healthReconciler struct {
+	*KustomizationSetReconciler
+}
+
+// Reconcile updates the status of the KustomizationSet from the generated
+// Kustomizations recorded in its inventory.
+func (r *healthReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("kustomizationset", req.Name, "namespace", req.Namespace)
+	var kustomizationSet kustomizesetv1.KustomizationSet
+	if err := r.Client.Get(ctx, req.NamespacedName, &kustomizationSet); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !canRefreshHealth(&kustomizationSet) {
+		return ctrl.Result{}, nil
+	}
+
+	updated, err := r.updateHealth(ctx, kustomizationSet, kustomizationSet.Status.Inventory)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if equality.Semantic.DeepEqual(kustomizationSet.Status, updated.Status) {
+		return ctrl.Result{}, nil
+	}
+	logger.Info("refreshing health of generated kustomizations")
+
+	return ctrl.Result{}, r.Status().Update(ctx, &updated)
+}
+
+// canRefreshHealth returns true if the current generation of the
+// KustomizationSet was applied and its Ready condition reflects the health
+// of the generated Kustomizations.
+//
+// Failures, dry runs and suspended or deleted sets are left to the
+// KustomizationSetReconciler.
+func canRefreshHealth(kustomizationSet *kustomizesetv1.KustomizationSet) bool {
+	if !kustomizationSet.ObjectMeta.DeletionTimestamp.IsZero() || kustomizationSet.Spec.Suspend || kustomizationSet.Spec.DryRun {
+		return false
+	}
+	if kustomizationSet.Status.Inventory == nil || kustomizationSet.Status.ObservedGeneration != kustomizationSet.Generation {
+		return false
+	}
+	ready := apimeta.FindStatusCondition(kustomizationSet.Status.Conditions, meta.ReadyCondition)
+	if ready == nil {
+		return false
+	}
+	switch ready.Reason {
+	case kustomizesetv1.HealthyCondition, meta.ProgressingReason, kustomizesetv1.KustomizationsFailedReason:
+		return true
+	}
+
+	return false
+}
+
+// kustomizationsHealth loads the generated Kustomizations recorded in the
+// inventory and returns their status, other generated resources are ignored.
+func (r *KustomizationSetReconciler) kustomizationsHealth(ctx context.Context, inventory *kustomizesetv1.ResourceInventory) ([]kustomizesetv1.GeneratedKustomizationStatus, kustomizesetv1.KustomizationSetSummary, error) {
+	var summary kustomizesetv1.KustomizationSetSummary
+	if inventory == nil {
+		return nil, summary, nil
+	}
+
+	statuses := []kustomizesetv1.GeneratedKustomizationStatus{}
+	for _, ref := range inventory.Entries {
+		if !isKustomizationRef(ref) {
+			continue
+		}
+		u, err := unstructuredFromResourceRef(ref)
+		if err != nil {
+			return nil, summary, err
+		}
+		status := kustomizesetv1.GeneratedKustomizationStatus{
+			Name:      u.GetName(),
+			Namespace: u.GetNamespace(),
+		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(u), u); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, summary, fmt.Errorf("failed to load Kustomization %s: %w", client.ObjectKeyFromObject(u), err)
+			}
+			status.Status = kustomizesetv1.KustomizationProgressingStatus
+			status.Message = "Kustomization not found"
+		} else {
+			status, err = kustomizationHealth(u)
+			if err != nil {
+				return nil, summary, err
+			}
+		}
+
+		switch status.Status {
+		case kustomizesetv1.KustomizationReadyStatus:
+			summary.Ready++
+		case kustomizesetv1.KustomizationFailedStatus:
+			summary.Failed++
+		default:
+			summary.Progressing++
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, summary, nil
+}
+
+// kustomizationHealth determines the status of a Kustomization from its Ready
+// condition.
+//
+// Kustomizations are Progressing until the kustomize-controller has reported
+// the result of reconciling the current generation, or while they are
+// waiting for their dependencies.
+func kustomizationHealth(u *unstructured.Unstructured) (kustomizesetv1.GeneratedKustomizationStatus, error) {
+	health := kustomizesetv1.GeneratedKustomizationStatus{
+		Name:      u.GetName(),
+		Namespace: u.GetNamespace(),
+		Status:    kustomizesetv1.KustomizationProgressingStatus,
+	}
+
+	var status kustomizev1.KustomizationStatus
+	rawStatus, ok, err := unstructured.NestedMap(u.Object, "status")
+	if err != nil {
+		return health, fmt.Errorf("failed to read status of Kustomization %s: %w", client.ObjectKeyFromObject(u), err)
+	}
+	if ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(rawStatus, &status); err != nil {
+			return health, fmt.Errorf("failed to parse status of Kustomization %s: %w", client.ObjectKeyFromObject(u), err)
+		}
+	}
+	health.LastAppliedRevision = status.LastAppliedRevision
+
+	ready := apimeta.FindStatusCondition(status.Conditions, meta.ReadyCondition)
+	if ready == nil {
+		health.Message = "waiting for Kustomization to be reconciled"
+		return health, nil
+	}
+	health.Message = ready.Message
+	if status.ObservedGeneration < u.GetGeneration() {
+		return health, nil
+	}
+
+	switch ready.Status {
+	case "True":
+		health.Status = kustomizesetv1.KustomizationReadyStatus
+	case "False":
+		if ready.Reason != meta.DependencyNotReadyReason {
+			health.Status = kustomizesetv1.KustomizationFailedStatus
+		}
+	}
+
+	return health, nil
+}
+
+// evaluateHealth compares the summary of the generated Kustomizations with
+// the thresholds configured for the KustomizationSet, and returns the status
+// of the set, one of Ready, Progressing or Failed.
+func evaluateHealth(kustomizationSet *kustomizesetv1.KustomizationSet, summary kustomizesetv1.KustomizationSetSummary) (string, error) {
+	minReady, maxFailed := &defaultMinReady, &defaultMaxFailed
+	if hc := kustomizationSet.Spec.HealthCheck; hc != nil {
+		if hc.MinReady != nil {
+			minReady = hc.MinReady
+		}
+		if hc.MaxFailed != nil {
+			maxFailed = hc.MaxFailed
+		}
+	}
+
+	total := summary.Total()
+	wantReady, err := intstr.GetScaledValueFromIntOrPercent(minReady, total, true)
+	if err != nil {
+		return "", fmt.Errorf("invalid minReady %s: %w", minReady.String(), err)
+	}
+	allowedFailures, err := intstr.GetScaledValueFromIntOrPercent(maxFailed, total, false)
+	if err != nil {
+		return "", fmt.Errorf("invalid maxFailed %s: %w", maxFailed.String(), err)
+	}
+
+	switch {
+	case summary.Failed > allowedFailures:
+		return kustomizesetv1.KustomizationFailedStatus, nil
+	case summary.Ready >= wantReady:
+		return kustomizesetv1.KustomizationReadyStatus, nil
+	}
+
+	return kustomizesetv1.KustomizationProgressingStatus, nil
+}
+
+func isKustomizationRef(ref kustomizesetv1.ResourceRef) bool {
+	return strings.HasSuffix(ref.ID, "_"+kustomizev1.GroupVersion.Group+"_"+kustomizev1.KustomizationKind)
+}
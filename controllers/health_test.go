@@ -0,0 +1,202 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	sourcev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+func TestEvaluateHealth(t *testing.T) {
+	healthTests := []struct {
+		name        string
+		healthCheck *sourcev1alpha1.KustomizationSetHealthCheck
+		summary     sourcev1alpha1.KustomizationSetSummary
+		want        string
+	}{
+		{
+			name:    "all ready",
+			summary: sourcev1alpha1.KustomizationSetSummary{Ready: 3},
+			want:    sourcev1alpha1.KustomizationReadyStatus,
+		},
+		{
+			name:    "no kustomizations",
+			summary: sourcev1alpha1.KustomizationSetSummary{},
+			want:    sourcev1alpha1.KustomizationReadyStatus,
+		},
+		{
+			name:    "some progressing",
+			summary: sourcev1alpha1.KustomizationSetSummary{Ready: 2, Progressing: 1},
+			want:    sourcev1alpha1.KustomizationProgressingStatus,
+		},
+		{
+			name:    "some failed",
+			summary: sourcev1alpha1.KustomizationSetSummary{Ready: 2, Failed: 1},
+			want:    sourcev1alpha1.KustomizationFailedStatus,
+		},
+		{
+			name: "min ready percentage is met",
+			healthCheck: &sourcev1alpha1.KustomizationSetHealthCheck{
+				MinReady: intOrStringPtr(intstr.FromString("60%")),
+			},
+			summary: sourcev1alpha1.KustomizationSetSummary{Ready: 3, Progressing: 2},
+			want:    sourcev1alpha1.KustomizationReadyStatus,
+		},
+		{
+			name: "min ready percentage is rounded up",
+			healthCheck: &sourcev1alpha1.KustomizationSetHealthCheck{
+				MinReady: intOrStringPtr(intstr.FromString("50%")),
+			},
+			summary: sourcev1alpha1.KustomizationSetSummary{Ready: 1, Progressing: 2},
+			want:    sourcev1alpha1.KustomizationProgressingStatus,
+		},
+		{
+			name: "failures within max failed",
+			healthCheck: &sourcev1alpha1.KustomizationSetHealthCheck{
+				MinReady:  intOrStringPtr(intstr.FromInt(2)),
+				MaxFailed: intOrStringPtr(intstr.FromInt(1)),
+			},
+			summary: sourcev1alpha1.KustomizationSetSummary{Ready: 2, Failed: 1},
+			want:    sourcev1alpha1.KustomizationReadyStatus,
+		},
+		{
+			name: "max failed percentage is rounded down",
+			healthCheck: &sourcev1alpha1.KustomizationSetHealthCheck{
+				MaxFailed: intOrStringPtr(intstr.FromString("40%")),
+			},
+			summary: sourcev1alpha1.KustomizationSetSummary{Ready: 1, Failed: 1},
+			want:    sourcev1alpha1.KustomizationFailedStatus,
+		},
+	}
+
+	for _, tt := range healthTests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+				ks.Spec.HealthCheck = tt.healthCheck
+			})
+
+			got, err := evaluateHealth(ks, tt.summary)
+			test.AssertNoError(t, err)
+
+			if got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKustomizationHealth(t *testing.T) {
+	healthTests := []struct {
+		name   string
+		status map[string]any
+		want   sourcev1alpha1.GeneratedKustomizationStatus
+	}{
+		{
+			name: "no status",
+			want: sourcev1alpha1.GeneratedKustomizationStatus{
+				Status:  sourcev1alpha1.KustomizationProgressingStatus,
+				Message: "waiting for Kustomization to be reconciled",
+			},
+		},
+		{
+			name:   "ready",
+			status: kustomizationStatus(2, "True", "ReconciliationSucceeded", "main@sha1:a"),
+			want: sourcev1alpha1.GeneratedKustomizationStatus{
+				Status:              sourcev1alpha1.KustomizationReadyStatus,
+				Message:             "testing",
+				LastAppliedRevision: "main@sha1:a",
+			},
+		},
+		{
+			name:   "ready with an earlier generation",
+			status: kustomizationStatus(1, "True", "ReconciliationSucceeded", "main@sha1:a"),
+			want: sourcev1alpha1.GeneratedKustomizationStatus{
+				Status:              sourcev1alpha1.KustomizationProgressingStatus,
+				Message:             "testing",
+				LastAppliedRevision: "main@sha1:a",
+			},
+		},
+		{
+			name:   "failed",
+			status: kustomizationStatus(2, "False", "ReconciliationFailed", ""),
+			want: sourcev1alpha1.GeneratedKustomizationStatus{
+				Status:  sourcev1alpha1.KustomizationFailedStatus,
+				Message: "testing",
+			},
+		},
+		{
+			name:   "waiting for dependencies",
+			status: kustomizationStatus(2, "False", "DependencyNotReady", ""),
+			want: sourcev1alpha1.GeneratedKustomizationStatus{
+				Status:  sourcev1alpha1.KustomizationProgressingStatus,
+				Message: "testing",
+			},
+		},
+		{
+			name:   "reconciling",
+			status: kustomizationStatus(2, "Unknown", "Progressing", ""),
+			want: sourcev1alpha1.GeneratedKustomizationStatus{
+				Status:  sourcev1alpha1.KustomizationProgressingStatus,
+				Message: "testing",
+			},
+		},
+	}
+
+	for _, tt := range healthTests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{Object: map[string]any{}}
+			u.SetAPIVersion("kustomize.toolkit.fluxcd.io/v1")
+			u.SetKind("Kustomization")
+			u.SetName("test-kustomization")
+			u.SetNamespace("default")
+			u.SetGeneration(2)
+			if tt.status != nil {
+				u.Object["status"] = tt.status
+			}
+
+			got, err := kustomizationHealth(u)
+			test.AssertNoError(t, err)
+
+			tt.want.Name = "test-kustomization"
+			tt.want.Namespace = "default"
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("failed to get health:\n%s", diff)
+			}
+		})
+	}
+}
+
+func kustomizationStatus(generation int64, status, reason, revision string) map[string]any {
+	return map[string]any{
+		"observedGeneration":  generation,
+		"lastAppliedRevision": revision,
+		"conditions": []any{
+			map[string]any{
+				"type":               "Ready",
+				"status":             status,
+				"reason":             reason,
+				"message":            "testing",
+				"lastTransitionTime": "2022-11-01T10:00:00Z",
+			},
+		},
+	}
+}
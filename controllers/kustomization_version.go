@@ -0,0 +1,49 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// DetectKustomizationVersion queries the cluster for the versions of the
+// Kustomization API that it serves, and returns v1 if it's available,
+// falling back to v1beta2.
+func DetectKustomizationVersion(dc discovery.DiscoveryInterface) (string, error) {
+	gv := kustomizev1.GroupVersion.Group + "/" + kustomizesetv1.KustomizationVersionV1
+	resources, err := dc.ServerResourcesForGroupVersion(gv)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return kustomizesetv1.KustomizationVersionV1Beta2, nil
+		}
+		return "", fmt.Errorf("failed to discover resources for %s: %w", gv, err)
+	}
+
+	for _, v := range resources.APIResources {
+		if v.Kind == kustomizev1.KustomizationKind {
+			return kustomizesetv1.KustomizationVersionV1, nil
+		}
+	}
+
+	return kustomizesetv1.KustomizationVersionV1Beta2, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestDetectKustomizationVersion(t *testing.T) {
+	versionTests := []struct {
+		name      string
+		resources []*metav1.APIResourceList
+		want      string
+	}{
+		{
+			name: "v1 is served",
+			resources: []*metav1.APIResourceList{
+				kustomizationResources("kustomize.toolkit.fluxcd.io/v1"),
+				kustomizationResources("kustomize.toolkit.fluxcd.io/v1beta2"),
+			},
+			want: "v1",
+		},
+		{
+			name: "only v1beta2 is served",
+			resources: []*metav1.APIResourceList{
+				kustomizationResources("kustomize.toolkit.fluxcd.io/v1beta2"),
+			},
+			want: "v1beta2",
+		},
+		{
+			name: "v1 is served without Kustomizations",
+			resources: []*metav1.APIResourceList{
+				{GroupVersion: "kustomize.toolkit.fluxcd.io/v1"},
+				kustomizationResources("kustomize.toolkit.fluxcd.io/v1beta2"),
+			},
+			want: "v1beta2",
+		},
+	}
+
+	for _, tt := range versionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: tt.resources}}
+
+			version, err := DetectKustomizationVersion(dc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if version != tt.want {
+				t.Fatalf("got version %s, want %s", version, tt.want)
+			}
+		})
+	}
+}
+
+func kustomizationResources(gv string) *metav1.APIResourceList {
+	return &metav1.APIResourceList{
+		GroupVersion: gv,
+		APIResources: []metav1.APIResource{
+			{Name: "kustomizations", Namespaced: true, Kind: "Kustomization"},
+		},
+	}
+}
@@ -18,31 +18,65 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/runtime/patch"
+	"github.com/fluxcd/pkg/runtime/predicates"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/cli-utils/pkg/object"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/metrics"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/tracing"
 	"github.com/gitops-tools/pkg/sets"
 )
 
 const (
 	gitRepositoryIndexKey string = ".metadata.gitRepository"
+
+	// deletionRequeueInterval is how long to wait before checking whether
+	// the generated resources have been removed when deleting a
+	// KustomizationSet.
+	deletionRequeueInterval = 5 * time.Second
+
+	// dryRunRequeueInterval is how often the changes are computed again for
+	// a dry run, the existing resources can change without the
+	// KustomizationSet changing.
+	dryRunRequeueInterval = 5 * time.Minute
+
+	// fieldManager is the field manager used when applying generated
+	// resources.
+	fieldManager = "kustomizationset-controller"
 )
 
 // KustomizationSetReconciler reconciles a KustomizationSet object
@@ -50,129 +84,806 @@ type KustomizationSetReconciler struct {
 	client.Client
 	Scheme     *runtime.Scheme
 	Generators map[string]generators.Generator
+
+	// KustomizationVersion is the version of the Kustomization API used when
+	// a KustomizationSet doesn't specify a version, if this is empty v1beta2
+	// is used.
+	KustomizationVersion string
+
+	// EventRecorder records events on the KustomizationSet for changes to
+	// the generated resources and failures to reconcile.
+	EventRecorder record.EventRecorder
+
+	// WatchNamespaces are the namespaces that the manager's cache is
+	// restricted to, if this is empty all namespaces are watched.
+	//
+	// Resources can't be generated in namespaces outside the cache, so the
+	// allowedNamespaces of a KustomizationSet must be watched.
+	WatchNamespaces []string
+}
+
+// KustomizationSetReconcilerOptions configures the controller.
+type KustomizationSetReconcilerOptions struct {
+	// MaxConcurrentReconciles is the number of KustomizationSets that can be
+	// reconciled at the same time, this defaults to 1.
+	MaxConcurrentReconciles int
+
+	// RateLimiter limits how often KustomizationSets are requeued after
+	// failures, this defaults to the controller-runtime rate limiter.
+	RateLimiter ratelimiter.RateLimiter
+
+	// CacheSyncTimeout is how long to wait for the caches to sync before
+	// the controller starts, this defaults to two minutes.
+	CacheSyncTimeout time.Duration
 }
 
 //+kubebuilder:rbac:groups=source.gitops.solutions,resources=kustomizationsets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=source.gitops.solutions,resources=kustomizationsets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=source.gitops.solutions,resources=kustomizationsets/finalizers,verbs=update
 //+kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories,verbs=get;list;watch
+//+kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=gitrepositories;ocirepositories;helmrepositories;buckets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *KustomizationSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	logger := log.FromContext(ctx)
+func (r *KustomizationSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, retErr error) {
+	ctx, span := tracing.Start(ctx, "Reconcile",
+		attribute.String("namespace", req.Namespace), attribute.String("name", req.Name))
+	defer func() {
+		tracing.End(span, retErr)
+	}()
+
+	// The namespace is already recorded by the controller.
+	logger := log.FromContext(ctx).WithValues("kustomizationset", req.Name, "namespace", req.Namespace)
+	ctx = log.IntoContext(ctx, logger)
 	var kustomizationSet kustomizesetv1.KustomizationSet
 	if err := r.Client.Get(ctx, req.NamespacedName, &kustomizationSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			metrics.ForgetSet(req.NamespacedName)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	defer recordMetrics(&kustomizationSet)
 
 	logger.Info("kustomization set loaded")
 
 	if !kustomizationSet.ObjectMeta.DeletionTimestamp.IsZero() {
+		return r.finalize(ctx, &kustomizationSet)
+	}
+
+	if !controllerutil.ContainsFinalizer(&kustomizationSet, kustomizesetv1.KustomizationSetFinalizer) {
+		controllerutil.AddFinalizer(&kustomizationSet, kustomizesetv1.KustomizationSetFinalizer)
+		if err := r.Update(ctx, &kustomizationSet); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if kustomizationSet.Spec.Suspend {
+		logger.Info("reconciliation is suspended for this object")
 		return ctrl.Result{}, nil
 	}
 
-	inventory, err := r.reconcileResources(ctx, &kustomizationSet)
+	if v, ok := meta.ReconcileAnnotationValue(kustomizationSet.GetAnnotations()); ok {
+		kustomizationSet.Status.SetLastHandledReconcileRequest(v)
+	}
+
+	if kustomizationSet.Generation != kustomizationSet.Status.ObservedGeneration {
+		kustomizationSet = kustomizesetv1.KustomizationSetProgressing(kustomizationSet)
+		if err := r.Status().Update(ctx, &kustomizationSet); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	result, err := r.reconcileResources(ctx, &kustomizationSet)
 	if err != nil {
+		reason := reasonForError(err)
+		logger.Error(err, "failed to reconcile kustomization set", "reason", reason)
+		r.recordErrorEvent(&kustomizationSet, reason, err)
+		// Invalid templates won't be fixed by retrying, the KustomizationSet
+		// needs to be changed.
+		if isSpecError(&kustomizationSet, err) {
+			kustomizationSet = kustomizesetv1.KustomizationSetStalled(kustomizationSet, reason, err.Error())
+			return ctrl.Result{}, r.Status().Update(ctx, &kustomizationSet)
+		}
+		kustomizationSet = kustomizesetv1.KustomizationSetNotReady(kustomizationSet, reason, err.Error())
+		if updateErr := r.Status().Update(ctx, &kustomizationSet); updateErr != nil {
+			logger.Error(updateErr, "failed to update status")
+		}
 		return ctrl.Result{}, err
 	}
-	if inventory != nil {
-		kustomizationSet = kustomizesetv1.KustomizationSetReady(kustomizationSet, inventory, kustomizesetv1.HealthyCondition, fmt.Sprintf("%d kustomizations created", len(inventory.Entries)))
+	kustomizationSet.Status.DryRun = result.dryRun
+	if result.dryRun != nil {
+		kustomizationSet = kustomizesetv1.KustomizationSetDryRun(kustomizationSet, dryRunMessage(result.dryRun))
 		if err := r.Status().Update(ctx, &kustomizationSet); err != nil {
 			return ctrl.Result{}, err
 		}
+		return ctrl.Result{RequeueAfter: dryRunRequeueInterval}, nil
+	}
+	kustomizationSet.Status.RollingSync = result.rollout
+	kustomizationSet.Status.PendingDeletions = result.pendingDeletions
+	kustomizationSet, err = r.updateHealth(ctx, kustomizationSet, result.inventory)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if result.deletionsBlocked != "" {
+		kustomizationSet = kustomizesetv1.KustomizationSetDeletionsBlocked(kustomizationSet, kustomizesetv1.MaxDeletionsExceededReason, result.deletionsBlocked)
+	} else {
+		apimeta.RemoveStatusCondition(&kustomizationSet.Status.Conditions, kustomizesetv1.DeletionsBlockedCondition)
+	}
+	if err := r.Status().Update(ctx, &kustomizationSet); err != nil {
+		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	return ctrl.Result{RequeueAfter: result.requeueAfter}, nil
 }
 
-func (r *KustomizationSetReconciler) reconcileResources(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet) (*kustomizesetv1.ResourceInventory, error) {
-	kustomizations, err := reconciler.GenerateKustomizations(ctx, kustomizationSet, r.Generators)
+// recordMetrics records the number of generated resources and the Ready
+// status of the KustomizationSet.
+func recordMetrics(kustomizationSet *kustomizesetv1.KustomizationSet) {
+	generated := 0
+	if inv := kustomizationSet.Status.Inventory; inv != nil {
+		generated = len(inv.Entries)
+	}
+	status := metav1.ConditionUnknown
+	if ready := apimeta.FindStatusCondition(kustomizationSet.Status.Conditions, meta.ReadyCondition); ready != nil {
+		status = ready.Status
+	}
+	metrics.RecordSet(client.ObjectKeyFromObject(kustomizationSet), generated, string(status))
+}
+
+// updateHealth records the status of the generated Kustomizations and sets
+// the Ready condition of the KustomizationSet based on the configured health
+// check thresholds.
+func (r *KustomizationSetReconciler) updateHealth(ctx context.Context, kustomizationSet kustomizesetv1.KustomizationSet, inventory *kustomizesetv1.ResourceInventory) (kustomizesetv1.KustomizationSet, error) {
+	statuses, summary, err := r.kustomizationsHealth(ctx, inventory)
+	if err != nil {
+		return kustomizationSet, err
+	}
+	health, err := evaluateHealth(&kustomizationSet, summary)
+	if err != nil {
+		return kustomizationSet, err
+	}
+	kustomizationSet.Status.Kustomizations = statuses
+	kustomizationSet.Status.Summary = &summary
+
+	message := fmt.Sprintf("%d of %d kustomizations ready", summary.Ready, summary.Total())
+	if summary.Total() == 0 {
+		message = fmt.Sprintf("%d resources created", len(inventory.Entries))
+	}
+
+	switch health {
+	case kustomizesetv1.KustomizationFailedStatus:
+		return kustomizesetv1.KustomizationSetUnhealthy(kustomizationSet, inventory, kustomizesetv1.KustomizationsFailedReason,
+			fmt.Sprintf("%d of %d kustomizations failed", summary.Failed, summary.Total())), nil
+	case kustomizesetv1.KustomizationProgressingStatus:
+		return kustomizesetv1.KustomizationSetWaiting(kustomizationSet, inventory, message), nil
+	}
+	if rollout := kustomizationSet.Status.RollingSync; rollout != nil && rollout.CurrentStep != 0 {
+		step := rollout.Steps[rollout.CurrentStep-1]
+		if step.Status == kustomizesetv1.RollingSyncStepBlocked {
+			return kustomizesetv1.KustomizationSetUnhealthy(kustomizationSet, inventory, kustomizesetv1.RolloutBlockedReason,
+				fmt.Sprintf("step %d of %d is blocked by %d failed resources", rollout.CurrentStep, len(rollout.Steps), step.Failed)), nil
+		}
+		return kustomizesetv1.KustomizationSetWaiting(kustomizationSet, inventory,
+			fmt.Sprintf("rolling out step %d of %d", rollout.CurrentStep, len(rollout.Steps))), nil
+	}
+
+	return kustomizesetv1.KustomizationSetReady(kustomizationSet, inventory, kustomizesetv1.HealthyCondition, message), nil
+}
+
+// reconcileResult is the result of reconciling the generated resources.
+type reconcileResult struct {
+	inventory        *kustomizesetv1.ResourceInventory
+	rollout          *kustomizesetv1.RollingSyncStatus
+	pendingDeletions []kustomizesetv1.PendingDeletion
+	requeueAfter     time.Duration
+
+	// deletionsBlocked is the reason that resources that are no longer
+	// generated are not being deleted, if they are blocked.
+	deletionsBlocked string
+
+	// dryRun is the summary of the changes that would be made, when the
+	// KustomizationSet is a dry run no changes are made.
+	dryRun *kustomizesetv1.DryRunSummary
+}
+
+func (r *KustomizationSetReconciler) reconcileResources(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet) (*reconcileResult, error) {
+	if err := r.checkWatchedNamespaces(kustomizationSet); err != nil {
+		return nil, &specError{Err: err}
+	}
+
+	resources, err := reconciler.GenerateResources(ctx, kustomizationSet, r.Generators, reconciler.GenerateOptions{
+		IsNamespaced:         r.isNamespaced,
+		KustomizationVersion: r.KustomizationVersion,
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	existingEntries := sets.New[kustomizesetv1.ResourceRef]()
-	if kustomizationSet.Status.Inventory != nil {
-		existingEntries.Insert(kustomizationSet.Status.Inventory.Entries...)
+	// Entries are compared by ID, the same resource may be recorded with
+	// a different API version.
+	existingEntries := inventoryEntries(kustomizationSet, reconciler.KustomizationVersion(kustomizationSet, r.KustomizationVersion))
+	existingIDs := sets.New[string]()
+	for _, v := range existingEntries {
+		existingIDs.Insert(v.ID)
 	}
 
+	generatedCounts := map[string]int{}
+	for i, gen := range kustomizationSet.Spec.Generators {
+		generatedCounts[reconciler.GeneratorID(i, gen)] = 0
+	}
 	entries := sets.New[kustomizesetv1.ResourceRef]()
-	for _, kustomization := range kustomizations {
-		objMeta, err := object.RuntimeToObjMeta(&kustomization)
+	ids := sets.New[string]()
+	managed := 0
+	newResources := []*unstructured.Unstructured{}
+	existingResources := []*existingResource{}
+	for _, generated := range resources {
+		resource := generated.Resource
+		objMeta, err := object.RuntimeToObjMeta(resource)
 		if err != nil {
 			return nil, fmt.Errorf("failed to update inventory: %w", err)
 		}
+		setOwnerLabels(kustomizationSet, resource)
 		ref := kustomizesetv1.ResourceRef{
-			ID:      objMeta.String(),
-			Version: kustomization.GetObjectKind().GroupVersionKind().GroupVersion().String(),
+			ID:        objMeta.String(),
+			Version:   resource.GroupVersionKind().GroupVersion().String(),
+			Policy:    generated.Policy,
+			Generator: generated.Generator,
 		}
+		generatedCounts[generated.Generator]++
 		entries.Insert(ref)
+		ids.Insert(ref.ID)
 
-		if existingEntries.Has(ref) {
-			existing := &kustomizev1.Kustomization{}
-			if err := r.Client.Get(ctx, types.NamespacedName{Name: kustomization.Name, Namespace: kustomization.Namespace}, existing); err != nil {
-				return nil, fmt.Errorf("failed to load existing Kustomization: %w", err)
-			}
-			patchHelper, err := patch.NewHelper(existing, r.Client)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create patch helper for Kustomization: %w", err)
-			}
-			existing.ObjectMeta.Annotations = kustomization.Annotations
-			existing.ObjectMeta.Labels = kustomization.Labels
-			existing.Spec = kustomization.Spec
-			if err := patchHelper.Patch(ctx, existing); err != nil {
-				return nil, fmt.Errorf("failed to update Kustomization: %w", err)
+		// Owner references can't cross namespaces, resources generated
+		// into other namespaces (or cluster-scoped resources) are tracked by
+		// label and removed through the inventory.
+		if resource.GetNamespace() == kustomizationSet.GetNamespace() {
+			if err := controllerutil.SetControllerReference(kustomizationSet, resource, r.Scheme); err != nil {
+				return nil, fmt.Errorf("failed to set owner reference: %w", err)
 			}
+		}
+
+		if !existingIDs.Has(ref.ID) {
+			newResources = append(newResources, resource)
+			continue
+		}
+		if policyAllowsDeletion(generated.Policy) {
+			managed++
+		}
+		if !policyAllowsUpdate(generated.Policy) {
 			continue
 		}
 
-		controllerutil.SetControllerReference(kustomizationSet, &kustomization, r.Scheme)
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(resource.GroupVersionKind())
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(resource), existing); err != nil {
+			return nil, fmt.Errorf("failed to load existing %s: %w", resource.GetKind(), err)
+		}
+		current, err := newExistingResource(resource, existing)
+		if err != nil {
+			return nil, err
+		}
+		existingResources = append(existingResources, current)
+	}
+
+	// Resources that the generator policy doesn't allow to be deleted
+	// stay in the inventory, so that they are updated if they are
+	// generated again.
+	resourcesToRemove := []kustomizesetv1.ResourceRef{}
+	for _, v := range existingEntries {
+		if !ids.Has(v.ID) {
+			resourcesToRemove = append(resourcesToRemove, v)
+		}
+	}
+	resourcesToRemove, retained := splitByDeletionPolicy(resourcesToRemove)
 
-		if err := r.Client.Create(ctx, &kustomization); err != nil {
-			return nil, fmt.Errorf("failed to create Kustomization: %w", err)
+	if kustomizationSet.Spec.DryRun {
+		created := []*existingResource{}
+		for _, resource := range newResources {
+			existing, err := r.getExisting(ctx, resource)
+			if err != nil {
+				return nil, err
+			}
+			created = append(created, &existingResource{generated: resource, existing: existing})
+		}
+		summary, err := planDryRun(kustomizationSet, created, existingResources, resourcesToRemove)
+		if err != nil {
+			return nil, err
 		}
+		return &reconcileResult{inventory: kustomizationSet.Status.Inventory, dryRun: summary}, nil
 	}
 
-	if kustomizationSet.Status.Inventory == nil {
-		return &kustomizesetv1.ResourceInventory{Entries: entries.SortedList(func(x, y kustomizesetv1.ResourceRef) bool {
-			return x.ID < y.ID
-		})}, nil
+	updates := existingResources
+	var rollout *kustomizesetv1.RollingSyncStatus
+	if strategy := kustomizationSet.Spec.Strategy; strategy != nil && strategy.RollingSync != nil {
+		updates, rollout, err = planRollingSync(strategy.RollingSync, existingResources)
+		if err != nil {
+			return nil, &specError{Err: err}
+		}
+	}
 
+	for _, resource := range newResources {
+		if err := r.createResource(ctx, kustomizationSet, resource); err != nil {
+			return nil, err
+		}
 	}
-	kustomizationsToRemove := existingEntries.Difference(entries)
-	if err := r.removeResourceRefs(ctx, kustomizationsToRemove.List()); err != nil {
-		return nil, err
+
+	for _, update := range updates {
+		if err := r.applyResource(ctx, kustomizationSet, update); err != nil {
+			return nil, err
+		}
 	}
 
-	return &kustomizesetv1.ResourceInventory{Entries: entries.SortedList(func(x, y kustomizesetv1.ResourceRef) bool {
+	result := &reconcileResult{rollout: rollout}
+	if kustomizationSet.Status.Inventory != nil {
+		entries.Insert(retained...)
+		plan, err := planDeletions(kustomizationSet.Spec.SyncPolicy, generatedCounts, managed, resourcesToRemove, kustomizationSet.Status.PendingDeletions,
+			kustomizationSet.GetAnnotations()[kustomizesetv1.ApproveDeletionsAnnotation], time.Now())
+		if err != nil {
+			return nil, &specError{Err: err}
+		}
+		if err := r.removeResourceRefs(ctx, kustomizationSet, plan.deletions); err != nil {
+			return nil, err
+		}
+		r.recordDeferredDeletions(kustomizationSet, plan)
+		for _, v := range plan.pending {
+			entries.Insert(v.ResourceRef)
+		}
+		result.pendingDeletions = plan.pending
+		result.requeueAfter = plan.requeueAfter
+		result.deletionsBlocked = blockedDeletionsMessage(plan)
+	}
+	result.inventory = &kustomizesetv1.ResourceInventory{Entries: entries.SortedList(func(x, y kustomizesetv1.ResourceRef) bool {
 		return x.ID < y.ID
-	})}, nil
+	})}
 
+	return result, nil
+}
+
+// createResource creates a generated resource with server-side apply, if the
+// resource already exists it is adopted if the adoption policy allows it.
+func (r *KustomizationSetReconciler) createResource(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet, resource *unstructured.Unstructured) (err error) {
+	ctx, span := startResourceSpan(ctx, "CreateResource", resource)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	existing, err := r.getExisting(ctx, resource)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := r.adoptResource(ctx, kustomizationSet, resource, existing); err != nil {
+			return fmt.Errorf("failed to create %s: %w", resource.GetKind(), err)
+		}
+		return nil
+	}
+
+	if err := r.Client.Patch(ctx, resource, client.Apply, client.FieldOwner(fieldManager)); err != nil {
+		return fmt.Errorf("failed to create %s: %w", resource.GetKind(), err)
+	}
+	metrics.ResourceChanges.WithLabelValues(metrics.OperationCreate, resource.GetKind()).Inc()
+	r.recordResourceEvent(kustomizationSet, createdReason, resource, resource.GetKind())
+
+	return nil
 }
 
-func (r *KustomizationSetReconciler) removeResourceRefs(ctx context.Context, deletions []kustomizesetv1.ResourceRef) error {
+// checkWatchedNamespaces returns an error if the KustomizationSet allows
+// resources to be generated in namespaces that are not watched.
+func (r *KustomizationSetReconciler) checkWatchedNamespaces(kustomizationSet *kustomizesetv1.KustomizationSet) error {
+	if len(r.WatchNamespaces) == 0 {
+		return nil
+	}
+	watched := sets.New(r.WatchNamespaces...)
+	unwatched := []string{}
+	for _, ns := range kustomizationSet.Spec.AllowedNamespaces {
+		if !watched.Has(ns) {
+			unwatched = append(unwatched, ns)
+		}
+	}
+	if len(unwatched) > 0 {
+		return fmt.Errorf("allowedNamespaces %s are not watched by the controller, must be within %s",
+			strings.Join(unwatched, ", "), strings.Join(r.WatchNamespaces, ", "))
+	}
+
+	return nil
+}
+
+// getExisting loads the existing resource for a generated resource, or
+// returns nil if the resource doesn't exist.
+func (r *KustomizationSetReconciler) getExisting(ctx context.Context, resource *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(resource.GroupVersionKind())
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(resource), existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load existing %s: %w", resource.GetKind(), err)
+	}
+
+	return existing, nil
+}
+
+// specError is returned when the strategy, sync policy or allowed namespaces
+// of the KustomizationSet are invalid.
+type specError struct {
+	Err error
+}
+
+func (e *specError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *specError) Unwrap() error {
+	return e.Err
+}
+
+// isSpecError returns true if the error can only be fixed by changing the
+// KustomizationSet.
+//
+// Templates that fail to render may be fixed by changes to the generated
+// parameters (e.g. a pull request branch name), these are only treated as
+// spec errors if the templates can't be parsed.
+func isSpecError(kustomizationSet *kustomizesetv1.KustomizationSet, err error) bool {
+	var specErr *specError
+	if errors.As(err, &specErr) {
+		return true
+	}
+	var renderErr *reconciler.RenderError
+	if !errors.As(err, &renderErr) {
+		return false
+	}
+
+	return reconciler.ParseTemplates(kustomizationSet) != nil
+}
+
+// reasonForError maps an error from reconciling resources to the reason
+// recorded in the Ready condition.
+func reasonForError(err error) string {
+	var generateErr *reconciler.GenerateError
+	var specErr *specError
+	var renderErr *reconciler.RenderError
+	var duplicateErr *reconciler.DuplicateError
+	var adoptionErr *adoptionRefusedError
+	switch {
+	case errors.Is(err, generators.SourceNotReadyError):
+		return kustomizesetv1.SourceNotReadyReason
+	case errors.As(err, &generateErr):
+		return kustomizesetv1.GenerationFailedReason
+	case errors.As(err, &renderErr), errors.As(err, &specErr):
+		return kustomizesetv1.RenderFailedReason
+	case errors.As(err, &duplicateErr):
+		return kustomizesetv1.DuplicateResourcesReason
+	case apierrors.IsConflict(err):
+		return kustomizesetv1.ApplyConflictReason
+	case errors.As(err, &adoptionErr):
+		return kustomizesetv1.AdoptionRefusedReason
+	}
+
+	return kustomizesetv1.ApplyFailedReason
+}
+
+// removeResourceRefs deletes the resources, resources generated by
+// generators with a policy that doesn't allow deletion are skipped.
+func (r *KustomizationSetReconciler) removeResourceRefs(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet, deletions []kustomizesetv1.ResourceRef) error {
 	for _, v := range deletions {
-		objMeta, err := object.ParseObjMetadata(v.ID)
+		if !policyAllowsDeletion(v.Policy) {
+			continue
+		}
+		u, err := unstructuredFromResourceRef(v)
+		if err != nil {
+			return err
+		}
+		if err := r.deleteResource(ctx, kustomizationSet, u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteResource deletes a resource that is no longer generated, resources
+// that have already been deleted are ignored.
+func (r *KustomizationSetReconciler) deleteResource(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet, u *unstructured.Unstructured) (err error) {
+	ctx, span := startResourceSpan(ctx, "DeleteResource", u)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	err = r.Client.Delete(ctx, u)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", u.GetKind(), client.ObjectKeyFromObject(u), err)
+	}
+	metrics.ResourceChanges.WithLabelValues(metrics.OperationDelete, u.GetKind()).Inc()
+	r.recordResourceEvent(kustomizationSet, deletedReason, u, u.GetKind())
+
+	return nil
+}
+
+func (r *KustomizationSetReconciler) isNamespaced(gvk schema.GroupVersionKind) (bool, error) {
+	mapping, err := r.Client.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, err
+	}
+
+	return mapping.Scope.Name() == apimeta.RESTScopeNameNamespace, nil
+}
+
+// finalize applies the deletion policy of the KustomizationSet to the
+// resources recorded in the inventory and then removes the finalizer from the
+// KustomizationSet.
+//
+// When resources are deleted, the finalizer is only removed once they no
+// longer exist, this allows generated Kustomizations to prune their own
+// resources.
+func (r *KustomizationSetReconciler) finalize(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+	if !controllerutil.ContainsFinalizer(kustomizationSet, kustomizesetv1.KustomizationSetFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	entries := inventoryEntries(kustomizationSet, reconciler.KustomizationVersion(kustomizationSet, r.KustomizationVersion))
+	switch kustomizationSet.Spec.DeletionPolicy {
+	case kustomizesetv1.DeletionPolicyOrphan:
+		if err := r.orphanResourceRefs(ctx, kustomizationSet, entries); err != nil {
+			return ctrl.Result{}, err
+		}
+	default:
+		// Resources are only deleted if the policy of the generator that
+		// generated them allows it, the rest are orphaned.
+		deletable, retained := splitByDeletionPolicy(entries)
+		if err := r.orphanResourceRefs(ctx, kustomizationSet, retained); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.removeResourceRefs(ctx, kustomizationSet, deletable); err != nil {
+			return ctrl.Result{}, err
+		}
+		remaining, err := r.countExistingResourceRefs(ctx, deletable)
 		if err != nil {
-			return fmt.Errorf("failed to parse object ID %s for deletion: %w", v.ID, err)
+			return ctrl.Result{}, err
 		}
-		k := kustomizev1.Kustomization{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      objMeta.Name,
-				Namespace: objMeta.Namespace,
-			},
+		if remaining > 0 {
+			logger.Info("waiting for generated resources to be deleted", "remaining", remaining)
+			return ctrl.Result{RequeueAfter: deletionRequeueInterval}, nil
 		}
-		if err := r.Client.Delete(ctx, &k); err != nil {
-			return fmt.Errorf("failed to delete %v: %w", k, err)
+	}
+
+	controllerutil.RemoveFinalizer(kustomizationSet, kustomizesetv1.KustomizationSetFinalizer)
+
+	return ctrl.Result{}, r.Update(ctx, kustomizationSet)
+}
+
+// orphanResourceRefs removes the owner references and labels that link the
+// resources to the KustomizationSet.
+func (r *KustomizationSetReconciler) orphanResourceRefs(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet, refs []kustomizesetv1.ResourceRef) error {
+	for _, v := range refs {
+		u, err := unstructuredFromResourceRef(v)
+		if err != nil {
+			return err
 		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(u), u); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to load %s %s: %w", u.GetKind(), client.ObjectKeyFromObject(u), err)
+		}
+		patchHelper, err := patch.NewHelper(u, r.Client)
+		if err != nil {
+			return fmt.Errorf("failed to create patch helper for %s: %w", u.GetKind(), err)
+		}
+
+		ownerRefs := []metav1.OwnerReference{}
+		for _, ref := range u.GetOwnerReferences() {
+			if ref.UID != kustomizationSet.GetUID() {
+				ownerRefs = append(ownerRefs, ref)
+			}
+		}
+		u.SetOwnerReferences(ownerRefs)
+		labels := u.GetLabels()
+		delete(labels, kustomizesetv1.SetNameLabel)
+		delete(labels, kustomizesetv1.SetNamespaceLabel)
+		u.SetLabels(labels)
+
+		if err := patchHelper.Patch(ctx, u); err != nil {
+			return fmt.Errorf("failed to orphan %s %s: %w", u.GetKind(), client.ObjectKeyFromObject(u), err)
+		}
+	}
+	return nil
+}
+
+// countExistingResourceRefs returns the number of the resources that still
+// exist in the cluster.
+func (r *KustomizationSetReconciler) countExistingResourceRefs(ctx context.Context, refs []kustomizesetv1.ResourceRef) (int, error) {
+	count := 0
+	for _, v := range refs {
+		u, err := unstructuredFromResourceRef(v)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(u), u); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return 0, fmt.Errorf("failed to load %s %s: %w", u.GetKind(), client.ObjectKeyFromObject(u), err)
+		}
+		count++
 	}
+	return count, nil
+}
+
+// setOwnerLabels records the KustomizationSet that generated a resource in
+// its labels.
+func setOwnerLabels(kustomizationSet *kustomizesetv1.KustomizationSet, obj client.Object) {
+	labels := map[string]string{}
+	for k, v := range obj.GetLabels() {
+		labels[k] = v
+	}
+	labels[kustomizesetv1.SetNameLabel] = kustomizationSet.GetName()
+	labels[kustomizesetv1.SetNamespaceLabel] = kustomizationSet.GetNamespace()
+	obj.SetLabels(labels)
+}
+
+// applyResource updates an existing resource with server-side apply, the
+// controller only owns the fields that are present in the generated resource,
+// and fields that are owned by other field managers are left in place.
+//
+// Resources that haven't been applied by the controller (they were created
+// by an earlier release that didn't use server-side apply, or are being
+// adopted) are applied with ForceOwnership, so that the controller takes
+// ownership of the generated fields, after that, conflicts with other field
+// managers are reported.
+func (r *KustomizationSetReconciler) applyResource(ctx context.Context, kustomizationSet *kustomizesetv1.KustomizationSet, resource *existingResource) (err error) {
+	ctx, span := startResourceSpan(ctx, "ApplyResource", resource.generated)
+	span.SetAttributes(attribute.Bool("outOfDate", resource.outOfDate))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	if !isAppliedBy(resource.existing, fieldManager) {
+		opts = append(opts, client.ForceOwnership)
+	}
+	if err := r.Client.Patch(ctx, resource.generated, client.Apply, opts...); err != nil {
+		return fmt.Errorf("failed to apply %s %s: %w", resource.generated.GetKind(), client.ObjectKeyFromObject(resource.generated), err)
+	}
+	if resource.outOfDate {
+		metrics.ResourceChanges.WithLabelValues(metrics.OperationUpdate, resource.generated.GetKind()).Inc()
+		r.recordResourceEvent(kustomizationSet, updatedReason, resource.generated, resource.generated.GetKind())
+	}
+
 	return nil
 }
 
+// startResourceSpan starts a span for a change to a generated resource.
+func startResourceSpan(ctx context.Context, name string, obj *unstructured.Unstructured) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		attribute.String("kind", obj.GetKind()),
+		attribute.String("namespace", obj.GetNamespace()),
+		attribute.String("name", obj.GetName()))
+}
+
+// isAppliedBy returns true if the field manager has applied the object with
+// server-side apply.
+func isAppliedBy(obj metav1.Object, manager string) bool {
+	for _, v := range obj.GetManagedFields() {
+		if v.Manager == manager && v.Operation == metav1.ManagedFieldsOperationApply {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newExistingResource compares the generated resource with the existing
+// resource, and determines whether the existing resource is Ready.
+func newExistingResource(generated, existing *unstructured.Unstructured) (*existingResource, error) {
+	outOfDate, err := isOutOfDate(generated, existing)
+	if err != nil {
+		return nil, err
+	}
+	current := &existingResource{
+		generated: generated,
+		existing:  existing,
+		outOfDate: outOfDate,
+		ready:     true,
+	}
+	gvk := existing.GroupVersionKind()
+	if gvk.Group == kustomizev1.GroupVersion.Group && gvk.Kind == kustomizev1.KustomizationKind {
+		health, err := kustomizationHealth(existing)
+		if err != nil {
+			return nil, err
+		}
+		current.ready = health.Status == kustomizesetv1.KustomizationReadyStatus
+		current.failed = health.Status == kustomizesetv1.KustomizationFailedStatus
+	}
+
+	return current, nil
+}
+
+// isOutOfDate returns true if the fields in the generated resource differ
+// from the existing resource, or fields that the controller applied are no
+// longer generated.
+//
+// Other fields that are not set in the generated resource are ignored, these
+// may have been defaulted by the API server or set by other field managers.
+func isOutOfDate(generated, existing *unstructured.Unstructured) (bool, error) {
+	removed, err := removedFields(generated, existing)
+	if err != nil {
+		return false, err
+	}
+	if len(removed) > 0 {
+		return true, nil
+	}
+
+	if !equality.Semantic.DeepDerivative(generated.GetLabels(), existing.GetLabels()) ||
+		!equality.Semantic.DeepDerivative(generated.GetAnnotations(), existing.GetAnnotations()) {
+		return true, nil
+	}
+	for k, v := range generated.Object {
+		switch k {
+		case "apiVersion", "kind", "metadata", "status":
+			continue
+		}
+		if !equality.Semantic.DeepDerivative(v, existing.Object[k]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// inventoryEntries returns the entries from the inventory of the
+// KustomizationSet.
+//
+// Kustomizations are returned with the provided version of the Kustomization
+// API, this must be the version that the KustomizationSet generates, so that
+// they can be managed after the cluster stops serving the version they were
+// created with.
+//
+// Earlier releases recorded Kustomizations in the inventory without a group,
+// kind or version, these entries are also migrated.
+func inventoryEntries(kustomizationSet *kustomizesetv1.KustomizationSet, kustomizationVersion string) []kustomizesetv1.ResourceRef {
+	if kustomizationSet.Status.Inventory == nil {
+		return nil
+	}
+	kustomizationSuffix := "_" + kustomizev1.GroupVersion.Group + "_" + kustomizev1.KustomizationKind
+	entries := []kustomizesetv1.ResourceRef{}
+	for _, v := range kustomizationSet.Status.Inventory.Entries {
+		if v.Version == "" && strings.HasSuffix(v.ID, "__") {
+			v.ID = strings.TrimSuffix(v.ID, "__") + kustomizationSuffix
+		}
+		if strings.HasSuffix(v.ID, kustomizationSuffix) {
+			v.Version = kustomizev1.GroupVersion.Group + "/" + kustomizationVersion
+		}
+		entries = append(entries, v)
+	}
+
+	return entries
+}
+
+func unstructuredFromResourceRef(ref kustomizesetv1.ResourceRef) (*unstructured.Unstructured, error) {
+	objMeta, err := object.ParseObjMetadata(ref.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse object ID %s: %w", ref.ID, err)
+	}
+	gv, err := schema.ParseGroupVersion(ref.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse version %s for object ID %s: %w", ref.Version, ref.ID, err)
+	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(gv.WithKind(objMeta.GroupKind.Kind))
+	u.SetName(objMeta.Name)
+	u.SetNamespace(objMeta.Namespace)
+
+	return u, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
-func (r *KustomizationSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+func (r *KustomizationSetReconciler) SetupWithManager(mgr ctrl.Manager, opts KustomizationSetReconcilerOptions) error {
 
 	// Index the KustomizationSets by the GitRepository references they (may) point at.
 	if err := mgr.GetCache().IndexField(context.TODO(),
@@ -181,15 +892,88 @@ func (r *KustomizationSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return fmt.Errorf("failed setting index fields: %w", err)
 	}
 
+	// Changes to the status of the generated Kustomizations only refresh the
+	// health of the set, this avoids calling the generators every time a
+	// Kustomization is reconciled.
+	health, err := controller.New("kustomizationset-health", mgr, controller.Options{
+		Reconciler:              &healthReconciler{KustomizationSetReconciler: r},
+		MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+		RateLimiter:             opts.RateLimiter,
+		CacheSyncTimeout:        opts.CacheSyncTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed creating the health controller: %w", err)
+	}
+	if err := health.Watch(
+		&source.Kind{Type: &kustomizev1.Kustomization{}},
+		handler.EnqueueRequestsFromMapFunc(kustomizationToKustomizationSet),
+	); err != nil {
+		return fmt.Errorf("failed watching Kustomizations: %w", err)
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&kustomizesetv1.KustomizationSet{}).
+		For(&kustomizesetv1.KustomizationSet{}, builder.WithPredicates(
+			predicate.Or(predicate.GenerationChangedPredicate{}, predicates.ReconcileRequestedPredicate{}, deletionsApprovedPredicate()),
+		)).
+		// Generated Kustomizations that are deleted or changed by something
+		// else are reapplied.
+		Watches(
+			&source.Kind{Type: &kustomizev1.Kustomization{}},
+			handler.EnqueueRequestsFromMapFunc(kustomizationToKustomizationSet),
+			builder.WithPredicates(generatedKustomizationChangedPredicate()),
+		).
+		// The next step of a rolling sync starts when the Kustomizations in
+		// the current step are Ready.
+		Watches(
+			&source.Kind{Type: &kustomizev1.Kustomization{}},
+			handler.EnqueueRequestsFromMapFunc(r.kustomizationToRollingSet),
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  func(event.CreateEvent) bool { return false },
+				DeleteFunc:  func(event.DeleteEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			}),
+		).
 		Watches(
 			&source.Kind{Type: &sourcev1.GitRepository{}},
 			handler.EnqueueRequestsFromMapFunc(r.gitRepositoryToKustomizationSet),
 		).
+		WithOptions(controller.Options{
+			MaxConcurrentReconciles: opts.MaxConcurrentReconciles,
+			RateLimiter:             opts.RateLimiter,
+			CacheSyncTimeout:        opts.CacheSyncTimeout,
+		}).
 		Complete(r)
 }
 
+// deletionsApprovedPredicate accepts changes to the approve-deletions
+// annotation of a KustomizationSet.
+func deletionsApprovedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+			return e.ObjectOld.GetAnnotations()[kustomizesetv1.ApproveDeletionsAnnotation] != e.ObjectNew.GetAnnotations()[kustomizesetv1.ApproveDeletionsAnnotation]
+		},
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
+
+// generatedKustomizationChangedPredicate accepts the deletion of generated
+// Kustomizations and changes to their spec, changes to their status are
+// handled by the healthReconciler.
+func generatedKustomizationChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(event.CreateEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return predicate.GenerationChangedPredicate{}.Update(e)
+		},
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
+
 func (r *KustomizationSetReconciler) gitRepositoryToKustomizationSet(obj client.Object) []reconcile.Request {
 	// TODO: Store the applied version of GitRepositories in the Status, and don't
 	// retrigger if the commit-id isn't different.
@@ -210,6 +994,38 @@ func (r *KustomizationSetReconciler) gitRepositoryToKustomizationSet(obj client.
 	return result
 }
 
+// kustomizationToKustomizationSet maps generated Kustomizations to the
+// KustomizationSet that generated them, Kustomizations generated into other
+// namespaces can't have owner references so this uses the labels.
+func kustomizationToKustomizationSet(obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	name, namespace := labels[kustomizesetv1.SetNameLabel], labels[kustomizesetv1.SetNamespaceLabel]
+	if name == "" || namespace == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name, Namespace: namespace}}}
+}
+
+// kustomizationToRollingSet maps generated Kustomizations to the
+// KustomizationSet that generated them if the set is rolling out changes.
+func (r *KustomizationSetReconciler) kustomizationToRollingSet(obj client.Object) []reconcile.Request {
+	requests := kustomizationToKustomizationSet(obj)
+	if len(requests) == 0 {
+		return nil
+	}
+
+	var kustomizationSet kustomizesetv1.KustomizationSet
+	if err := r.Get(context.Background(), requests[0].NamespacedName, &kustomizationSet); err != nil {
+		return nil
+	}
+	if rollout := kustomizationSet.Status.RollingSync; rollout == nil || rollout.CurrentStep == 0 {
+		return nil
+	}
+
+	return requests
+}
+
 func indexGitRepositories(o client.Object) []string {
 	ks, ok := o.(*kustomizesetv1.KustomizationSet)
 	if !ok {
@@ -17,29 +17,43 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/cli-utils/pkg/object"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 	"github.com/fluxcd/pkg/apis/meta"
 	sourcev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/metrics"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/list"
+	"github.com/gitops-tools/kustomization-set-controller/test"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestReconciliation(t *testing.T) {
@@ -78,9 +92,10 @@ func TestReconciliation(t *testing.T) {
 		Generators: map[string]generators.Generator{
 			"List": list.NewGenerator(),
 		},
+		EventRecorder: &record.FakeRecorder{},
 	}
 
-	if err := reconciler.SetupWithManager(mgr); err != nil {
+	if err := reconciler.SetupWithManager(mgr, KustomizationSetReconcilerOptions{}); err != nil {
 		t.Fatal(err)
 	}
 
@@ -111,11 +126,1610 @@ func TestReconciliation(t *testing.T) {
 		assertInventoryHasItems(t, updated, want...)
 
 		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
-		assertKustomizationCondition(t, updated, meta.ReadyCondition, "3 kustomizations created")
+		assertKustomizationCondition(t, updated, meta.ReadyCondition, "0 of 3 kustomizations ready")
+
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev-demo", Namespace: "default"}, &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		if !isAppliedBy(&kustomization, fieldManager) {
+			t.Fatalf("Kustomization was not created with server-side apply: %v", kustomization.ManagedFields)
+		}
+	})
+
+	t.Run("reconciling removal of resources", func(t *testing.T) {
+		ctx := context.TODO()
+		devKS := newKustomization("engineering-dev-demo", "default")
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-prod"}`)},
+							{Raw: []byte(`{"cluster": "engineering-preprod"}`)},
+						},
+					},
+				},
+			}
+		})
+		// TODO: create and cleanup
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		if err := k8sClient.Create(ctx, devKS); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
+			Entries: []sourcev1alpha1.ResourceRef{
+				resourceRefFromObject(t, devKS),
+			},
+		}
+		if err := k8sClient.Status().Update(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []runtime.Object{
+			newKustomization("engineering-prod-demo", "default"),
+			newKustomization("engineering-preprod-demo", "default"),
+		}
+		assertInventoryHasItems(t, updated, want...)
+		assertResourceDoesNotExist(t, k8sClient, devKS)
+	})
+
+	t.Run("reconciling update of resources", func(t *testing.T) {
+		ctx := context.TODO()
+		devKS := newKustomization("engineering-dev-demo", "default", func(k *kustomizev1.Kustomization) {
+			k.ObjectMeta.Annotations = map[string]string{
+				"testing": "testing",
+			}
+		})
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Template.KustomizationSetTemplateMeta = sourcev1alpha1.KustomizationSetTemplateMeta{
+				Name:      `{{.cluster}}-demo`,
+				Namespace: "default",
+				Annotations: map[string]string{
+					"testing.cluster": "{{.cluster}}",
+				},
+			}
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		// TODO: create and cleanup
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		if err := k8sClient.Create(ctx, devKS); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
+			Entries: []sourcev1alpha1.ResourceRef{
+				resourceRefFromObject(t, devKS),
+			},
+		}
+		if err := k8sClient.Status().Update(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		wantUpdated := newKustomization("engineering-dev-demo", "default", func(k *kustomizev1.Kustomization) {
+			k.ObjectMeta.Annotations = map[string]string{
+				"testing":         "testing",
+				"testing.cluster": "engineering-dev",
+			}
+			k.ObjectMeta.Labels = map[string]string{
+				sourcev1alpha1.SetNameLabel:      "demo-set",
+				sourcev1alpha1.SetNamespaceLabel: "default",
+			}
+			k.Spec.Path = "./clusters/engineering-dev/"
+			k.Spec.KubeConfig = &kustomizev1.KubeConfig{SecretRef: meta.SecretKeyReference{Name: "engineering-dev"}}
+			if err := controllerutil.SetControllerReference(updated, k, scheme.Scheme); err != nil {
+				t.Fatal(err)
+			}
+		})
+		want := []runtime.Object{
+			wantUpdated,
+		}
+		assertInventoryHasItems(t, updated, want...)
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(wantUpdated), &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(wantUpdated, &kustomization, objectMetaIgnore()...); diff != "" {
+			t.Fatalf("failed to update Kustomization:\n%s", diff)
+		}
+	})
+
+	t.Run("reconciling creation of resources in other namespaces", func(t *testing.T) {
+		ctx := context.TODO()
+		createNamespace(t, k8sClient, "team-a")
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.AllowedNamespaces = []string{"team-a"}
+			ks.Spec.Template.Namespace = "{{.team}}"
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev", "team": "team-a"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated, newKustomization("engineering-dev-demo", "team-a"))
+
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev-demo", Namespace: "team-a"}, &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		if l := len(kustomization.GetOwnerReferences()); l != 0 {
+			t.Fatalf("got %d owner references, want 0", l)
+		}
+		wantLabels := map[string]string{
+			sourcev1alpha1.SetNameLabel:      "demo-set",
+			sourcev1alpha1.SetNamespaceLabel: "default",
+		}
+		if diff := cmp.Diff(wantLabels, kustomization.GetLabels()); diff != "" {
+			t.Fatalf("failed to label Kustomization:\n%s", diff)
+		}
+
+		if err := k8sClient.Delete(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertResourceDoesNotExist(t, k8sClient, &kustomization)
+		assertKustomizationSetDoesNotExist(t, k8sClient, kz)
+	})
+
+	t.Run("reconciling creation of resources in namespaces that are not allowed", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Template.Namespace = "team-a"
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+
+		// The namespace may be rendered from generated parameters, so this is
+		// retried rather than stalled.
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		test.AssertErrorMatch(t, "in namespace team-a is not permitted", err)
+		assertKustomizationsExist(t, k8sClient, "team-a")
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionFalse, sourcev1alpha1.RenderFailedReason)
+		assertConditionStatus(t, updated, meta.ReconcilingCondition, metav1.ConditionTrue, meta.ProgressingWithRetryReason)
+		if apimeta.FindStatusCondition(updated.Status.Conditions, meta.StalledCondition) != nil {
+			t.Fatalf("render failure was stalled: %#v", updated.Status.Conditions)
+		}
+		if updated.Status.ObservedGeneration != updated.Generation {
+			t.Fatalf("got observedGeneration %d, want %d", updated.Status.ObservedGeneration, updated.Generation)
+		}
+	})
+
+	t.Run("reconciling a set that generates duplicate resources is retried", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "duplicate-set"
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "duplicated"}`)},
+							{Raw: []byte(`{"cluster": "duplicated"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+
+		// Duplicates are usually generated from the parameters, and can be
+		// resolved by changes to them.
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		test.AssertErrorMatch(t, "generated duplicate resources", err)
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionFalse, sourcev1alpha1.DuplicateResourcesReason)
+		assertConditionStatus(t, updated, meta.ReconcilingCondition, metav1.ConditionTrue, meta.ProgressingWithRetryReason)
+		if apimeta.FindStatusCondition(updated.Status.Conditions, meta.StalledCondition) != nil {
+			t.Fatalf("duplicate resources were stalled: %#v", updated.Status.Conditions)
+		}
+	})
+
+	t.Run("reconciling a set recovers from a failure", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "recovering-set"
+			ks.Spec.Template.Name = "{{.cluster}-demo"
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "recovering"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.StalledCondition, metav1.ConditionTrue, sourcev1alpha1.RenderFailedReason)
+
+		updated.Spec.Template.Name = "{{.cluster}}-demo"
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionUnknown, meta.ProgressingReason)
+		if apimeta.FindStatusCondition(updated.Status.Conditions, meta.StalledCondition) != nil {
+			t.Fatalf("stalled condition was not removed: %#v", updated.Status.Conditions)
+		}
+		if updated.Status.ObservedGeneration != updated.Generation {
+			t.Fatalf("got observedGeneration %d, want %d", updated.Status.ObservedGeneration, updated.Generation)
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "recovering-demo")
+	})
+
+	t.Run("reconciling with allowed namespaces that are not watched", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "unwatched-set"
+			ks.Spec.AllowedNamespaces = []string{"default", "team-a"}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+		watching := *reconciler
+		watching.WatchNamespaces = []string{"default"}
+
+		_, err := watching.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default")
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.StalledCondition, metav1.ConditionTrue, sourcev1alpha1.RenderFailedReason)
+		want := "allowedNamespaces team-a are not watched by the controller, must be within default"
+		if msg := apimeta.FindStatusCondition(updated.Status.Conditions, meta.ReadyCondition).Message; msg != want {
+			t.Fatalf("got message %q, want %q", msg, want)
+		}
+	})
+	t.Run("reconciling creation and removal of resources from a resource template", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Template = nil
+			ks.Spec.ResourceTemplate = &apiextensionsv1.JSON{
+				Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{.cluster}}-config"}, "data": {"cluster": "{{.cluster}}"}}`),
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated,
+			newConfigMap("engineering-dev-config", "default"),
+			newConfigMap("engineering-prod-config", "default"),
+			newConfigMap("engineering-preprod-config", "default"))
+
+		var cm corev1.ConfigMap
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev-config", Namespace: "default"}, &cm); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string]string{"cluster": "engineering-dev"}, cm.Data); diff != "" {
+			t.Fatalf("failed to generate ConfigMap:\n%s", diff)
+		}
+
+		updated.Spec.Generators[0].List.Elements = updated.Spec.Generators[0].List.Elements[1:]
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated,
+			newConfigMap("engineering-prod-config", "default"),
+			newConfigMap("engineering-preprod-config", "default"))
+		assertObjectDoesNotExist(t, k8sClient, &cm)
+	})
+
+	t.Run("reconciling creation of cluster-scoped resources from a resource template", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Template = nil
+			ks.Spec.ResourceTemplate = &apiextensionsv1.JSON{
+				Raw: []byte(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "{{.cluster}}"}}`),
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "engineering-dev"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "engineering-prod"}},
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "engineering-preprod"}})
+
+		var ns corev1.Namespace
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev"}, &ns); err != nil {
+			t.Fatal(err)
+		}
+		if l := len(ns.GetOwnerReferences()); l != 0 {
+			t.Fatalf("got %d owner references, want 0", l)
+		}
+		if v := ns.GetLabels()[sourcev1alpha1.SetNameLabel]; v != "demo-set" {
+			t.Fatalf("got set name label %q, want %q", v, "demo-set")
+		}
+	})
+
+	t.Run("reconciling creation of resources from multiple templates", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Template = nil
+			ks.Spec.Templates = []sourcev1alpha1.KustomizationSetResourceTemplate{
+				{
+					Name:     "config",
+					Resource: apiextensionsv1.JSON{Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{.cluster}}-settings"}}`)},
+				},
+				{
+					Name:      "kustomization",
+					Condition: `{{ ne .cluster "engineering-prod" }}`,
+					Resource:  apiextensionsv1.JSON{Raw: []byte(`{"apiVersion": "kustomize.toolkit.fluxcd.io/v1beta2", "kind": "Kustomization", "metadata": {"name": "{{.cluster}}-demo"}, "spec": {"interval": "5m", "path": "./examples/kustomize/environments/dev", "prune": true, "sourceRef": {"kind": "GitRepository", "name": "demo-repo"}}}`)},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated,
+			newConfigMap("engineering-dev-settings", "default"),
+			newConfigMap("engineering-prod-settings", "default"),
+			newConfigMap("engineering-preprod-settings", "default"),
+			newKustomization("engineering-dev-demo", "default"),
+			newKustomization("engineering-preprod-demo", "default"))
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-preprod-demo")
+	})
+
+	t.Run("reconciling creation of v1 Kustomizations", func(t *testing.T) {
+		ctx := context.TODO()
+		v1Reconciler := &KustomizationSetReconciler{
+			Client:               k8sClient,
+			Scheme:               scheme.Scheme,
+			Generators:           reconciler.Generators,
+			KustomizationVersion: sourcev1alpha1.KustomizationVersionV1,
+			EventRecorder:        reconciler.EventRecorder,
+		}
+		devKS := newKustomization("engineering-dev-demo", "default")
+		kz := newKustomizationSet()
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		if err := k8sClient.Create(ctx, devKS); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
+			Entries: []sourcev1alpha1.ResourceRef{
+				resourceRefFromObject(t, devKS),
+			},
+		}
+		if err := k8sClient.Status().Update(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := v1Reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		want := []sourcev1alpha1.ResourceRef{}
+		for _, name := range []string{"engineering-dev-demo", "engineering-preprod-demo", "engineering-prod-demo"} {
+			want = append(want, sourcev1alpha1.ResourceRef{
+				ID:        "default_" + name + "_kustomize.toolkit.fluxcd.io_Kustomization",
+				Version:   "kustomize.toolkit.fluxcd.io/v1",
+				Policy:    sourcev1alpha1.GeneratorPolicySync,
+				Generator: "0/List",
+			})
+		}
+		if diff := cmp.Diff(&sourcev1alpha1.ResourceInventory{Entries: want}, updated.Status.Inventory); diff != "" {
+			t.Fatalf("failed to get inventory:\n%s", diff)
+		}
+
+		// The existing Kustomization was updated rather than recreated.
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(devKS), &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		if kustomization.GetUID() != devKS.GetUID() {
+			t.Fatal("existing Kustomization was recreated")
+		}
+		if kustomization.Spec.Path != "./clusters/engineering-dev/" {
+			t.Fatalf("existing Kustomization was not updated, got path %q", kustomization.Spec.Path)
+		}
+	})
+
+	t.Run("deleting a set with the Orphan deletion policy", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.DeletionPolicy = sourcev1alpha1.DeletionPolicyOrphan
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Delete(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertKustomizationSetDoesNotExist(t, k8sClient, kz)
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev-demo", Namespace: "default"}, &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		if l := len(kustomization.GetOwnerReferences()); l != 0 {
+			t.Fatalf("got %d owner references, want 0", l)
+		}
+		if l := len(kustomization.GetLabels()); l != 0 {
+			t.Fatalf("got labels %v, want none", kustomization.GetLabels())
+		}
+	})
+
+	t.Run("deleting a set waits for generated resources to be removed", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Template.Finalizers = []string{"testing/finalizer"}
+			ks.Spec.Generators[0].List.Elements = ks.Spec.Generators[0].List.Elements[:1]
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Delete(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.RequeueAfter == 0 {
+			t.Fatal("expected the deletion to be requeued")
+		}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), &sourcev1alpha1.KustomizationSet{}); err != nil {
+			t.Fatalf("expected the set to exist while resources are deleted: %s", err)
+		}
+
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev-demo", Namespace: "default"}, &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		kustomization.SetFinalizers(nil)
+		if err := k8sClient.Update(ctx, &kustomization); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationSetDoesNotExist(t, k8sClient, kz)
+		assertResourceDoesNotExist(t, k8sClient, &kustomization)
+	})
+
+	t.Run("reconciling the health of generated Kustomizations", func(t *testing.T) {
+		healthTests := []struct {
+			name        string
+			healthCheck *sourcev1alpha1.KustomizationSetHealthCheck
+			wantStatus  metav1.ConditionStatus
+			wantReason  string
+			wantMessage string
+		}{
+			{
+				name:        "default thresholds",
+				wantStatus:  metav1.ConditionFalse,
+				wantReason:  sourcev1alpha1.KustomizationsFailedReason,
+				wantMessage: "1 of 3 kustomizations failed",
+			},
+			{
+				name: "configured thresholds",
+				healthCheck: &sourcev1alpha1.KustomizationSetHealthCheck{
+					MinReady:  intOrStringPtr(intstr.FromString("50%")),
+					MaxFailed: intOrStringPtr(intstr.FromInt(1)),
+				},
+				wantStatus:  metav1.ConditionTrue,
+				wantReason:  sourcev1alpha1.HealthyCondition,
+				wantMessage: "2 of 3 kustomizations ready",
+			},
+		}
+
+		for i, tt := range healthTests {
+			t.Run(tt.name, func(t *testing.T) {
+				ctx := context.TODO()
+				prefix := fmt.Sprintf("health-%d", i)
+				kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+					ks.ObjectMeta.Name = prefix + "-set"
+					ks.Spec.HealthCheck = tt.healthCheck
+					ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+						{
+							List: &sourcev1alpha1.ListGenerator{
+								Elements: []apiextensionsv1.JSON{
+									{Raw: []byte(fmt.Sprintf(`{"cluster": "%s-dev"}`, prefix))},
+									{Raw: []byte(fmt.Sprintf(`{"cluster": "%s-staging"}`, prefix))},
+									{Raw: []byte(fmt.Sprintf(`{"cluster": "%s-prod"}`, prefix))},
+								},
+							},
+						},
+					}
+				})
+				if err := k8sClient.Create(ctx, kz); err != nil {
+					t.Fatal(err)
+				}
+				defer cleanupResource(t, k8sClient, kz)
+				defer deleteAllKustomizations(t, k8sClient)
+
+				_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				setKustomizationReady(t, k8sClient, prefix+"-dev-demo", metav1.ConditionTrue, "main@sha1:a")
+				setKustomizationReady(t, k8sClient, prefix+"-staging-demo", metav1.ConditionTrue, "main@sha1:a")
+				setKustomizationReady(t, k8sClient, prefix+"-prod-demo", metav1.ConditionFalse, "")
+
+				_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				updated := &sourcev1alpha1.KustomizationSet{}
+				if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+					t.Fatal(err)
+				}
+				assertConditionStatus(t, updated, meta.ReadyCondition, tt.wantStatus, tt.wantReason)
+				assertKustomizationCondition(t, updated, meta.ReadyCondition, tt.wantMessage)
+				wantSummary := &sourcev1alpha1.KustomizationSetSummary{Ready: 2, Failed: 1}
+				if diff := cmp.Diff(wantSummary, updated.Status.Summary); diff != "" {
+					t.Fatalf("failed to summarise Kustomizations:\n%s", diff)
+				}
+				wantKustomizations := []sourcev1alpha1.GeneratedKustomizationStatus{
+					{Name: prefix + "-dev-demo", Namespace: "default", Status: sourcev1alpha1.KustomizationReadyStatus, Message: "testing", LastAppliedRevision: "main@sha1:a"},
+					{Name: prefix + "-prod-demo", Namespace: "default", Status: sourcev1alpha1.KustomizationFailedStatus, Message: "testing"},
+					{Name: prefix + "-staging-demo", Namespace: "default", Status: sourcev1alpha1.KustomizationReadyStatus, Message: "testing", LastAppliedRevision: "main@sha1:a"},
+				}
+				if diff := cmp.Diff(wantKustomizations, updated.Status.Kustomizations); diff != "" {
+					t.Fatalf("failed to record Kustomization status:\n%s", diff)
+				}
+			})
+		}
+	})
+
+	t.Run("refreshing the health of generated Kustomizations does not generate resources", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet()
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		setKustomizationReady(t, k8sClient, "engineering-dev-demo", metav1.ConditionTrue, "main@sha1:a")
+		setKustomizationReady(t, k8sClient, "engineering-prod-demo", metav1.ConditionTrue, "main@sha1:a")
+		setKustomizationReady(t, k8sClient, "engineering-preprod-demo", metav1.ConditionTrue, "main@sha1:a")
+
+		healthReconciler := &healthReconciler{KustomizationSetReconciler: &KustomizationSetReconciler{
+			Client: k8sClient,
+			Scheme: scheme.Scheme,
+			Generators: map[string]generators.Generator{
+				"List": failingGenerator{Generator: list.NewGenerator(), err: errors.New("test failure")},
+			},
+			EventRecorder: &record.FakeRecorder{},
+		}}
+		_, err = healthReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionTrue, sourcev1alpha1.HealthyCondition)
+		assertKustomizationCondition(t, updated, meta.ReadyCondition, "3 of 3 kustomizations ready")
+		if diff := cmp.Diff(&sourcev1alpha1.KustomizationSetSummary{Ready: 3}, updated.Status.Summary); diff != "" {
+			t.Fatalf("failed to summarise Kustomizations:\n%s", diff)
+		}
+	})
+
+	t.Run("refreshing the health of a set that failed to reconcile", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet()
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		*updated = sourcev1alpha1.KustomizationSetNotReady(*updated, sourcev1alpha1.GenerationFailedReason, "test failure")
+		if err := k8sClient.Status().Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		setKustomizationReady(t, k8sClient, "engineering-dev-demo", metav1.ConditionTrue, "main@sha1:a")
+
+		_, err = (&healthReconciler{KustomizationSetReconciler: reconciler}).Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionFalse, sourcev1alpha1.GenerationFailedReason)
+	})
+
+	t.Run("reconciling updates with a rolling sync strategy", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "rolling-set"
+			ks.Spec.Template.KustomizationSetTemplateMeta = sourcev1alpha1.KustomizationSetTemplateMeta{
+				Name:      "rolling-{{.env}}-demo",
+				Namespace: "default",
+				Labels: map[string]string{
+					"env": "{{.env}}",
+				},
+			}
+			ks.Spec.Template.Spec.Path = "./clusters/{{.env}}/v1"
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"env": "dev"}`)},
+							{Raw: []byte(`{"env": "prod"}`)},
+						},
+					},
+				},
+			}
+			ks.Spec.Strategy = &sourcev1alpha1.KustomizationSetStrategy{
+				RollingSync: &sourcev1alpha1.RollingSyncStrategy{
+					Steps: []sourcev1alpha1.RollingSyncStep{
+						{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"dev"}}}},
+						{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod"}}}},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		setKustomizationReady(t, k8sClient, "rolling-dev-demo", metav1.ConditionTrue, "")
+		setKustomizationReady(t, k8sClient, "rolling-prod-demo", metav1.ConditionTrue, "")
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		updated.Spec.Template.Spec.Path = "./clusters/{{.env}}/v2"
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertKustomizationPath(t, k8sClient, "rolling-dev-demo", "./clusters/dev/v2")
+		assertKustomizationPath(t, k8sClient, "rolling-prod-demo", "./clusters/prod/v1")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionUnknown, meta.ProgressingReason)
+		if updated.Status.RollingSync.CurrentStep != 1 {
+			t.Fatalf("got current step %d, want 1", updated.Status.RollingSync.CurrentStep)
+		}
+
+		setKustomizationReady(t, k8sClient, "rolling-dev-demo", metav1.ConditionTrue, "")
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationPath(t, k8sClient, "rolling-prod-demo", "./clusters/prod/v2")
+
+		setKustomizationReady(t, k8sClient, "rolling-prod-demo", metav1.ConditionTrue, "")
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionTrue, sourcev1alpha1.HealthyCondition)
+		if updated.Status.RollingSync.CurrentStep != 0 {
+			t.Fatalf("got current step %d, want the rollout to be complete", updated.Status.RollingSync.CurrentStep)
+		}
+	})
+
+	t.Run("reconciling a suspended set", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "suspended-set"
+			ks.Spec.Suspend = true
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "suspended"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default")
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		updated.Spec.Suspend = false
+		updated.SetAnnotations(map[string]string{
+			meta.ReconcileRequestAnnotation: "2022-11-01T10:00:00Z",
+		})
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "suspended-demo")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		if v := updated.Status.LastHandledReconcileAt; v != "2022-11-01T10:00:00Z" {
+			t.Fatalf("got lastHandledReconcileAt %q", v)
+		}
+	})
+
+	t.Run("reconciling update of resources with conflicting field managers", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "conflicting-set"
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "conflicting"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		// The first reconciliation creates the Kustomization, and the second
+		// applies it.
+		for i := 0; i < 2; i++ {
+			if _, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		other := &unstructured.Unstructured{}
+		other.SetGroupVersionKind(kustomizev1.GroupVersion.WithKind(kustomizev1.KustomizationKind))
+		other.SetName("conflicting-demo")
+		other.SetNamespace("default")
+		if err := unstructured.SetNestedField(other.Object, "./other", "spec", "path"); err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Patch(ctx, other, client.Apply, client.FieldOwner("other-manager"), client.ForceOwnership); err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		updated.Spec.Template.Spec.Path = "./clusters/{{.cluster}}/v2"
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if !apierrors.IsConflict(err) {
+			t.Fatalf("expected a conflict error, got %v", err)
+		}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionFalse, sourcev1alpha1.ApplyConflictReason)
+		assertKustomizationPath(t, k8sClient, "conflicting-demo", "./other")
+	})
+
+	t.Run("reconciling adoption of existing resources", func(t *testing.T) {
+		ctx := context.TODO()
+		existing := newKustomization("adopted-demo", "default", func(k *kustomizev1.Kustomization) {
+			k.ObjectMeta.Labels = map[string]string{"app": "demo"}
+		})
+		if err := k8sClient.Create(ctx, existing); err != nil {
+			t.Fatal(err)
+		}
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "adopting-set"
+			ks.Spec.AdoptionPolicy = sourcev1alpha1.AdoptionPolicyIfAnnotated
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "adopted"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err == nil {
+			t.Fatal("expected an error adopting a resource that is not annotated")
+		}
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionFalse, sourcev1alpha1.AdoptionRefusedReason)
+		assertKustomizationCondition(t, updated, meta.ReadyCondition,
+			"failed to create Kustomization: Kustomization default/adopted-demo already exists and is not annotated with source.gitops.solutions/adopt=default/adopting-set")
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(existing), existing); err != nil {
+			t.Fatal(err)
+		}
+		existing.SetAnnotations(map[string]string{sourcev1alpha1.AdoptAnnotation: "default/adopting-set"})
+		if err := k8sClient.Update(ctx, existing); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated, existing)
+
+		var adopted kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(existing), &adopted); err != nil {
+			t.Fatal(err)
+		}
+		wantLabels := map[string]string{
+			"app":                            "demo",
+			sourcev1alpha1.SetNameLabel:      "adopting-set",
+			sourcev1alpha1.SetNamespaceLabel: "default",
+		}
+		if diff := cmp.Diff(wantLabels, adopted.GetLabels()); diff != "" {
+			t.Fatalf("failed to label adopted Kustomization:\n%s", diff)
+		}
+		if adopted.Spec.Path != "./clusters/adopted/" {
+			t.Fatalf("failed to update adopted Kustomization, got path %s", adopted.Spec.Path)
+		}
+		if l := len(adopted.GetOwnerReferences()); l != 1 {
+			t.Fatalf("got %d owner references, want 1", l)
+		}
+	})
+
+	t.Run("reconciling an empty set with preserveOnEmpty", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "preserving-set"
+			ks.Spec.SyncPolicy = &sourcev1alpha1.KustomizationSetSyncPolicy{PreserveOnEmpty: true}
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "preserved"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		updated.Spec.Generators[0].List.Elements = []apiextensionsv1.JSON{}
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "preserved-demo")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		preserved := newKustomization("preserved-demo", "default")
+		assertInventoryHasItems(t, updated, preserved)
+		if l := len(updated.Status.PendingDeletions); l != 1 {
+			t.Fatalf("got %d pending deletions, want 1", l)
+		}
+		if want := resourceRefFromObject(t, preserved); updated.Status.PendingDeletions[0].ResourceRef != want {
+			t.Fatalf("got pending deletion %v, want %v", updated.Status.PendingDeletions[0].ResourceRef, want)
+		}
+	})
+
+	t.Run("reconciling an empty generator with preserveOnEmpty", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "preserving-generator-set"
+			ks.Spec.SyncPolicy = &sourcev1alpha1.KustomizationSetSyncPolicy{PreserveOnEmpty: true}
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "kept"}`)},
+						},
+					},
+				},
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "preserved"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		updated.Spec.Generators[1].List.Elements = []apiextensionsv1.JSON{}
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "kept-demo", "preserved-demo")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		want := resourceRefFromObject(t, newKustomization("preserved-demo", "default"))
+		want.Generator = "1/List"
+		if l := len(updated.Status.PendingDeletions); l != 1 {
+			t.Fatalf("got %d pending deletions, want 1", l)
+		}
+		if updated.Status.PendingDeletions[0].ResourceRef != want {
+			t.Fatalf("got pending deletion %v, want %v", updated.Status.PendingDeletions[0].ResourceRef, want)
+		}
+	})
+
+	t.Run("reconciling resources with generator policies", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "policy-set"
+			ks.Spec.Template.Spec.Path = "./v1"
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					Policy: sourcev1alpha1.GeneratorPolicyCreateOnly,
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "create-only"}`)}},
+					},
+				},
+				{
+					Policy: sourcev1alpha1.GeneratorPolicyCreateUpdate,
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "create-update"}`)}},
+					},
+				},
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "synced"}`)}},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "create-only-demo", "create-update-demo", "synced-demo")
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		policies := map[string]string{}
+		for _, v := range updated.Status.Inventory.Entries {
+			policies[v.ID] = v.Policy
+		}
+		wantPolicies := map[string]string{
+			"default_create-only-demo_kustomize.toolkit.fluxcd.io_Kustomization":   sourcev1alpha1.GeneratorPolicyCreateOnly,
+			"default_create-update-demo_kustomize.toolkit.fluxcd.io_Kustomization": sourcev1alpha1.GeneratorPolicyCreateUpdate,
+			"default_synced-demo_kustomize.toolkit.fluxcd.io_Kustomization":        sourcev1alpha1.GeneratorPolicySync,
+		}
+		if diff := cmp.Diff(wantPolicies, policies); diff != "" {
+			t.Fatalf("failed to record policies:\n%s", diff)
+		}
+
+		updated.Spec.Template.Spec.Path = "./v2"
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationPath(t, k8sClient, "create-only-demo", "./v1")
+		assertKustomizationPath(t, k8sClient, "create-update-demo", "./v2")
+		assertKustomizationPath(t, k8sClient, "synced-demo", "./v2")
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		for i := range updated.Spec.Generators {
+			updated.Spec.Generators[i].List.Elements = []apiextensionsv1.JSON{}
+		}
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "create-only-demo", "create-update-demo")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		if l := len(updated.Status.Inventory.Entries); l != 2 {
+			t.Fatalf("got %d inventory entries, want 2", l)
+		}
+
+		// Deleting the set orphans the resources that can't be deleted.
+		if err := k8sClient.Delete(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationSetDoesNotExist(t, k8sClient, kz)
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "create-only-demo", Namespace: "default"}, &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := kustomization.GetLabels()[sourcev1alpha1.SetNameLabel]; ok {
+			t.Fatalf("expected the set labels to be removed, got %v", kustomization.GetLabels())
+		}
+		if refs := kustomization.GetOwnerReferences(); len(refs) != 0 {
+			t.Fatalf("expected the owner references to be removed, got %v", refs)
+		}
+	})
+
+	t.Run("reconciling a dry run", func(t *testing.T) {
+		ctx := context.TODO()
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.ObjectMeta.Name = "dry-run-set"
+			ks.Spec.DryRun = true
+			ks.Spec.Generators[0].List.Elements = ks.Spec.Generators[0].List.Elements[:1]
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		result, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.RequeueAfter != dryRunRequeueInterval {
+			t.Fatalf("got RequeueAfter %v, want %v", result.RequeueAfter, dryRunRequeueInterval)
+		}
+		assertKustomizationsExist(t, k8sClient, "default")
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionUnknown, sourcev1alpha1.DryRunReason)
+		devKS := newKustomization("engineering-dev-demo", "default")
+		wantRef := resourceRefFromObject(t, devKS)
+		wantRef.Policy = ""
+		wantRef.Generator = ""
+		if diff := cmp.Diff(&sourcev1alpha1.DryRunSummary{Create: []sourcev1alpha1.ResourceRef{wantRef}}, updated.Status.DryRun); diff != "" {
+			t.Fatalf("failed to get dry run summary:\n%s", diff)
+		}
+
+		updated.Spec.DryRun = false
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo")
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		if updated.Status.DryRun != nil {
+			t.Fatalf("expected the dry run summary to be removed, got %v", updated.Status.DryRun)
+		}
+		updated.Spec.DryRun = true
+		updated.Spec.Template.Spec.Path = "./changed"
+		updated.Spec.Generators[0].List.Elements = []apiextensionsv1.JSON{}
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertKustomizationPath(t, k8sClient, "engineering-dev-demo", "./clusters/engineering-dev/")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated, devKS)
+		assertConditionStatus(t, updated, meta.ReadyCondition, metav1.ConditionUnknown, sourcev1alpha1.DryRunReason)
+		if updated.Status.Kustomizations != nil || updated.Status.Summary != nil {
+			t.Fatalf("expected the Kustomization statuses to be cleared, got %v", updated.Status.Kustomizations)
+		}
+		if diff := cmp.Diff(&sourcev1alpha1.DryRunSummary{Delete: []sourcev1alpha1.ResourceRef{resourceRefFromObject(t, devKS)}}, updated.Status.DryRun); diff != "" {
+			t.Fatalf("failed to get dry run summary:\n%s", diff)
+		}
+	})
+
+	t.Run("reconciling resources with a legacy inventory", func(t *testing.T) {
+		ctx := context.TODO()
+		devKS := newKustomization("engineering-dev-demo", "default")
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-prod"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		if err := k8sClient.Create(ctx, devKS); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
+			Entries: []sourcev1alpha1.ResourceRef{
+				{ID: "default_engineering-dev-demo__"},
+			},
+		}
+		if err := k8sClient.Status().Update(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		updated := &sourcev1alpha1.KustomizationSet{}
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		assertInventoryHasItems(t, updated, newKustomization("engineering-prod-demo", "default"))
+		assertResourceDoesNotExist(t, k8sClient, devKS)
+	})
+
+	t.Run("reconciling records metrics", func(t *testing.T) {
+		ctx := context.TODO()
+		devKS := newKustomization("engineering-dev-demo", "default")
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-prod"}`)},
+							{Raw: []byte(`{"cluster": "engineering-preprod"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		if err := k8sClient.Create(ctx, devKS); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
+			Entries: []sourcev1alpha1.ResourceRef{
+				resourceRefFromObject(t, devKS),
+			},
+		}
+		if err := k8sClient.Status().Update(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		creates := metrics.ResourceChanges.WithLabelValues(metrics.OperationCreate, "Kustomization")
+		deletes := metrics.ResourceChanges.WithLabelValues(metrics.OperationDelete, "Kustomization")
+		createsBefore, deletesBefore := testutil.ToFloat64(creates), testutil.ToFloat64(deletes)
+
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v := testutil.ToFloat64(creates) - createsBefore; v != 2 {
+			t.Errorf("got %v creates, want 2", v)
+		}
+		if v := testutil.ToFloat64(deletes) - deletesBefore; v != 1 {
+			t.Errorf("got %v deletes, want 1", v)
+		}
+		if v := testutil.ToFloat64(metrics.GeneratedResources.WithLabelValues("default", kz.GetName())); v != 2 {
+			t.Errorf("got %v generated resources, want 2", v)
+		}
+	})
+
+	t.Run("reconciling records events for changed resources", func(t *testing.T) {
+		ctx := context.TODO()
+		recorder := record.NewFakeRecorder(10)
+		eventsReconciler := &KustomizationSetReconciler{
+			Client:        k8sClient,
+			Scheme:        scheme.Scheme,
+			Generators:    reconciler.Generators,
+			EventRecorder: recorder,
+		}
+		devKS := newKustomization("engineering-dev-demo", "default")
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-prod"}`)},
+							{Raw: []byte(`{"cluster": "engineering-preprod"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		if err := k8sClient.Create(ctx, devKS); err != nil {
+			t.Fatal(err)
+		}
+		defer deleteAllKustomizations(t, k8sClient)
+
+		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
+			Entries: []sourcev1alpha1.ResourceRef{
+				resourceRefFromObject(t, devKS),
+			},
+		}
+		if err := k8sClient.Status().Update(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"Normal Created Kustomization default/engineering-prod-demo created",
+			"Normal Created Kustomization default/engineering-preprod-demo created",
+			"Normal Deleted Kustomization default/engineering-dev-demo deleted",
+		}
+		if diff := cmp.Diff(want, drainEvents(recorder)); diff != "" {
+			t.Fatalf("failed to record events:\n%s", diff)
+		}
 	})
 
-	t.Run("reconciling removal of resources", func(t *testing.T) {
+	t.Run("reconciling records events for fields removed from the template", func(t *testing.T) {
+		ctx := context.TODO()
+		recorder := record.NewFakeRecorder(10)
+		eventsReconciler := &KustomizationSetReconciler{
+			Client:        k8sClient,
+			Scheme:        scheme.Scheme,
+			Generators:    reconciler.Generators,
+			EventRecorder: recorder,
+		}
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.Template.Annotations = map[string]string{"testing.cluster": "{{.cluster}}"}
+			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
+				{
+					List: &sourcev1alpha1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		for i := 0; i < 2; i++ {
+			_, err := eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		drainEvents(recorder)
+
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), kz); err != nil {
+			t.Fatal(err)
+		}
+		kz.Spec.Template.Annotations = nil
+		if err := k8sClient.Update(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		_, err := eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []string{
+			"Normal Updated Kustomization default/engineering-dev-demo updated",
+		}
+		if diff := cmp.Diff(want, drainEvents(recorder)); diff != "" {
+			t.Fatalf("failed to record events:\n%s", diff)
+		}
+		var kustomization kustomizev1.Kustomization
+		if err := k8sClient.Get(ctx, client.ObjectKey{Name: "engineering-dev-demo", Namespace: "default"}, &kustomization); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := kustomization.Annotations["testing.cluster"]; ok {
+			t.Fatalf("annotation was not removed: %v", kustomization.Annotations)
+		}
+	})
+
+	t.Run("reconciling records events for failing generators", func(t *testing.T) {
+		ctx := context.TODO()
+		recorder := record.NewFakeRecorder(10)
+		eventsReconciler := &KustomizationSetReconciler{
+			Client: k8sClient,
+			Scheme: scheme.Scheme,
+			Generators: map[string]generators.Generator{
+				"List": failingGenerator{Generator: list.NewGenerator(), err: errors.New("test failure")},
+			},
+			EventRecorder: recorder,
+		}
+		kz := newKustomizationSet()
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+
+		_, err := eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		test.AssertErrorMatch(t, "test failure", err)
+
+		events := drainEvents(recorder)
+		if len(events) != 1 || !strings.HasPrefix(events[0], "Warning GenerationFailed ") {
+			t.Fatalf("got events %v, want a GenerationFailed warning", events)
+		}
+	})
+
+	t.Run("reconciling records events when deletions are deferred", func(t *testing.T) {
 		ctx := context.TODO()
+		recorder := record.NewFakeRecorder(10)
+		eventsReconciler := &KustomizationSetReconciler{
+			Client:        k8sClient,
+			Scheme:        scheme.Scheme,
+			Generators:    reconciler.Generators,
+			EventRecorder: recorder,
+		}
 		devKS := newKustomization("engineering-dev-demo", "default")
 		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
 			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
@@ -123,13 +1737,14 @@ func TestReconciliation(t *testing.T) {
 					List: &sourcev1alpha1.ListGenerator{
 						Elements: []apiextensionsv1.JSON{
 							{Raw: []byte(`{"cluster": "engineering-prod"}`)},
-							{Raw: []byte(`{"cluster": "engineering-preprod"}`)},
 						},
 					},
 				},
 			}
+			ks.Spec.SyncPolicy = &sourcev1alpha1.KustomizationSetSyncPolicy{
+				DeletionGracePeriod: &metav1.Duration{Duration: time.Hour},
+			}
 		})
-		// TODO: create and cleanup
 		if err := k8sClient.Create(ctx, kz); err != nil {
 			t.Fatal(err)
 		}
@@ -139,66 +1754,126 @@ func TestReconciliation(t *testing.T) {
 		}
 		defer deleteAllKustomizations(t, k8sClient)
 
-		objMeta, err := object.RuntimeToObjMeta(devKS)
-		if err != nil {
-			t.Fatal(err)
-		}
 		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
 			Entries: []sourcev1alpha1.ResourceRef{
-				{
-					ID:      objMeta.String(),
-					Version: devKS.GetObjectKind().GroupVersionKind().GroupVersion().String(),
-				},
+				resourceRefFromObject(t, devKS),
 			},
 		}
 		if err := k8sClient.Status().Update(ctx, kz); err != nil {
 			t.Fatal(err)
 		}
 
-		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		for i := 0; i < 2; i++ {
+			_, err := eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The second reconciliation doesn't defer any more deletions.
+		want := []string{
+			"Normal Created Kustomization default/engineering-prod-demo created",
+			"Warning DeletionDeferred sync policy deferred the deletion of 1 resources that are no longer generated: default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization",
+		}
+		if diff := cmp.Diff(want, drainEvents(recorder)); diff != "" {
+			t.Fatalf("failed to record events:\n%s", diff)
+		}
+	})
+
+	t.Run("reconciling blocks deletions over maxDeletions until they are approved", func(t *testing.T) {
+		ctx := context.TODO()
+		recorder := record.NewFakeRecorder(10)
+		eventsReconciler := &KustomizationSetReconciler{
+			Client:        k8sClient,
+			Scheme:        scheme.Scheme,
+			Generators:    reconciler.Generators,
+			EventRecorder: recorder,
+		}
+		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
+			ks.Spec.SyncPolicy = &sourcev1alpha1.KustomizationSetSyncPolicy{
+				MaxDeletions: intOrStringPtr(intstr.FromInt(1)),
+			}
+		})
+		if err := k8sClient.Create(ctx, kz); err != nil {
+			t.Fatal(err)
+		}
+		defer cleanupResource(t, k8sClient, kz)
+		defer deleteAllKustomizations(t, k8sClient)
+
+		_, err := eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
 		if err != nil {
 			t.Fatal(err)
 		}
+		drainEvents(recorder)
 
 		updated := &sourcev1alpha1.KustomizationSet{}
 		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
 			t.Fatal(err)
 		}
+		updated.Spec.Generators[0].List.Elements = updated.Spec.Generators[0].List.Elements[:1]
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			result, err := eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.RequeueAfter != 0 {
+				t.Fatalf("got requeue after %v, want blocked deletions to wait for a change", result.RequeueAfter)
+			}
+		}
 
-		want := []runtime.Object{
-			newKustomization("engineering-prod-demo", "default"),
-			newKustomization("engineering-preprod-demo", "default"),
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo", "engineering-prod-demo", "engineering-preprod-demo")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		blocked := apimeta.FindStatusCondition(updated.Status.Conditions, sourcev1alpha1.DeletionsBlockedCondition)
+		if blocked == nil || blocked.Status != metav1.ConditionTrue {
+			t.Fatalf("got condition %v, want DeletionsBlocked", blocked)
+		}
+		events := drainEvents(recorder)
+		if len(events) != 1 || !strings.HasPrefix(events[0], "Warning MaxDeletionsExceeded 2 resources that are no longer generated would be deleted") {
+			t.Fatalf("got events %v, want a single MaxDeletionsExceeded warning", events)
+		}
+
+		digest := blocked.Message[strings.LastIndex(blocked.Message, "=")+1 : strings.LastIndex(blocked.Message, " to delete them")]
+		updated.SetAnnotations(map[string]string{sourcev1alpha1.ApproveDeletionsAnnotation: digest})
+		if err := k8sClient.Update(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		_, err = eventsReconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assertKustomizationsExist(t, k8sClient, "default", "engineering-dev-demo")
+		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
+			t.Fatal(err)
+		}
+		if blocked := apimeta.FindStatusCondition(updated.Status.Conditions, sourcev1alpha1.DeletionsBlockedCondition); blocked != nil {
+			t.Fatalf("got condition %v, want no DeletionsBlocked condition", blocked)
+		}
+		if l := len(updated.Status.PendingDeletions); l != 0 {
+			t.Fatalf("got %d pending deletions, want 0", l)
 		}
-		assertInventoryHasItems(t, updated, want...)
-		assertResourceDoesNotExist(t, k8sClient, devKS)
 	})
 
-	t.Run("reconciling update of resources", func(t *testing.T) {
+	t.Run("reconciling records spans", func(t *testing.T) {
 		ctx := context.TODO()
-		devKS := newKustomization("engineering-dev-demo", "default", func(k *kustomizev1.Kustomization) {
-			k.ObjectMeta.Annotations = map[string]string{
-				"testing": "testing",
-			}
-		})
+		exporter := test.RecordSpans(t)
+		devKS := newKustomization("engineering-dev-demo", "default")
 		kz := newKustomizationSet(func(ks *sourcev1alpha1.KustomizationSet) {
-			ks.Spec.Template.KustomizationSetTemplateMeta = sourcev1alpha1.KustomizationSetTemplateMeta{
-				Name:      `{{.cluster}}-demo`,
-				Namespace: "default",
-				Annotations: map[string]string{
-					"testing.cluster": "{{.cluster}}",
-				},
-			}
 			ks.Spec.Generators = []sourcev1alpha1.KustomizationSetGenerator{
 				{
 					List: &sourcev1alpha1.ListGenerator{
 						Elements: []apiextensionsv1.JSON{
-							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+							{Raw: []byte(`{"cluster": "engineering-prod"}`)},
 						},
 					},
 				},
 			}
 		})
-		// TODO: create and cleanup
 		if err := k8sClient.Create(ctx, kz); err != nil {
 			t.Fatal(err)
 		}
@@ -208,52 +1883,80 @@ func TestReconciliation(t *testing.T) {
 		}
 		defer deleteAllKustomizations(t, k8sClient)
 
-		objMeta, err := object.RuntimeToObjMeta(devKS)
-		if err != nil {
-			t.Fatal(err)
-		}
 		kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
 			Entries: []sourcev1alpha1.ResourceRef{
-				{
-					ID:      objMeta.String(),
-					Version: devKS.GetObjectKind().GroupVersionKind().GroupVersion().String(),
-				},
+				resourceRefFromObject(t, devKS),
 			},
 		}
 		if err := k8sClient.Status().Update(ctx, kz); err != nil {
 			t.Fatal(err)
 		}
 
-		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
+		_, err := reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kz)})
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		updated := &sourcev1alpha1.KustomizationSet{}
-		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(kz), updated); err != nil {
-			t.Fatal(err)
+		want := []string{"Generate", "RenderTemplate", "CreateResource", "DeleteResource", "Reconcile"}
+		if diff := cmp.Diff(want, test.SpanNames(exporter)); diff != "" {
+			t.Fatalf("failed to record spans:\n%s", diff)
 		}
-		wantUpdated := newKustomization("engineering-dev-demo", "default", func(k *kustomizev1.Kustomization) {
-			k.ObjectMeta.Annotations = map[string]string{
-				"testing.cluster": "engineering-dev",
+		spans := exporter.GetSpans()
+		reconcileSpan := spans[len(spans)-1]
+		for _, v := range spans[:len(spans)-1] {
+			if v.Parent.SpanID() != reconcileSpan.SpanContext.SpanID() {
+				t.Errorf("span %s is not a child of the Reconcile span", v.Name)
 			}
-			k.Spec.Path = "./clusters/engineering-dev/"
-			k.Spec.KubeConfig = &kustomizev1.KubeConfig{SecretRef: meta.SecretKeyReference{Name: "engineering-dev"}}
-		})
-		want := []runtime.Object{
-			wantUpdated,
-		}
-		assertInventoryHasItems(t, updated, want...)
-		var kustomization kustomizev1.Kustomization
-		if err := k8sClient.Get(ctx, client.ObjectKeyFromObject(wantUpdated), &kustomization); err != nil {
-			t.Fatal(err)
-		}
-		if diff := cmp.Diff(wantUpdated, &kustomization, objectMetaIgnore()...); diff != "" {
-			t.Fatalf("failed to update Kustomization:\n%s", diff)
 		}
 	})
 }
 
+// failingGenerator wraps a Generator and fails to generate parameters.
+type failingGenerator struct {
+	generators.Generator
+	err error
+}
+
+func (g failingGenerator) Generate(context.Context, *sourcev1alpha1.KustomizationSetGenerator, *sourcev1alpha1.KustomizationSet) ([]map[string]any, error) {
+	return nil, g.err
+}
+
+// drainEvents returns the events that have been recorded by the recorder.
+func drainEvents(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case e := <-recorder.Events:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func createNamespace(t *testing.T, cl client.Client, name string) {
+	t.Helper()
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if err := cl.Create(context.TODO(), ns); client.IgnoreAlreadyExists(err) != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertObjectDoesNotExist(t *testing.T, cl client.Client, obj client.Object) {
+	t.Helper()
+	if err := cl.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj); !apierrors.IsNotFound(err) {
+		t.Fatalf("object %v still exists", obj)
+	}
+}
+
+func assertKustomizationSetDoesNotExist(t *testing.T, cl client.Client, ks *sourcev1alpha1.KustomizationSet) {
+	t.Helper()
+	check := &sourcev1alpha1.KustomizationSet{}
+	if err := cl.Get(context.TODO(), client.ObjectKeyFromObject(ks), check); !apierrors.IsNotFound(err) {
+		t.Fatalf("object %v still exists", ks)
+	}
+}
+
 func deleteAllKustomizations(t *testing.T, cl client.Client) {
 	t.Helper()
 	err := cl.DeleteAllOf(context.TODO(), &kustomizev1.Kustomization{}, client.InNamespace("default"))
@@ -286,7 +1989,7 @@ func assertKustomizationsExist(t *testing.T, cl client.Client, ns string, want .
 	}(kss.Items)
 
 	sort.Strings(want)
-	if diff := cmp.Diff(want, existingNames); diff != "" {
+	if diff := cmp.Diff(want, existingNames, cmpopts.EquateEmpty()); diff != "" {
 		t.Fatalf("got different names:\n%s", diff)
 	}
 }
@@ -301,6 +2004,51 @@ func assertKustomizationCondition(t *testing.T, ks *sourcev1alpha1.Kustomization
 	}
 }
 
+func setKustomizationReady(t *testing.T, cl client.Client, name string, status metav1.ConditionStatus, revision string) {
+	t.Helper()
+	var k kustomizev1.Kustomization
+	if err := cl.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "default"}, &k); err != nil {
+		t.Fatal(err)
+	}
+	k.Status.ObservedGeneration = k.Generation
+	k.Status.LastAppliedRevision = revision
+	apimeta.SetStatusCondition(&k.Status.Conditions, metav1.Condition{
+		Type:    meta.ReadyCondition,
+		Status:  status,
+		Reason:  "Testing",
+		Message: "testing",
+	})
+	if err := cl.Status().Update(context.TODO(), &k); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertKustomizationPath(t *testing.T, cl client.Client, name, want string) {
+	t.Helper()
+	var k kustomizev1.Kustomization
+	if err := cl.Get(context.TODO(), client.ObjectKey{Name: name, Namespace: "default"}, &k); err != nil {
+		t.Fatal(err)
+	}
+	if k.Spec.Path != want {
+		t.Fatalf("got path %s for %s, want %s", k.Spec.Path, name, want)
+	}
+}
+
+func intOrStringPtr(v intstr.IntOrString) *intstr.IntOrString {
+	return &v
+}
+
+func assertConditionStatus(t *testing.T, ks *sourcev1alpha1.KustomizationSet, condType string, status metav1.ConditionStatus, reason string) {
+	t.Helper()
+	cond := apimeta.FindStatusCondition(ks.Status.Conditions, condType)
+	if cond == nil {
+		t.Fatalf("failed to find matching status condition for type %s in %#v", condType, ks.Status.Conditions)
+	}
+	if cond.Status != status || cond.Reason != reason {
+		t.Fatalf("got %s/%s, want %s/%s", cond.Status, cond.Reason, status, reason)
+	}
+}
+
 func assertInventoryHasItems(t *testing.T, ks *sourcev1alpha1.KustomizationSet, objs ...runtime.Object) {
 	t.Helper()
 	if l := len(ks.Status.Inventory.Entries); l != len(objs) {
@@ -308,14 +2056,7 @@ func assertInventoryHasItems(t *testing.T, ks *sourcev1alpha1.KustomizationSet,
 	}
 	entries := []sourcev1alpha1.ResourceRef{}
 	for _, obj := range objs {
-		objMeta, err := object.RuntimeToObjMeta(obj)
-		if err != nil {
-			t.Fatal(err)
-		}
-		entries = append(entries, sourcev1alpha1.ResourceRef{
-			ID:      objMeta.String(),
-			Version: obj.GetObjectKind().GroupVersionKind().GroupVersion().String(),
-		})
+		entries = append(entries, resourceRefFromObject(t, obj))
 	}
 	sort.Slice(entries, func(i, j int) bool {
 		return entries[i].ID < entries[j].ID
@@ -326,7 +2067,35 @@ func assertInventoryHasItems(t *testing.T, ks *sourcev1alpha1.KustomizationSet,
 	}
 }
 
+func resourceRefFromObject(t *testing.T, obj runtime.Object) sourcev1alpha1.ResourceRef {
+	t.Helper()
+	gvk, err := apiutil.GVKForObject(obj, scheme.Scheme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj = obj.DeepCopyObject()
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	objMeta, err := object.RuntimeToObjMeta(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sourcev1alpha1.ResourceRef{
+		ID:        objMeta.String(),
+		Version:   gvk.GroupVersion().String(),
+		Policy:    sourcev1alpha1.GeneratorPolicySync,
+		Generator: "0/List",
+	}
+}
+
 func cleanupResource(t *testing.T, cl client.Client, obj client.Object) {
+	if err := cl.Get(context.TODO(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		t.Fatal(err)
+	}
+	obj.SetFinalizers(nil)
+	if err := cl.Update(context.TODO(), obj); err != nil {
+		t.Fatal(err)
+	}
 	if err := cl.Delete(context.TODO(), obj); err != nil {
 		t.Fatal(err)
 	}
@@ -356,6 +2125,15 @@ func newKustomization(name, namespace string, opts ...func(*kustomizev1.Kustomiz
 	return k
 }
 
+func newConfigMap(name, namespace string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
 func newKustomizationSet(opts ...func(*sourcev1alpha1.KustomizationSet)) *sourcev1alpha1.KustomizationSet {
 	ks := &sourcev1alpha1.KustomizationSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -374,7 +2152,7 @@ func newKustomizationSet(opts ...func(*sourcev1alpha1.KustomizationSet)) *source
 					},
 				},
 			},
-			Template: sourcev1alpha1.KustomizationSetTemplate{
+			Template: &sourcev1alpha1.KustomizationSetTemplate{
 				KustomizationSetTemplateMeta: sourcev1alpha1.KustomizationSetTemplateMeta{
 					Name:      `{{.cluster}}-demo`,
 					Namespace: "default",
@@ -404,7 +2182,47 @@ func newKustomizationSet(opts ...func(*sourcev1alpha1.KustomizationSet)) *source
 
 func objectMetaIgnore() []cmp.Option {
 	return []cmp.Option{
+		cmpopts.IgnoreTypes(metav1.TypeMeta{}),
 		cmpopts.IgnoreFields(metav1.ObjectMeta{}, "UID", "ResourceVersion", "Generation", "CreationTimestamp", "ManagedFields"),
 		cmpopts.IgnoreFields(kustomizev1.KustomizationStatus{}, "ObservedGeneration"),
 	}
 }
+
+func TestReasonForError(t *testing.T) {
+	reasonTests := []struct {
+		err  error
+		want string
+	}{
+		{&reconciler.GenerateError{Err: errors.New("failed")}, sourcev1alpha1.GenerationFailedReason},
+		{&reconciler.GenerateError{Err: fmt.Errorf("not ready: %w", generators.SourceNotReadyError)}, sourcev1alpha1.SourceNotReadyReason},
+		{&reconciler.RenderError{Err: errors.New("failed")}, sourcev1alpha1.RenderFailedReason},
+		{&specError{Err: errors.New("invalid maxDeletions")}, sourcev1alpha1.RenderFailedReason},
+		{&reconciler.DuplicateError{SetName: "test"}, sourcev1alpha1.DuplicateResourcesReason},
+		{errors.New("failed to create Kustomization"), sourcev1alpha1.ApplyFailedReason},
+		{fmt.Errorf("failed to apply: %w", apierrors.NewConflict(schema.GroupResource{}, "test", errors.New("conflict"))), sourcev1alpha1.ApplyConflictReason},
+	}
+
+	for _, tt := range reasonTests {
+		if got := reasonForError(tt.err); got != tt.want {
+			t.Errorf("reasonForError(%v) got %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestInventoryEntries_uses_the_version_of_the_set(t *testing.T) {
+	kz := newKustomizationSet()
+	kz.Spec.KustomizationVersion = sourcev1alpha1.KustomizationVersionV1Beta2
+	kz.Status.Inventory = &sourcev1alpha1.ResourceInventory{
+		Entries: []sourcev1alpha1.ResourceRef{
+			{ID: "default_engineering-dev-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2"},
+			{ID: "default_engineering-dev-repo_source.toolkit.fluxcd.io_GitRepository", Version: "source.toolkit.fluxcd.io/v1beta2"},
+		},
+	}
+
+	// The controller generates v1 Kustomizations by default.
+	entries := inventoryEntries(kz, reconciler.KustomizationVersion(kz, sourcev1alpha1.KustomizationVersionV1))
+
+	if diff := cmp.Diff(kz.Status.Inventory.Entries, entries); diff != "" {
+		t.Fatalf("failed to get inventory entries:\n%s", diff)
+	}
+}
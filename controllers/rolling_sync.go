@@ -0,0 +1,154 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+var defaultMaxUpdate = intstr.FromString("100%")
+
+// existingResource is a generated resource that already exists in the
+// cluster.
+type existingResource struct {
+	generated *unstructured.Unstructured
+	existing  *unstructured.Unstructured
+
+	// outOfDate is true when the existing resource doesn't match the
+	// generated resource.
+	outOfDate bool
+
+	// ready is true when the existing resource is a Kustomization that is
+	// Ready, or is not a Kustomization.
+	ready bool
+
+	// failed is true when the existing resource is a Kustomization that has
+	// reconciled its current generation and is not Ready.
+	failed bool
+}
+
+// planRollingSync selects the out of date resources that can be updated in
+// the current step of the rollout, and returns the progress of the rollout.
+//
+// A step is complete when all the resources it selects are up to date and
+// Ready, later steps are not started until the earlier steps are complete.
+//
+// Up to date resources that have failed don't count towards the maxUpdate of
+// the step, as they may have been failing before the rollout started, but
+// they block the later steps until they are fixed.
+func planRollingSync(strategy *kustomizesetv1.RollingSyncStrategy, resources []*existingResource) ([]*existingResource, *kustomizesetv1.RollingSyncStatus, error) {
+	selectors := []labels.Selector{}
+	for i, step := range strategy.Steps {
+		selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{MatchExpressions: step.MatchExpressions})
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid matchExpressions in step %d: %w", i+1, err)
+		}
+		selectors = append(selectors, selector)
+	}
+
+	// The final group holds the resources that are not selected by any step.
+	groups := make([][]*existingResource, len(strategy.Steps)+1)
+	for _, resource := range resources {
+		idx := len(strategy.Steps)
+		for i, selector := range selectors {
+			if selector.Matches(labels.Set(resource.generated.GetLabels())) {
+				idx = i
+				break
+			}
+		}
+		groups[idx] = append(groups[idx], resource)
+	}
+
+	updates := []*existingResource{}
+	status := &kustomizesetv1.RollingSyncStatus{}
+	blocked := false
+	for i, group := range groups {
+		if i == len(strategy.Steps) && len(group) == 0 {
+			continue
+		}
+
+		outOfDate := []*existingResource{}
+		updating, failed := 0, 0
+		for _, resource := range group {
+			switch {
+			case resource.outOfDate:
+				outOfDate = append(outOfDate, resource)
+			case resource.failed:
+				failed++
+			case !resource.ready:
+				updating++
+			}
+		}
+		stepStatus := kustomizesetv1.RollingSyncStepStatus{
+			Step:      i + 1,
+			Status:    kustomizesetv1.RollingSyncStepPending,
+			Total:     len(group),
+			Updating:  updating,
+			OutOfDate: len(outOfDate),
+			Failed:    failed,
+		}
+		if blocked {
+			status.Steps = append(status.Steps, stepStatus)
+			continue
+		}
+
+		maxUpdate := &defaultMaxUpdate
+		if i < len(strategy.Steps) && strategy.Steps[i].MaxUpdate != nil {
+			maxUpdate = strategy.Steps[i].MaxUpdate
+		}
+		allowed, err := intstr.GetScaledValueFromIntOrPercent(maxUpdate, len(group), true)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid maxUpdate %s in step %d: %w", maxUpdate.String(), i+1, err)
+		}
+		// A step that allows no updates would never complete.
+		if allowed < 1 {
+			allowed = 1
+		}
+		toUpdate := allowed - updating
+		if toUpdate > len(outOfDate) {
+			toUpdate = len(outOfDate)
+		}
+		if toUpdate > 0 {
+			updates = append(updates, outOfDate[:toUpdate]...)
+			stepStatus.Updating += toUpdate
+			stepStatus.OutOfDate -= toUpdate
+		}
+
+		switch {
+		case stepStatus.Updating != 0 || stepStatus.OutOfDate != 0:
+			stepStatus.Status = kustomizesetv1.RollingSyncStepProgressing
+		case stepStatus.Failed != 0:
+			stepStatus.Status = kustomizesetv1.RollingSyncStepBlocked
+		default:
+			stepStatus.Status = kustomizesetv1.RollingSyncStepComplete
+		}
+		if stepStatus.Status != kustomizesetv1.RollingSyncStepComplete {
+			status.CurrentStep = i + 1
+			blocked = true
+		}
+		status.Steps = append(status.Steps, stepStatus)
+	}
+
+	return updates, status, nil
+}
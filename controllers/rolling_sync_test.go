@@ -0,0 +1,302 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	sourcev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+func TestPlanRollingSync(t *testing.T) {
+	envSteps := []sourcev1alpha1.RollingSyncStep{
+		{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"dev"}}}},
+		{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod"}}},
+			MaxUpdate:        intOrStringPtr(intstr.FromInt(1)),
+		},
+	}
+
+	planTests := []struct {
+		name        string
+		steps       []sourcev1alpha1.RollingSyncStep
+		resources   []*existingResource
+		wantUpdates []string
+		wantStatus  *sourcev1alpha1.RollingSyncStatus
+	}{
+		{
+			name:  "all resources up to date",
+			steps: envSteps,
+			resources: []*existingResource{
+				newTestResource("dev-1", "dev", false, true),
+				newTestResource("prod-1", "prod", false, true),
+			},
+			wantUpdates: []string{},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepComplete, Total: 1},
+					{Step: 2, Status: sourcev1alpha1.RollingSyncStepComplete, Total: 1},
+				},
+			},
+		},
+		{
+			name:  "first step is updated",
+			steps: envSteps,
+			resources: []*existingResource{
+				newTestResource("dev-1", "dev", true, true),
+				newTestResource("dev-2", "dev", true, true),
+				newTestResource("prod-1", "prod", true, true),
+			},
+			wantUpdates: []string{"dev-1", "dev-2"},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				CurrentStep: 1,
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepProgressing, Total: 2, Updating: 2},
+					{Step: 2, Status: sourcev1alpha1.RollingSyncStepPending, Total: 1, OutOfDate: 1},
+				},
+			},
+		},
+		{
+			name:  "waiting for the first step to be ready",
+			steps: envSteps,
+			resources: []*existingResource{
+				newTestResource("dev-1", "dev", false, false),
+				newTestResource("prod-1", "prod", true, true),
+			},
+			wantUpdates: []string{},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				CurrentStep: 1,
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepProgressing, Total: 1, Updating: 1},
+					{Step: 2, Status: sourcev1alpha1.RollingSyncStepPending, Total: 1, OutOfDate: 1},
+				},
+			},
+		},
+		{
+			name:  "max update limits the updates in a step",
+			steps: envSteps,
+			resources: []*existingResource{
+				newTestResource("dev-1", "dev", false, true),
+				newTestResource("prod-1", "prod", true, true),
+				newTestResource("prod-2", "prod", true, true),
+			},
+			wantUpdates: []string{"prod-1"},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				CurrentStep: 2,
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepComplete, Total: 1},
+					{Step: 2, Status: sourcev1alpha1.RollingSyncStepProgressing, Total: 2, Updating: 1, OutOfDate: 1},
+				},
+			},
+		},
+		{
+			name:  "max update counts resources that are not ready",
+			steps: envSteps,
+			resources: []*existingResource{
+				newTestResource("prod-1", "prod", false, false),
+				newTestResource("prod-2", "prod", true, true),
+			},
+			wantUpdates: []string{},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				CurrentStep: 2,
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepComplete},
+					{Step: 2, Status: sourcev1alpha1.RollingSyncStepProgressing, Total: 2, Updating: 1, OutOfDate: 1},
+				},
+			},
+		},
+		{
+			name: "failed resources don't count towards max update",
+			steps: []sourcev1alpha1.RollingSyncStep{
+				{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"dev"}}},
+					MaxUpdate:        intOrStringPtr(intstr.FromInt(1)),
+				},
+			},
+			resources: []*existingResource{
+				newFailedTestResource("dev-1", "dev"),
+				newTestResource("dev-2", "dev", true, true),
+			},
+			wantUpdates: []string{"dev-2"},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				CurrentStep: 1,
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepProgressing, Total: 2, Updating: 1, Failed: 1},
+				},
+			},
+		},
+		{
+			name:  "failed resources block later steps",
+			steps: envSteps,
+			resources: []*existingResource{
+				newFailedTestResource("dev-1", "dev"),
+				newTestResource("dev-2", "dev", false, true),
+				newTestResource("prod-1", "prod", true, true),
+			},
+			wantUpdates: []string{},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				CurrentStep: 1,
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepBlocked, Total: 2, Failed: 1},
+					{Step: 2, Status: sourcev1alpha1.RollingSyncStepPending, Total: 1, OutOfDate: 1},
+				},
+			},
+		},
+		{
+			name:  "resources not selected by a step are updated last",
+			steps: envSteps,
+			resources: []*existingResource{
+				newTestResource("dev-1", "dev", false, true),
+				newTestResource("prod-1", "prod", false, true),
+				newTestResource("test-1", "test", true, true),
+			},
+			wantUpdates: []string{"test-1"},
+			wantStatus: &sourcev1alpha1.RollingSyncStatus{
+				CurrentStep: 3,
+				Steps: []sourcev1alpha1.RollingSyncStepStatus{
+					{Step: 1, Status: sourcev1alpha1.RollingSyncStepComplete, Total: 1},
+					{Step: 2, Status: sourcev1alpha1.RollingSyncStepComplete, Total: 1},
+					{Step: 3, Status: sourcev1alpha1.RollingSyncStepProgressing, Total: 1, Updating: 1},
+				},
+			},
+		},
+	}
+
+	for _, tt := range planTests {
+		t.Run(tt.name, func(t *testing.T) {
+			updates, status, err := planRollingSync(&sourcev1alpha1.RollingSyncStrategy{Steps: tt.steps}, tt.resources)
+			test.AssertNoError(t, err)
+
+			names := []string{}
+			for _, v := range updates {
+				names = append(names, v.generated.GetName())
+			}
+			if diff := cmp.Diff(tt.wantUpdates, names); diff != "" {
+				t.Fatalf("failed to plan updates:\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantStatus, status); diff != "" {
+				t.Fatalf("failed to get status:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPlanRollingSync_invalid_expression(t *testing.T) {
+	steps := []sourcev1alpha1.RollingSyncStep{
+		{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Unknown"}}},
+	}
+
+	_, _, err := planRollingSync(&sourcev1alpha1.RollingSyncStrategy{Steps: steps}, nil)
+
+	test.MatchErrorString(t, "invalid matchExpressions in step 1: .*", err)
+}
+
+func TestIsOutOfDate(t *testing.T) {
+	existing := newTestUnstructured("test", map[string]string{"env": "dev", "team": "a"})
+	existing.Object["spec"] = map[string]any{
+		"path":      "./dev",
+		"force":     false,
+		"dependsOn": []any{map[string]any{"name": "infra"}},
+	}
+	// The controller applied the team label, spec.path and spec.dependsOn,
+	// the env label and spec.force are owned by other field managers.
+	setAppliedFields(existing, fieldManager, `{"f:metadata":{"f:labels":{"f:team":{}}},"f:spec":{"f:path":{},"f:dependsOn":{}}}`)
+
+	outOfDateTests := []struct {
+		name   string
+		labels map[string]string
+		spec   map[string]any
+		want   bool
+	}{
+		{"matching fields", map[string]string{"team": "a"}, map[string]any{"path": "./dev", "dependsOn": []any{map[string]any{"name": "infra"}}}, false},
+		{"changed field", map[string]string{"team": "a"}, map[string]any{"path": "./prod", "dependsOn": []any{map[string]any{"name": "infra"}}}, true},
+		{"changed label", map[string]string{"team": "b"}, map[string]any{"path": "./dev", "dependsOn": []any{map[string]any{"name": "infra"}}}, true},
+		{"new field", map[string]string{"team": "a"}, map[string]any{"path": "./dev", "dependsOn": []any{map[string]any{"name": "infra"}}, "prune": true}, true},
+		{"removed applied field", map[string]string{"team": "a"}, map[string]any{"path": "./dev"}, true},
+		{"removed applied label", nil, map[string]any{"path": "./dev", "dependsOn": []any{map[string]any{"name": "infra"}}}, true},
+	}
+
+	for _, tt := range outOfDateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated := newTestUnstructured("test", tt.labels)
+			generated.Object["spec"] = tt.spec
+
+			got, err := isOutOfDate(generated, existing)
+			test.AssertNoError(t, err)
+
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsOutOfDate_not_applied(t *testing.T) {
+	existing := newTestUnstructured("test", map[string]string{"env": "dev"})
+	existing.Object["spec"] = map[string]any{"path": "./dev", "force": false}
+	generated := newTestUnstructured("test", nil)
+	generated.Object["spec"] = map[string]any{"path": "./dev"}
+
+	got, err := isOutOfDate(generated, existing)
+	test.AssertNoError(t, err)
+
+	if got {
+		t.Fatal("got out of date, want fields that weren't applied to be ignored")
+	}
+}
+
+func newTestResource(name, env string, outOfDate, ready bool) *existingResource {
+	return &existingResource{
+		generated: newTestUnstructured(name, map[string]string{"env": env}),
+		existing:  newTestUnstructured(name, map[string]string{"env": env}),
+		outOfDate: outOfDate,
+		ready:     ready,
+	}
+}
+
+func newFailedTestResource(name, env string) *existingResource {
+	resource := newTestResource(name, env, false, false)
+	resource.failed = true
+	return resource
+}
+
+func setAppliedFields(u *unstructured.Unstructured, manager, fields string) {
+	u.SetManagedFields([]metav1.ManagedFieldsEntry{
+		{
+			Manager:    manager,
+			Operation:  metav1.ManagedFieldsOperationApply,
+			APIVersion: u.GetAPIVersion(),
+			FieldsType: "FieldsV1",
+			FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+		},
+	})
+}
+
+func newTestUnstructured(name string, labels map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{}}
+	u.SetAPIVersion("kustomize.toolkit.fluxcd.io/v1beta2")
+	u.SetKind("Kustomization")
+	u.SetName(name)
+	u.SetNamespace("default")
+	u.SetLabels(labels)
+	return u
+}
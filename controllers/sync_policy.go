@@ -0,0 +1,172 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// deletionPlan is the result of applying the SyncPolicy to the resources that
+// are no longer generated.
+type deletionPlan struct {
+	// deletions are the resources to delete now.
+	deletions []kustomizesetv1.ResourceRef
+
+	// pending are the resources that are kept in the inventory.
+	pending []kustomizesetv1.PendingDeletion
+
+	// requeueAfter is when the pending resources should be checked again,
+	// this is zero if they can only be removed by a change to the generated
+	// resources.
+	requeueAfter time.Duration
+
+	// blocked are the resources that were not deleted because there were
+	// more than the maximum number of deletions, these are also pending.
+	blocked []kustomizesetv1.ResourceRef
+
+	// blockedDigest identifies the blocked deletions, the deletions are
+	// approved by annotating the KustomizationSet with the digest.
+	blockedDigest string
+}
+
+// planDeletions applies the SyncPolicy to the resources that are no longer
+// generated.
+//
+// generated is the number of resources generated by each generator of the
+// KustomizationSet, keyed by the generator ID, and the previously pending
+// deletions are used to determine how long resources have been waiting to
+// be deleted.
+//
+// managed is the number of resources in the inventory that are still
+// generated and that the generator policy allows to be deleted, a
+// percentage MaxDeletions is scaled against these and the resources that
+// could be deleted now, resources that are retained or kept pending are not
+// counted.
+//
+// If more resources would be deleted than the MaxDeletions allows, none of
+// them are deleted unless approved is the digest of the deletions.
+func planDeletions(policy *kustomizesetv1.KustomizationSetSyncPolicy, generated map[string]int, managed int, candidates []kustomizesetv1.ResourceRef, previous []kustomizesetv1.PendingDeletion, approved string, now time.Time) (*deletionPlan, error) {
+	plan := &deletionPlan{}
+	if policy == nil {
+		plan.deletions = candidates
+		return plan, nil
+	}
+
+	since := map[string]metav1.Time{}
+	for _, v := range previous {
+		since[v.ID] = v.Since
+	}
+	pendingSince := func(ref kustomizesetv1.ResourceRef) kustomizesetv1.PendingDeletion {
+		t, ok := since[ref.ID]
+		if !ok {
+			t = metav1.NewTime(now)
+		}
+		return kustomizesetv1.PendingDeletion{ResourceRef: ref, Since: t}
+	}
+
+	eligible := []kustomizesetv1.ResourceRef{}
+	for _, ref := range candidates {
+		pending := pendingSince(ref)
+		if policy.PreserveOnEmpty && isFromEmptyGenerator(ref, generated) {
+			plan.pending = append(plan.pending, pending)
+			continue
+		}
+		if policy.DeletionGracePeriod != nil {
+			if remaining := policy.DeletionGracePeriod.Duration - now.Sub(pending.Since.Time); remaining > 0 {
+				plan.pending = append(plan.pending, pending)
+				plan.requeue(remaining)
+				continue
+			}
+		}
+		eligible = append(eligible, ref)
+	}
+
+	if policy.MaxDeletions != nil {
+		limit, err := intstr.GetScaledValueFromIntOrPercent(policy.MaxDeletions, managed+len(eligible), true)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxDeletions %s: %w", policy.MaxDeletions.String(), err)
+		}
+		if digest := deletionsDigest(eligible); len(eligible) > limit && digest != approved {
+			for _, ref := range eligible {
+				plan.pending = append(plan.pending, pendingSince(ref))
+			}
+			plan.blocked = eligible
+			plan.blockedDigest = digest
+			eligible = nil
+		}
+	}
+	plan.deletions = eligible
+
+	return plan, nil
+}
+
+// blockedDeletionsMessage describes the blocked deletions and how to
+// approve them, or returns an empty string if no deletions are blocked.
+func blockedDeletionsMessage(plan *deletionPlan) string {
+	if len(plan.blocked) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d resources that are no longer generated would be deleted, more than maxDeletions allows, annotate with %s=%s to delete them",
+		len(plan.blocked), kustomizesetv1.ApproveDeletionsAnnotation, plan.blockedDigest)
+}
+
+// deletionsDigest returns a short digest of the IDs of the resources.
+func deletionsDigest(refs []kustomizesetv1.ResourceRef) string {
+	ids := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ids = append(ids, ref.ID)
+	}
+	sort.Strings(ids)
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(ids, "\n"))))[:16]
+}
+
+// isFromEmptyGenerator returns true if the generator that generated the
+// resource generated nothing.
+//
+// Resources recorded without a generator are only considered to be from an
+// empty generator when none of the generators generated anything, resources
+// from generators that were removed from the KustomizationSet never are.
+func isFromEmptyGenerator(ref kustomizesetv1.ResourceRef, generated map[string]int) bool {
+	if ref.Generator != "" {
+		count, ok := generated[ref.Generator]
+		return ok && count == 0
+	}
+	for _, count := range generated {
+		if count > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *deletionPlan) requeue(d time.Duration) {
+	if p.requeueAfter == 0 || d < p.requeueAfter {
+		p.requeueAfter = d
+	}
+}
@@ -0,0 +1,177 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	sourcev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+func TestPlanDeletions(t *testing.T) {
+	now := time.Date(2022, time.November, 1, 10, 0, 0, 0, time.UTC)
+	refs := []sourcev1alpha1.ResourceRef{
+		{ID: "default_dev-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2", Generator: "0/List"},
+		{ID: "default_prod-demo_kustomize.toolkit.fluxcd.io_Kustomization", Version: "kustomize.toolkit.fluxcd.io/v1beta2", Generator: "1/PullRequest"},
+	}
+	pending := func(ref sourcev1alpha1.ResourceRef, since time.Time) sourcev1alpha1.PendingDeletion {
+		return sourcev1alpha1.PendingDeletion{ResourceRef: ref, Since: metav1.NewTime(since)}
+	}
+
+	planTests := []struct {
+		name      string
+		policy    *sourcev1alpha1.KustomizationSetSyncPolicy
+		generated map[string]int
+		managed   int
+		previous  []sourcev1alpha1.PendingDeletion
+		approved  string
+		want      *deletionPlan
+	}{
+		{
+			name:      "no sync policy",
+			generated: map[string]int{"0/List": 0, "1/PullRequest": 0},
+			want:      &deletionPlan{deletions: refs},
+		},
+		{
+			name:      "preserve on empty with no generated resources",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{PreserveOnEmpty: true},
+			generated: map[string]int{"0/List": 0, "1/PullRequest": 0},
+			want: &deletionPlan{
+				pending: []sourcev1alpha1.PendingDeletion{pending(refs[0], now), pending(refs[1], now)},
+			},
+		},
+		{
+			name:      "preserve on empty with generated resources",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{PreserveOnEmpty: true},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 1},
+			want:      &deletionPlan{deletions: refs},
+		},
+		{
+			name:      "preserve on empty with an empty generator",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{PreserveOnEmpty: true},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 0},
+			want: &deletionPlan{
+				deletions: refs[:1],
+				pending:   []sourcev1alpha1.PendingDeletion{pending(refs[1], now)},
+			},
+		},
+		{
+			name:      "preserve on empty with a removed generator",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{PreserveOnEmpty: true},
+			generated: map[string]int{"0/List": 1},
+			want:      &deletionPlan{deletions: refs},
+		},
+		{
+			name:      "within the deletion grace period",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{DeletionGracePeriod: &metav1.Duration{Duration: 10 * time.Minute}},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 0},
+			previous:  []sourcev1alpha1.PendingDeletion{pending(refs[0], now.Add(-4*time.Minute))},
+			want: &deletionPlan{
+				pending:      []sourcev1alpha1.PendingDeletion{pending(refs[0], now.Add(-4*time.Minute)), pending(refs[1], now)},
+				requeueAfter: 6 * time.Minute,
+			},
+		},
+		{
+			name:      "after the deletion grace period",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{DeletionGracePeriod: &metav1.Duration{Duration: 10 * time.Minute}},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 0},
+			previous:  []sourcev1alpha1.PendingDeletion{pending(refs[0], now.Add(-10*time.Minute))},
+			want: &deletionPlan{
+				deletions:    refs[:1],
+				pending:      []sourcev1alpha1.PendingDeletion{pending(refs[1], now)},
+				requeueAfter: 10 * time.Minute,
+			},
+		},
+		{
+			name:      "more than the maximum deletions",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{MaxDeletions: intOrStringPtr(intstr.FromInt(1))},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 0},
+			want: &deletionPlan{
+				pending:       []sourcev1alpha1.PendingDeletion{pending(refs[0], now), pending(refs[1], now)},
+				blocked:       refs,
+				blockedDigest: deletionsDigest(refs),
+			},
+		},
+		{
+			name:      "more than the maximum deletions with approval",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{MaxDeletions: intOrStringPtr(intstr.FromInt(1))},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 0},
+			approved:  deletionsDigest(refs),
+			want:      &deletionPlan{deletions: refs},
+		},
+		{
+			name:      "more than the maximum deletions with a different approval",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{MaxDeletions: intOrStringPtr(intstr.FromInt(1))},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 0},
+			approved:  deletionsDigest(refs[:1]),
+			want: &deletionPlan{
+				pending:       []sourcev1alpha1.PendingDeletion{pending(refs[0], now), pending(refs[1], now)},
+				blocked:       refs,
+				blockedDigest: deletionsDigest(refs),
+			},
+		},
+		{
+			name:      "maximum deletions as a percentage",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{MaxDeletions: intOrStringPtr(intstr.FromString("50%"))},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 1},
+			managed:   2,
+			want:      &deletionPlan{deletions: refs},
+		},
+		{
+			name:      "maximum deletions as a percentage of the resources that could be deleted",
+			policy:    &sourcev1alpha1.KustomizationSetSyncPolicy{MaxDeletions: intOrStringPtr(intstr.FromString("50%"))},
+			generated: map[string]int{"0/List": 0, "1/PullRequest": 0},
+			want: &deletionPlan{
+				pending:       []sourcev1alpha1.PendingDeletion{pending(refs[0], now), pending(refs[1], now)},
+				blocked:       refs,
+				blockedDigest: deletionsDigest(refs),
+			},
+		},
+		{
+			name: "maximum deletions as a percentage with pending deletions",
+			policy: &sourcev1alpha1.KustomizationSetSyncPolicy{
+				MaxDeletions:        intOrStringPtr(intstr.FromString("50%")),
+				DeletionGracePeriod: &metav1.Duration{Duration: 10 * time.Minute},
+			},
+			generated: map[string]int{"0/List": 1, "1/PullRequest": 0},
+			managed:   1,
+			previous:  []sourcev1alpha1.PendingDeletion{pending(refs[0], now.Add(-10*time.Minute))},
+			want: &deletionPlan{
+				deletions:    refs[:1],
+				pending:      []sourcev1alpha1.PendingDeletion{pending(refs[1], now)},
+				requeueAfter: 10 * time.Minute,
+			},
+		},
+	}
+
+	for _, tt := range planTests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planDeletions(tt.policy, tt.generated, tt.managed, refs, tt.previous, tt.approved, now)
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.want, plan, cmp.AllowUnexported(deletionPlan{}), cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("failed to plan deletions:\n%s", diff)
+			}
+		})
+	}
+}
@@ -17,7 +17,9 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -25,19 +27,23 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/pkg/runtime/events"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	kustomizev1alpha1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	kustomizev1alpha2 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha2"
 	"github.com/gitops-tools/kustomization-set-controller/controllers"
-	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
-	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/gitrepository"
-	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/list"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/config"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/logging"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/tracing"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/validation"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -52,6 +58,7 @@ func init() {
 	utilruntime.Must(kustomizev1.AddToScheme(scheme))
 	utilruntime.Must(sourcev1.AddToScheme(scheme))
 	utilruntime.Must(kustomizev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(kustomizev1alpha2.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -59,45 +66,125 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var kustomizationVersion string
+	var eventsAddr string
+	var configFile string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	// TODO: provide configuration options!
-	opts := zap.Options{
-		Development: true,
-	}
-	opts.BindFlags(flag.CommandLine)
+	flag.StringVar(&kustomizationVersion, "kustomization-version", "",
+		"The version of the Kustomization API to generate (v1 or v1beta2). "+
+			"If not provided, this is detected from the versions served by the cluster.")
+	flag.StringVar(&eventsAddr, "events-addr", "",
+		"The address of a Flux notification-controller events receiver, events are always recorded in the cluster.")
+	tracingOpts := tracing.Options{ServiceName: "kustomization-set-controller"}
+	tracingOpts.BindFlags(flag.CommandLine)
+	var loggingOpts logging.Options
+	loggingOpts.BindFlags(flag.CommandLine)
+	var controllerOpts config.Options
+	controllerOpts.BindFlags(flag.CommandLine)
+	flag.StringVar(&configFile, "config", "",
+		"The path to a ControllerConfig file, flags set on the command-line take precedence over the file.")
 	flag.Parse()
 
-	zapLog := zap.New(zap.UseFlagOptions(&opts))
-	ctrl.SetLogger(zapLog)
+	if configFile != "" {
+		if err := config.LoadFile(flag.CommandLine, configFile); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration file: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	logger, err := logging.New(loggingOpts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid logging configuration: %s\n", err)
+		os.Exit(1)
+	}
+	ctrl.SetLogger(logger)
+
+	if err := controllerOpts.Validate(); err != nil {
+		setupLog.Error(err, "invalid controller configuration")
+		os.Exit(1)
+	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+
+	mgrOpts := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "2e8756a4.gitops.solutions",
-	})
+	}
+	cfg := ctrl.GetConfigOrDie()
+	if controllerOpts.WatchNamespaceSelector != "" {
+		c, err := client.New(cfg, client.Options{Scheme: scheme})
+		if err != nil {
+			setupLog.Error(err, "unable to create client")
+			os.Exit(1)
+		}
+		if err := controllerOpts.ResolveNamespaces(context.Background(), c); err != nil {
+			setupLog.Error(err, "unable to resolve the watched namespaces")
+			os.Exit(1)
+		}
+	}
+	if err := controllerOpts.ApplyToManager(&mgrOpts); err != nil {
+		setupLog.Error(err, "invalid controller configuration")
+		os.Exit(1)
+	}
+	setupLog.Info("watching KustomizationSets", "namespaces", controllerOpts.WatchNamespaces,
+		"namespaceSelector", controllerOpts.WatchNamespaceSelector, "generators", controllerOpts.Generators)
+
+	mgr, err := ctrl.NewManager(cfg, mgrOpts)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	switch kustomizationVersion {
+	case "", kustomizev1alpha1.KustomizationVersionV1, kustomizev1alpha1.KustomizationVersionV1Beta2:
+	default:
+		setupLog.Error(fmt.Errorf("unsupported version %q", kustomizationVersion), "invalid Kustomization API version")
+		os.Exit(1)
+	}
+	if kustomizationVersion == "" {
+		kustomizationVersion, err = controllers.DetectKustomizationVersion(discovery.NewDiscoveryClientForConfigOrDie(cfg))
+		if err != nil {
+			setupLog.Error(err, "unable to detect the Kustomization API version")
+			os.Exit(1)
+		}
+	}
+	setupLog.Info("generating Kustomizations", "version", kustomizationVersion)
+
+	eventRecorder, err := events.NewRecorder(mgr, logger, eventsAddr, "kustomization-set-controller")
+	if err != nil {
+		setupLog.Error(err, "unable to create event recorder")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.KustomizationSetReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
-		Generators: map[string]generators.Generator{
-			"List":          list.NewGenerator(),
-			"GitRepository": gitrepository.NewGenerator(zapLog, mgr.GetClient()),
-		},
-	}).SetupWithManager(mgr); err != nil {
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		Generators:           controllerOpts.NewGenerators(mgr.GetClient()),
+		KustomizationVersion: kustomizationVersion,
+		EventRecorder:        eventRecorder,
+		WatchNamespaces:      controllerOpts.WatchNamespaces,
+	}).SetupWithManager(mgr, controllerOpts.ReconcilerOptions()); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "KustomizationSet")
 		os.Exit(1)
 	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&validation.KustomizationSetValidator{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "KustomizationSet")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -114,4 +201,7 @@ func main() {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+	if err := shutdownTracing(context.Background()); err != nil {
+		setupLog.Error(err, "failed to export traces")
+	}
 }
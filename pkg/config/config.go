@@ -0,0 +1,222 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/gitops-tools/kustomization-set-controller/controllers"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/gitrepository"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/list"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators/pullrequest"
+)
+
+// availableGenerators creates the generators that can be enabled, keyed by
+// the name of the field that configures them in a KustomizationSet.
+var availableGenerators = map[string]func(client.Client) generators.Generator{
+	"List": func(client.Client) generators.Generator {
+		return list.NewGenerator()
+	},
+	"GitRepository": func(c client.Client) generators.Generator {
+		return gitrepository.NewGenerator(c)
+	},
+	"PullRequest": func(c client.Client) generators.Generator {
+		return pullrequest.NewGenerator(c)
+	},
+}
+
+// Options are the tunables for the controller.
+type Options struct {
+	// Concurrency is the number of KustomizationSets that are reconciled at
+	// the same time.
+	Concurrency int
+
+	// MinRetryDelay and MaxRetryDelay bound the exponential backoff when
+	// requeueing KustomizationSets that failed to reconcile.
+	MinRetryDelay time.Duration
+	MaxRetryDelay time.Duration
+
+	// CacheSyncTimeout is how long to wait for the caches to sync when the
+	// controller starts.
+	CacheSyncTimeout time.Duration
+
+	// WatchNamespaces restricts the controller to KustomizationSets in these
+	// namespaces, if this is empty all namespaces are watched.
+	WatchNamespaces []string
+
+	// WatchNamespaceSelector restricts the controller to KustomizationSets
+	// in the namespaces that match the label selector, the namespaces are
+	// resolved into WatchNamespaces by ResolveNamespaces.
+	WatchNamespaceSelector string
+
+	// Generators are the names of the enabled generators.
+	Generators []string
+}
+
+// BindFlags binds the flags that configure the controller.
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.IntVar(&o.Concurrency, "concurrent", 1,
+		"The number of KustomizationSets that are reconciled at the same time.")
+	fs.DurationVar(&o.MinRetryDelay, "min-retry-delay", 5*time.Millisecond,
+		"The delay before the first retry of a KustomizationSet that failed to reconcile.")
+	fs.DurationVar(&o.MaxRetryDelay, "max-retry-delay", 1000*time.Second,
+		"The maximum delay between retries of a KustomizationSet that failed to reconcile.")
+	fs.DurationVar(&o.CacheSyncTimeout, "cache-sync-timeout", 2*time.Minute,
+		"How long to wait for the caches to sync when the controller starts.")
+	o.WatchNamespaces = []string{}
+	fs.Var((*stringList)(&o.WatchNamespaces), "watch-namespaces",
+		"A comma-separated list of namespaces to watch for KustomizationSets, all namespaces are watched if this is empty.")
+	fs.StringVar(&o.WatchNamespaceSelector, "watch-namespace-selector", "",
+		"Watch the namespaces that match this label selector, e.g. tenant=team-a, the namespaces are resolved when the controller starts.")
+	o.Generators = []string{"List", "GitRepository"}
+	fs.Var((*stringList)(&o.Generators), "enable-generators",
+		fmt.Sprintf("A comma-separated list of the generators to enable, from %s.", strings.Join(generatorNames(), ", ")))
+}
+
+// Validate returns an error if the options are not valid.
+func (o Options) Validate() error {
+	var errs []error
+	if o.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("concurrent must be at least 1, got %d", o.Concurrency))
+	}
+	if o.MinRetryDelay <= 0 || o.MaxRetryDelay < o.MinRetryDelay {
+		errs = append(errs, fmt.Errorf("min-retry-delay must be positive and no more than max-retry-delay, got %s and %s", o.MinRetryDelay, o.MaxRetryDelay))
+	}
+	if o.CacheSyncTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("cache-sync-timeout must be positive, got %s", o.CacheSyncTimeout))
+	}
+	if _, err := labels.Parse(o.WatchNamespaceSelector); err != nil {
+		errs = append(errs, fmt.Errorf("invalid watch-namespace-selector: %w", err))
+	}
+	if o.WatchNamespaceSelector != "" && len(o.WatchNamespaces) > 0 {
+		errs = append(errs, errors.New("only one of watch-namespaces and watch-namespace-selector can be provided"))
+	}
+	for _, v := range o.Generators {
+		if _, ok := availableGenerators[v]; !ok {
+			errs = append(errs, fmt.Errorf("unknown generator %q, must be one of %s", v, strings.Join(generatorNames(), ", ")))
+		}
+	}
+
+	return kerrors.NewAggregate(errs)
+}
+
+// ReconcilerOptions returns the options for the KustomizationSet controller.
+//
+// The rate limiter is the controller-runtime default with the configured
+// backoff.
+func (o Options) ReconcilerOptions() controllers.KustomizationSetReconcilerOptions {
+	return controllers.KustomizationSetReconcilerOptions{
+		MaxConcurrentReconciles: o.Concurrency,
+		RateLimiter: workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(o.MinRetryDelay, o.MaxRetryDelay),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+		),
+		CacheSyncTimeout: o.CacheSyncTimeout,
+	}
+}
+
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=list
+
+// ResolveNamespaces sets the watched namespaces to the namespaces that match
+// the WatchNamespaceSelector.
+//
+// The namespaces are only resolved once, namespaces that are labelled after
+// the controller starts are not watched until the controller is restarted.
+func (o *Options) ResolveNamespaces(ctx context.Context, c client.Reader) error {
+	if o.WatchNamespaceSelector == "" {
+		return nil
+	}
+	selector, err := labels.Parse(o.WatchNamespaceSelector)
+	if err != nil {
+		return fmt.Errorf("invalid watch-namespace-selector: %w", err)
+	}
+	var namespaces corev1.NamespaceList
+	if err := c.List(ctx, &namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return fmt.Errorf("failed to list the namespaces that match watch-namespace-selector: %w", err)
+	}
+	// No namespaces would watch all namespaces.
+	if len(namespaces.Items) == 0 {
+		return fmt.Errorf("no namespaces match watch-namespace-selector %q", o.WatchNamespaceSelector)
+	}
+	watched := []string{}
+	for _, v := range namespaces.Items {
+		watched = append(watched, v.GetName())
+	}
+	sort.Strings(watched)
+	o.WatchNamespaces = watched
+
+	return nil
+}
+
+// ApplyToManager restricts the manager's cache to the watched namespaces.
+func (o Options) ApplyToManager(opts *ctrl.Options) error {
+	newCache := cache.New
+	switch len(o.WatchNamespaces) {
+	case 0:
+	case 1:
+		opts.Namespace = o.WatchNamespaces[0]
+	default:
+		newCache = cache.MultiNamespacedCacheBuilder(o.WatchNamespaces)
+	}
+	opts.NewCache = newCache
+
+	return nil
+}
+
+// NewGenerators creates the enabled generators.
+func (o Options) NewGenerators(c client.Client) map[string]generators.Generator {
+	res := map[string]generators.Generator{}
+	for _, v := range o.Generators {
+		if newGenerator, ok := availableGenerators[v]; ok {
+			res[v] = newGenerator(c)
+		}
+	}
+
+	return res
+}
+
+func generatorNames() []string {
+	names := []string{}
+	for k := range availableGenerators {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// stringList is a flag.Value for a comma-separated list of strings.
+type stringList []string
+
+func (s *stringList) String() string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	values := []string{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	*s = values
+
+	return nil
+}
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+func TestBindFlags(t *testing.T) {
+	opts, fs := newOptions()
+	test.AssertNoError(t, fs.Parse([]string{
+		"--concurrent=4",
+		"--max-retry-delay=5m",
+		"--watch-namespaces=tenant-a, tenant-b",
+		"--enable-generators=List,PullRequest",
+	}))
+
+	want := Options{
+		Concurrency:      4,
+		MinRetryDelay:    5 * time.Millisecond,
+		MaxRetryDelay:    5 * time.Minute,
+		CacheSyncTimeout: 2 * time.Minute,
+		WatchNamespaces:  []string{"tenant-a", "tenant-b"},
+		Generators:       []string{"List", "PullRequest"},
+	}
+	if diff := cmp.Diff(want, *opts); diff != "" {
+		t.Fatalf("failed to parse flags:\n%s", diff)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validateTests := []struct {
+		name    string
+		opts    func(*Options)
+		wantErr string
+	}{
+		{name: "defaults", opts: func(*Options) {}},
+		{name: "no concurrency", opts: func(o *Options) { o.Concurrency = 0 }, wantErr: "concurrent must be at least 1, got 0"},
+		{name: "min retry delay greater than max", opts: func(o *Options) { o.MinRetryDelay = time.Hour }, wantErr: "min-retry-delay must be positive and no more than max-retry-delay"},
+		{name: "no cache sync timeout", opts: func(o *Options) { o.CacheSyncTimeout = 0 }, wantErr: "cache-sync-timeout must be positive"},
+		{name: "invalid namespace selector", opts: func(o *Options) { o.WatchNamespaceSelector = "tenant in (" }, wantErr: "invalid watch-namespace-selector"},
+		{name: "namespaces and namespace selector", opts: func(o *Options) {
+			o.WatchNamespaces = []string{"tenant-a"}
+			o.WatchNamespaceSelector = "tenant=team-a"
+		}, wantErr: "only one of watch-namespaces and watch-namespace-selector can be provided"},
+		{name: "unknown generator", opts: func(o *Options) { o.Generators = []string{"Cluster"} }, wantErr: `unknown generator "Cluster", must be one of GitRepository, List, PullRequest`},
+	}
+
+	for _, tt := range validateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, _ := newOptions()
+			tt.opts(opts)
+
+			err := opts.Validate()
+
+			if tt.wantErr == "" {
+				test.AssertNoError(t, err)
+				return
+			}
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestNewGenerators(t *testing.T) {
+	opts, _ := newOptions()
+
+	enabled := []string{}
+	for k := range opts.NewGenerators(fake.NewClientBuilder().Build()) {
+		enabled = append(enabled, k)
+	}
+	sort.Strings(enabled)
+
+	if diff := cmp.Diff([]string{"GitRepository", "List"}, enabled); diff != "" {
+		t.Fatalf("failed to create generators:\n%s", diff)
+	}
+}
+
+func TestApplyToManager(t *testing.T) {
+	applyTests := []struct {
+		name          string
+		namespaces    []string
+		wantNamespace string
+	}{
+		{name: "all namespaces", namespaces: []string{}},
+		{name: "single namespace", namespaces: []string{"tenant-a"}, wantNamespace: "tenant-a"},
+		{name: "multiple namespaces", namespaces: []string{"tenant-a", "tenant-b"}},
+	}
+
+	for _, tt := range applyTests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, _ := newOptions()
+			opts.WatchNamespaces = tt.namespaces
+			var mgrOpts ctrl.Options
+
+			test.AssertNoError(t, opts.ApplyToManager(&mgrOpts))
+
+			if mgrOpts.Namespace != tt.wantNamespace {
+				t.Fatalf("got namespace %q, want %q", mgrOpts.Namespace, tt.wantNamespace)
+			}
+			if mgrOpts.NewCache == nil {
+				t.Fatal("did not configure the cache")
+			}
+		})
+	}
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	c := fake.NewClientBuilder().WithObjects(
+		newNamespace("tenant-b", map[string]string{"tenant": "team-a"}),
+		newNamespace("tenant-a", map[string]string{"tenant": "team-a"}),
+		newNamespace("tenant-c", map[string]string{"tenant": "team-b"}),
+	).Build()
+
+	resolveTests := []struct {
+		name           string
+		selector       string
+		namespaces     []string
+		wantNamespaces []string
+		wantErr        string
+	}{
+		{name: "no selector", namespaces: []string{"tenant-c"}, wantNamespaces: []string{"tenant-c"}},
+		{name: "matching namespaces", selector: "tenant=team-a", wantNamespaces: []string{"tenant-a", "tenant-b"}},
+		{name: "no matching namespaces", selector: "tenant=team-c", wantErr: `no namespaces match watch-namespace-selector "tenant=team-c"`},
+	}
+
+	for _, tt := range resolveTests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, _ := newOptions()
+			opts.WatchNamespaces = tt.namespaces
+			opts.WatchNamespaceSelector = tt.selector
+
+			err := opts.ResolveNamespaces(context.TODO(), c)
+
+			if tt.wantErr != "" {
+				test.AssertErrorMatch(t, tt.wantErr, err)
+				return
+			}
+			test.AssertNoError(t, err)
+			if diff := cmp.Diff(tt.wantNamespaces, opts.WatchNamespaces); diff != "" {
+				t.Fatalf("failed to resolve namespaces:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	opts, fs := newOptions()
+	var logLevel string
+	fs.StringVar(&logLevel, "log-level", "info", "")
+	test.AssertNoError(t, fs.Parse([]string{"--concurrent=8"}))
+
+	test.AssertNoError(t, LoadFile(fs, writeFile(t, `apiVersion: config.gitops.solutions/v1alpha1
+kind: ControllerConfig
+concurrent: 2
+cache-sync-timeout: 30s
+watch-namespaces:
+  - tenant-a
+  - tenant-b
+enable-generators: [List]
+log-level: debug
+`)))
+
+	want := Options{
+		Concurrency:      8,
+		MinRetryDelay:    5 * time.Millisecond,
+		MaxRetryDelay:    1000 * time.Second,
+		CacheSyncTimeout: 30 * time.Second,
+		WatchNamespaces:  []string{"tenant-a", "tenant-b"},
+		Generators:       []string{"List"},
+	}
+	if diff := cmp.Diff(want, *opts); diff != "" {
+		t.Fatalf("failed to load file:\n%s", diff)
+	}
+	if logLevel != "debug" {
+		t.Fatalf("got log-level %q, want debug", logLevel)
+	}
+}
+
+func TestLoadFile_errors(t *testing.T) {
+	loadTests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "wrong kind",
+			content: "apiVersion: controller-runtime.sigs.k8s.io/v1alpha1\nkind: ControllerManagerConfig\n",
+			wantErr: "must have apiVersion config.gitops.solutions/v1alpha1 and kind ControllerConfig",
+		},
+		{
+			name:    "unknown option",
+			content: "apiVersion: config.gitops.solutions/v1alpha1\nkind: ControllerConfig\nconcurrency: 2\n",
+			wantErr: `unknown option "concurrency"`,
+		},
+		{
+			name:    "invalid value",
+			content: "apiVersion: config.gitops.solutions/v1alpha1\nkind: ControllerConfig\ncache-sync-timeout: soon\n",
+			wantErr: `invalid value for "cache-sync-timeout"`,
+		},
+		{
+			name:    "unsupported value",
+			content: "apiVersion: config.gitops.solutions/v1alpha1\nkind: ControllerConfig\nconcurrent:\n  value: 2\n",
+			wantErr: `invalid value for "concurrent".*unsupported value`,
+		},
+	}
+
+	for _, tt := range loadTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fs := newOptions()
+
+			err := LoadFile(fs, writeFile(t, tt.content))
+
+			test.AssertErrorMatch(t, tt.wantErr, err)
+		})
+	}
+}
+
+func TestLoadFile_missing_file(t *testing.T) {
+	_, fs := newOptions()
+
+	err := LoadFile(fs, filepath.Join(t.TempDir(), "missing.yaml"))
+
+	test.AssertErrorMatch(t, "failed to read configuration file", err)
+}
+
+func newOptions() (*Options, *flag.FlagSet) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var opts Options
+	opts.BindFlags(fs)
+
+	return &opts, fs
+}
+
+func newNamespace(name string, labels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	test.AssertNoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
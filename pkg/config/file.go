@@ -0,0 +1,103 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the apiVersion of the controller configuration file.
+	APIVersion = "config.gitops.solutions/v1alpha1"
+
+	// Kind is the kind of the controller configuration file.
+	Kind = "ControllerConfig"
+)
+
+// LoadFile sets the flags in the FlagSet from a configuration file.
+//
+// Apart from the apiVersion and kind, the keys in the file are the names of
+// flags, e.g.
+//
+//	apiVersion: config.gitops.solutions/v1alpha1
+//	kind: ControllerConfig
+//	concurrent: 4
+//	watch-namespaces:
+//	  - tenant-a
+//
+// Flags that were set on the command-line take precedence over the file.
+func LoadFile(fs *flag.FlagSet, path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+	if values["apiVersion"] != APIVersion || values["kind"] != Kind {
+		return fmt.Errorf("configuration file %s must have apiVersion %s and kind %s", path, APIVersion, Kind)
+	}
+	delete(values, "apiVersion")
+	delete(values, "kind")
+
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	names := []string{}
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown option %q in configuration file %s", name, path)
+		}
+		if explicit[name] {
+			continue
+		}
+		value, err := flagValue(values[name])
+		if err != nil {
+			return fmt.Errorf("invalid value for %q in configuration file %s: %w", name, path, err)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for %q in configuration file %s: %w", name, path, err)
+		}
+	}
+
+	return nil
+}
+
+// flagValue converts a value parsed from the configuration file to the
+// string form accepted by a flag, lists become comma-separated.
+func flagValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []interface{}:
+		items := []string{}
+		for _, item := range v {
+			s, err := flagValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	}
+
+	return "", fmt.Errorf("unsupported value %v", v)
+}
@@ -3,13 +3,19 @@ package git
 import (
 	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/fluxcd/pkg/http/fetch"
 	"github.com/fluxcd/pkg/tar"
 	kustomizationsetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
-	"github.com/go-logr/logr"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/logging"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/metrics"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/yaml"
 )
 
@@ -24,7 +30,6 @@ const retries = 9
 // resources from them.
 type RepositoryParser struct {
 	fetcher archiveFetcher
-	logr.Logger
 }
 
 // NewRepositoryParser creates and returns a RepositoryParser.
@@ -33,43 +38,65 @@ func NewRepositoryParser() *RepositoryParser {
 }
 
 // ParseFromArtifacts extracts the archive and processes the files.
-func (p *RepositoryParser) ParseFromArtifacts(ctx context.Context, archiveURL, checksum string, dirs []kustomizationsetv1.GitRepositoryGeneratorDirectoryItem) ([]map[string]any, error) {
+func (p *RepositoryParser) ParseFromArtifacts(ctx context.Context, archiveURL, checksum string, dirs []kustomizationsetv1.GitRepositoryGeneratorDirectoryItem) (_ []map[string]any, err error) {
+	_, span := tracing.Start(ctx, "ParseFromArtifacts", attribute.String("url", logging.RedactURL(archiveURL)))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	tempDir, err := os.MkdirTemp("", "parsing")
 	if err != nil {
 		return nil, fmt.Errorf("failed to create temporary directory when parsing artifacts: %w", err)
 	}
 	defer func() {
 		if err := os.RemoveAll(tempDir); err != nil {
-			p.Logger.Error(err, "failed to remove temporary archive directory")
+			log.FromContext(ctx).Error(err, "failed to remove temporary archive directory")
 		}
 	}()
 
+	start := time.Now()
 	if err := p.fetcher.Fetch(archiveURL, checksum, tempDir); err != nil {
-		return nil, fmt.Errorf("failed to get archive URL %s: %w", archiveURL, err)
+		return nil, fmt.Errorf("failed to get archive URL %s: %w", logging.RedactURL(archiveURL), logging.RedactURLError(err))
 	}
+	metrics.ArtifactDownloadDuration.Observe(time.Since(start).Seconds())
+	// The fetcher doesn't report the size of the archive, so this records
+	// the size of the extracted files.
+	size, err := directorySize(tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate the size of archive %s: %w", logging.RedactURL(archiveURL), err)
+	}
+	metrics.ArtifactDownloadSize.Observe(float64(size))
+	span.SetAttributes(attribute.Int64("size", size))
+	log.FromContext(ctx).V(1).Info("fetched artifact", "size", size, "duration", time.Since(start))
+
+	return ParseDirectories(tempDir, dirs)
+}
 
+// ParseDirectories parses the files in the directories relative to the root
+// directory, e.g. a checkout of a repository.
+func ParseDirectories(root string, dirs []kustomizationsetv1.GitRepositoryGeneratorDirectoryItem) ([]map[string]any, error) {
 	// TODO: exclude paths!
 
 	result := []map[string]any{}
 	for _, dir := range dirs {
-		files, err := os.ReadDir(filepath.Join(tempDir, dir.Path))
+		files, err := os.ReadDir(filepath.Join(root, dir.Path))
 		if err != nil {
-			return nil, fmt.Errorf("failed to read directory from archive %q: %w", dir.Path, err)
+			return nil, fmt.Errorf("failed to read directory %q: %w", dir.Path, err)
 		}
 
 		for _, file := range files {
 			// TODO: Limit this?
 			localName := filepath.Join(dir.Path, file.Name())
-			filename := filepath.Join(tempDir, localName)
+			filename := filepath.Join(root, localName)
 
 			b, err := os.ReadFile(filename)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read from archive file %s: %w", localName, err)
+				return nil, fmt.Errorf("failed to read file %s: %w", localName, err)
 			}
 
 			r := map[string]any{}
 			if err := yaml.Unmarshal(b, &r); err != nil {
-				return nil, fmt.Errorf("failed to parse archive file %s: %w", localName, err)
+				return nil, fmt.Errorf("failed to parse file %s: %w", localName, err)
 			}
 
 			result = append(result, r)
@@ -78,3 +105,21 @@ func (p *RepositoryParser) ParseFromArtifacts(ctx context.Context, archiveURL, c
 
 	return result, nil
 }
+
+// directorySize returns the total size of the files in the directory.
+func directorySize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
@@ -2,6 +2,9 @@ package git
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/url"
 	"os"
 	"sort"
 	"strings"
@@ -59,11 +62,31 @@ func TestFetchArchiveResources_bad_yaml(t *testing.T) {
 	srv := test.StartFakeArchiveServer(t, "testdata")
 
 	_, err := parser.ParseFromArtifacts(context.TODO(), srv.URL+"/bad_files.tar.gz", strings.TrimSpace(mustReadFile(t, "testdata/bad_files.tar.gz.sum")), []kustomizationsetv1.GitRepositoryGeneratorDirectoryItem{{Path: "files"}})
-	if err.Error() != `failed to parse archive file files/dev.yaml: error converting YAML to JSON: yaml: line 4: could not find expected ':'` {
+	if err.Error() != `failed to parse file files/dev.yaml: error converting YAML to JSON: yaml: line 4: could not find expected ':'` {
 		t.Fatalf("got error %v", err)
 	}
 }
 
+func TestFetchArchiveResources_redacts_credentials(t *testing.T) {
+	archiveURL := "http://secret-token@source-controller.flux-system/files.tar.gz"
+	parser := &RepositoryParser{fetcher: fetcherFunc(func(archiveURL, _, _ string) error {
+		return fmt.Errorf("failed to download archive from %s, error: %w", archiveURL, &url.Error{Op: "Get", URL: archiveURL, Err: errors.New("connection refused")})
+	})}
+
+	_, err := parser.ParseFromArtifacts(context.TODO(), archiveURL, "", []kustomizationsetv1.GitRepositoryGeneratorDirectoryItem{{Path: "files"}})
+
+	test.AssertErrorMatch(t, "failed to get archive URL http://redacted@source-controller.flux-system/files.tar.gz", err)
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("returned credentials: %s", err)
+	}
+}
+
+type fetcherFunc func(archiveURL, checksum, dir string) error
+
+func (f fetcherFunc) Fetch(archiveURL, checksum, dir string) error {
+	return f(archiveURL, checksum, dir)
+}
+
 func mustReadFile(t *testing.T, filename string) string {
 	t.Helper()
 	b, err := os.ReadFile(filename)
@@ -0,0 +1,136 @@
+package logging
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+const (
+	// EncodingJSON logs JSON objects, one per line.
+	EncodingJSON = "json"
+
+	// EncodingConsole logs human-readable lines.
+	EncodingConsole = "console"
+)
+
+// levels maps the names of log levels to zap levels, debug and trace enable
+// the logger.V(1) and logger.V(2) messages.
+var levels = map[string]zapcore.Level{
+	"trace": zapcore.DebugLevel - 1,
+	"debug": zapcore.DebugLevel,
+	"info":  zapcore.InfoLevel,
+	"error": zapcore.ErrorLevel,
+}
+
+// Options configures the controller's logger.
+type Options struct {
+	// Level is the minimum level that is logged, one of trace, debug, info
+	// or error.
+	Level string
+
+	// Encoding is the format of log messages, json or console.
+	Encoding string
+}
+
+// BindFlags binds the flags that configure the logger.
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Level, "log-level", "info",
+		"The minimum level of log messages (trace, debug, info or error).")
+	fs.StringVar(&o.Encoding, "log-encoding", EncodingJSON,
+		"The format of log messages (json or console).")
+}
+
+// New creates a logger that writes to stderr.
+func New(opts Options) (logr.Logger, error) {
+	return newLogger(opts, os.Stderr)
+}
+
+func newLogger(opts Options, w io.Writer) (logr.Logger, error) {
+	level, ok := levels[opts.Level]
+	if !ok {
+		return logr.Discard(), fmt.Errorf("unsupported log level %q", opts.Level)
+	}
+
+	encoderConfig := func(c *zapcore.EncoderConfig) {
+		c.EncodeTime = zapcore.ISO8601TimeEncoder
+	}
+	var encoder zap.Opts
+	switch opts.Encoding {
+	case EncodingJSON:
+		encoder = zap.JSONEncoder(encoderConfig)
+	case EncodingConsole:
+		encoder = zap.ConsoleEncoder(encoderConfig)
+	default:
+		return logr.Discard(), fmt.Errorf("unsupported log encoding %q", opts.Encoding)
+	}
+
+	return zap.New(
+		zap.WriteTo(w),
+		encoder,
+		zap.Level(level),
+		zap.StacktraceLevel(zapcore.PanicLevel),
+	), nil
+}
+
+// RedactURL replaces the user information in a URL so that it can be logged,
+// tokens are often passed as the username, so this is redacted along with the
+// password.
+//
+// URLs that can't be parsed are replaced entirely as they may contain
+// credentials.
+func RedactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+
+	return u.String()
+}
+
+// RedactURLError redacts the URL of the *url.Error in the error chain, the
+// HTTP client returns these with the full URL of the request, which may
+// include the credentials of the server URL.
+//
+// Errors that wrap the *url.Error have already formatted the URL into their
+// message, so the URL is also redacted from the message of the returned
+// error, which wraps the original error.
+func RedactURLError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	raw := urlErr.URL
+	redacted := RedactURL(raw)
+	if raw == redacted {
+		return err
+	}
+	urlErr.URL = redacted
+
+	return &redactedError{err: err, message: strings.ReplaceAll(err.Error(), raw, redacted)}
+}
+
+// redactedError is an error with credentials removed from its message.
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
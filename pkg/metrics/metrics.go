@@ -0,0 +1,180 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "kustomizationset"
+
+const (
+	// OperationCreate labels resources that were created.
+	OperationCreate = "create"
+
+	// OperationUpdate labels resources that were updated.
+	OperationUpdate = "update"
+
+	// OperationDelete labels resources that were deleted.
+	OperationDelete = "delete"
+)
+
+var (
+	// GeneratedResources is the number of resources in the inventory of each
+	// KustomizationSet.
+	GeneratedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "generated_resources",
+		Help:      "Number of resources generated by a KustomizationSet.",
+	}, []string{"namespace", "name"})
+
+	// GenerateDuration is how long each generator takes to generate the
+	// parameters for a KustomizationSet.
+	GenerateDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "generate_duration_seconds",
+		Help:      "Duration of generating parameters, by generator.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"generator"})
+
+	// GenerateErrors is the number of times that each generator has failed
+	// to generate parameters.
+	GenerateErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "generate_errors_total",
+		Help:      "Number of failures to generate parameters, by generator.",
+	}, []string{"generator"})
+
+	// ResourceChanges is the number of generated resources that have been
+	// created, updated and deleted.
+	ResourceChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "resource_changes_total",
+		Help:      "Number of generated resources created, updated or deleted, by operation and kind.",
+	}, []string{"operation", "kind"})
+
+	// ArtifactDownloadDuration is how long it takes to download and extract
+	// GitRepository artifacts.
+	ArtifactDownloadDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "artifact_download_duration_seconds",
+		Help:      "Duration of downloading and extracting GitRepository artifacts.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// ArtifactDownloadSize is the size of the files extracted from
+	// downloaded GitRepository artifacts.
+	ArtifactDownloadSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "artifact_download_size_bytes",
+		Help:      "Size of the files extracted from downloaded GitRepository artifacts.",
+		// 1KiB to 256MiB
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+	})
+
+	// SCMRequests is the number of requests made to the APIs of Git hosting
+	// services.
+	SCMRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scm_requests_total",
+		Help:      "Number of requests to Git hosting service APIs, by driver, operation and result.",
+	}, []string{"driver", "operation", "result"})
+
+	// SCMRequestDuration is the latency of requests made to the APIs of Git
+	// hosting services.
+	SCMRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scm_request_duration_seconds",
+		Help:      "Duration of requests to Git hosting service APIs, by driver and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"driver", "operation"})
+
+	// ReadySets is the number of KustomizationSets with each status of the
+	// Ready condition.
+	ReadySets = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ready_sets",
+		Help:      "Number of KustomizationSets by the status of their Ready condition.",
+	}, []string{"status"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(
+		GeneratedResources,
+		GenerateDuration,
+		GenerateErrors,
+		ResourceChanges,
+		ArtifactDownloadDuration,
+		ArtifactDownloadSize,
+		SCMRequests,
+		SCMRequestDuration,
+		ReadySets,
+	)
+}
+
+// ObserveGenerate records the duration and result of generating parameters
+// with the named generator.
+func ObserveGenerate(generator string, start time.Time, err error) {
+	GenerateDuration.WithLabelValues(generator).Observe(time.Since(start).Seconds())
+	if err != nil {
+		GenerateErrors.WithLabelValues(generator).Inc()
+	}
+}
+
+// ObserveSCMRequest records the duration and result of a request to a Git
+// hosting service API.
+func ObserveSCMRequest(driver, operation string, start time.Time, err error) {
+	SCMRequestDuration.WithLabelValues(driver, operation).Observe(time.Since(start).Seconds())
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	SCMRequests.WithLabelValues(driver, operation, result).Inc()
+}
+
+// readyStatuses are the statuses of the Ready condition, these are always
+// reported so that the gauges drop to zero.
+var readyStatuses = []string{"True", "False", "Unknown"}
+
+var sets = &setTracker{statuses: map[types.NamespacedName]string{}}
+
+// setTracker records the Ready status of each KustomizationSet so that
+// ReadySets can be updated as KustomizationSets change status.
+type setTracker struct {
+	sync.Mutex
+	statuses map[types.NamespacedName]string
+}
+
+// RecordSet records the number of generated resources and the status of the
+// Ready condition of a KustomizationSet.
+func RecordSet(name types.NamespacedName, generated int, readyStatus string) {
+	GeneratedResources.WithLabelValues(name.Namespace, name.Name).Set(float64(generated))
+
+	sets.Lock()
+	defer sets.Unlock()
+	sets.statuses[name] = readyStatus
+	sets.updateReadySets()
+}
+
+// ForgetSet removes the metrics for a KustomizationSet that has been deleted.
+func ForgetSet(name types.NamespacedName) {
+	GeneratedResources.DeleteLabelValues(name.Namespace, name.Name)
+
+	sets.Lock()
+	defer sets.Unlock()
+	delete(sets.statuses, name)
+	sets.updateReadySets()
+}
+
+func (t *setTracker) updateReadySets() {
+	counts := map[string]int{}
+	for _, v := range t.statuses {
+		counts[v]++
+	}
+	for _, v := range readyStatuses {
+		ReadySets.WithLabelValues(v).Set(float64(counts[v]))
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRecordSet(t *testing.T) {
+	first := types.NamespacedName{Name: "first-set", Namespace: "default"}
+	second := types.NamespacedName{Name: "second-set", Namespace: "default"}
+	defer ForgetSet(first)
+	defer ForgetSet(second)
+
+	RecordSet(first, 3, "True")
+	RecordSet(second, 2, "False")
+	assertReadySets(t, map[string]float64{"True": 1, "False": 1, "Unknown": 0})
+	if v := testutil.ToFloat64(GeneratedResources.WithLabelValues("default", "first-set")); v != 3 {
+		t.Errorf("got %v generated resources, want 3", v)
+	}
+
+	RecordSet(second, 2, "True")
+	assertReadySets(t, map[string]float64{"True": 2, "False": 0, "Unknown": 0})
+
+	ForgetSet(first)
+	assertReadySets(t, map[string]float64{"True": 1, "False": 0, "Unknown": 0})
+	if n := testutil.CollectAndCount(GeneratedResources); n != 1 {
+		t.Errorf("got %d generated resources series, want 1", n)
+	}
+}
+
+func TestObserveGenerate(t *testing.T) {
+	before := testutil.ToFloat64(GenerateErrors.WithLabelValues("List"))
+
+	ObserveGenerate("List", time.Now(), nil)
+	ObserveGenerate("List", time.Now(), errors.New("failed"))
+
+	if v := testutil.ToFloat64(GenerateErrors.WithLabelValues("List")); v != before+1 {
+		t.Errorf("got %v errors, want %v", v, before+1)
+	}
+}
+
+func TestObserveSCMRequest(t *testing.T) {
+	ObserveSCMRequest("github", "list_pull_requests", time.Now(), nil)
+	ObserveSCMRequest("github", "list_pull_requests", time.Now(), errors.New("failed"))
+
+	for _, result := range []string{"success", "error"} {
+		if v := testutil.ToFloat64(SCMRequests.WithLabelValues("github", "list_pull_requests", result)); v != 1 {
+			t.Errorf("got %v %s requests, want 1", v, result)
+		}
+	}
+}
+
+func assertReadySets(t *testing.T, want map[string]float64) {
+	t.Helper()
+	for status, count := range want {
+		if v := testutil.ToFloat64(ReadySets.WithLabelValues(status)); v != count {
+			t.Errorf("got %v sets with Ready status %s, want %v", v, status, count)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// generatedKey identifies a rendered resource, and the parameters that were
+// used to render it.
+type generatedKey struct {
+	kind   string
+	key    string
+	params map[string]any
+}
+
+// resolveDuplicates applies the DuplicatePolicy of the KustomizationSet to
+// resources that were rendered more than once, and returns the indexes of the
+// rendered resources to keep, in the order they were rendered.
+func resolveDuplicates(r *sourcev1.KustomizationSet, keys []generatedKey) ([]int, error) {
+	byKey := map[string][]int{}
+	order := []string{}
+	for i, v := range keys {
+		id := v.kind + "/" + v.key
+		if _, ok := byKey[id]; !ok {
+			order = append(order, id)
+		}
+		byKey[id] = append(byKey[id], i)
+	}
+
+	keep := []int{}
+	duplicates := []Duplicate{}
+	for _, id := range order {
+		indexes := byKey[id]
+		if len(indexes) == 1 {
+			keep = append(keep, indexes[0])
+			continue
+		}
+		switch r.Spec.DuplicatePolicy {
+		case sourcev1.DuplicatePolicyFirstWins:
+			keep = append(keep, indexes[0])
+		case sourcev1.DuplicatePolicyLastWins:
+			keep = append(keep, indexes[len(indexes)-1])
+		default:
+			duplicate := Duplicate{Kind: keys[indexes[0]].kind, Key: keys[indexes[0]].key}
+			for _, i := range indexes {
+				duplicate.Params = append(duplicate.Params, formatParams(keys[i].params))
+			}
+			duplicates = append(duplicates, duplicate)
+		}
+	}
+	if len(duplicates) > 0 {
+		return nil, &DuplicateError{SetName: r.GetName(), Duplicates: duplicates}
+	}
+	sort.Ints(keep)
+
+	return keep, nil
+}
+
+func formatParams(params map[string]any) string {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Sprintf("%v", params)
+	}
+	return string(b)
+}
@@ -0,0 +1,61 @@
+package reconciler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GenerateError is returned when the generators fail to generate the
+// parameters for a KustomizationSet.
+type GenerateError struct {
+	Err error
+}
+
+func (e *GenerateError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *GenerateError) Unwrap() error {
+	return e.Err
+}
+
+// RenderError is returned when the templates for a KustomizationSet can't be
+// rendered with the generated parameters.
+//
+// These may be caused by the generated parameters, use ParseTemplates to
+// check whether the templates themselves are invalid.
+type RenderError struct {
+	Err error
+}
+
+func (e *RenderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// DuplicateError is returned when more than one set of generated parameters
+// renders the same resource.
+type DuplicateError struct {
+	SetName    string
+	Duplicates []Duplicate
+}
+
+// Duplicate is a resource that was rendered more than once, with the
+// parameters that rendered it.
+type Duplicate struct {
+	Kind   string
+	Key    string
+	Params []string
+}
+
+func (e *DuplicateError) Error() string {
+	duplicates := []string{}
+	for _, v := range e.Duplicates {
+		duplicates = append(duplicates, fmt.Sprintf("%s %s from parameters %s", v.Kind, v.Key, strings.Join(v.Params, ", ")))
+	}
+
+	return fmt.Sprintf("set %s generated duplicate resources: %s", e.SetName, strings.Join(duplicates, "; "))
+}
@@ -9,21 +9,19 @@ import (
 	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/git"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
-	"github.com/go-logr/logr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 // GitRepositoryGenerator extracts files from Flux GitRepository resources.
 type GitRepositoryGenerator struct {
 	client.Client
-	logr.Logger
 }
 
-// NewGenerator creates and returns a new pull request generator.
-func NewGenerator(l logr.Logger, c client.Client) *GitRepositoryGenerator {
+// NewGenerator creates and returns a new GitRepository generator.
+func NewGenerator(c client.Client) *GitRepositoryGenerator {
 	return &GitRepositoryGenerator{
 		Client: c,
-		Logger: l,
 	}
 }
 
@@ -40,9 +38,14 @@ func (g *GitRepositoryGenerator) Generate(ctx context.Context, sg *kustomizesetv
 	if err := g.Client.Get(ctx, client.ObjectKey{Name: sg.GitRepository.RepositoryRef, Namespace: ks.GetNamespace()}, &gr); err != nil {
 		return nil, fmt.Errorf("could not load GitRepository: %w", err)
 	}
+	if gr.Status.Artifact == nil {
+		return nil, fmt.Errorf("GitRepository %s has no artifact: %w", sg.GitRepository.RepositoryRef, generators.SourceNotReadyError)
+	}
+	logger := log.FromContext(ctx).WithValues("revision", gr.Status.Artifact.Revision)
+	logger.V(1).Info("parsing GitRepository artifact")
 	parser := git.NewRepositoryParser()
 
-	return parser.ParseFromArtifacts(ctx, gr.Status.Artifact.URL, gr.Status.Artifact.Checksum, sg.GitRepository.Directories)
+	return parser.ParseFromArtifacts(log.IntoContext(ctx, logger), gr.Status.Artifact.URL, gr.Status.Artifact.Checksum, sg.GitRepository.Directories)
 }
 
 // Interval is an implementation of the Generator interface.
@@ -2,6 +2,7 @@ package gitrepository
 
 import (
 	"context"
+	"errors"
 	"reflect"
 	"testing"
 
@@ -9,7 +10,6 @@ import (
 	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
 	"github.com/gitops-tools/kustomization-set-controller/test"
-	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -49,7 +49,7 @@ func TestGitRepositoryGenerator_Params(t *testing.T) {
 
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
-			gen := NewGenerator(logr.Discard(), newFakeClient(t, tt.objects...))
+			gen := NewGenerator(newFakeClient(t, tt.objects...))
 			got, err := gen.Generate(context.TODO(), &kustomizesetv1.KustomizationSetGenerator{
 				GitRepository: tt.generator,
 			},
@@ -75,8 +75,34 @@ func TestGitRepositoryGenerator_Params(t *testing.T) {
 	}
 }
 
+func TestGitRepositoryGenerator_Params_no_artifact(t *testing.T) {
+	gr := newGitRepository("", "")
+	gr.Status.Artifact = nil
+	gen := NewGenerator(newFakeClient(t, gr))
+	sg := &kustomizesetv1.KustomizationSetGenerator{
+		GitRepository: &kustomizesetv1.GitRepositoryGenerator{
+			RepositoryRef: "test-repository",
+		},
+	}
+
+	_, err := gen.Generate(context.TODO(), sg,
+		&kustomizesetv1.KustomizationSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-generator",
+				Namespace: testNamespace,
+			},
+			Spec: kustomizesetv1.KustomizationSetSpec{
+				Generators: []kustomizesetv1.KustomizationSetGenerator{*sg},
+			},
+		})
+
+	if !errors.Is(err, generators.SourceNotReadyError) {
+		t.Fatalf("got error %v, want %v", err, generators.SourceNotReadyError)
+	}
+}
+
 func TestGitRepositoryGenerator_Interval(t *testing.T) {
-	gen := NewGenerator(logr.Discard(), nil)
+	gen := NewGenerator(nil)
 	sg := &kustomizesetv1.KustomizationSetGenerator{
 		GitRepository: &kustomizesetv1.GitRepositoryGenerator{},
 	}
@@ -96,7 +122,7 @@ func TestGitRepositoryGenerator_GetTemplate(t *testing.T) {
 			},
 		},
 	}
-	gen := NewGenerator(logr.Discard(), nil)
+	gen := NewGenerator(nil)
 	sg := &kustomizesetv1.KustomizationSetGenerator{
 		GitRepository: &kustomizesetv1.GitRepositoryGenerator{
 			Template: template,
@@ -0,0 +1,50 @@
+package gitrepository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/git"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+)
+
+// LocalGitRepositoryGenerator generates from the files in a local directory,
+// e.g. a checkout of the repository referenced by the GitRepository, rather
+// than the GitRepository artifact.
+type LocalGitRepositoryGenerator struct {
+	dir string
+}
+
+// NewLocalGenerator creates and returns a new generator that parses the files
+// in the directory.
+func NewLocalGenerator(dir string) *LocalGitRepositoryGenerator {
+	return &LocalGitRepositoryGenerator{dir: dir}
+}
+
+func (g *LocalGitRepositoryGenerator) Generate(ctx context.Context, sg *kustomizesetv1.KustomizationSetGenerator, ks *kustomizesetv1.KustomizationSet) ([]map[string]any, error) {
+	if sg == nil {
+		return nil, generators.EmptyKustomizationSetGeneratorError
+	}
+
+	if sg.GitRepository == nil {
+		return nil, nil
+	}
+
+	if g.dir == "" {
+		return nil, errors.New("no local directory provided for GitRepository " + sg.GitRepository.RepositoryRef)
+	}
+
+	return git.ParseDirectories(g.dir, sg.GitRepository.Directories)
+}
+
+// Interval is an implementation of the Generator interface.
+func (g *LocalGitRepositoryGenerator) Interval(sg *kustomizesetv1.KustomizationSetGenerator) time.Duration {
+	return generators.NoRequeueInterval
+}
+
+// Template is an implementation of the Generator interface.
+func (g *LocalGitRepositoryGenerator) Template(sg *kustomizesetv1.KustomizationSetGenerator) *kustomizesetv1.KustomizationSetTemplate {
+	return sg.GitRepository.Template
+}
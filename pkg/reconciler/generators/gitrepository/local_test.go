@@ -0,0 +1,48 @@
+package gitrepository
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	kustomizesetv1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ generators.Generator = (*LocalGitRepositoryGenerator)(nil)
+
+func TestLocalGitRepositoryGenerator_Params(t *testing.T) {
+	gen := NewLocalGenerator("testdata/checkout")
+
+	got, err := gen.Generate(context.TODO(), &kustomizesetv1.KustomizationSetGenerator{
+		GitRepository: &kustomizesetv1.GitRepositoryGenerator{
+			RepositoryRef: "test-repository",
+			Directories: []kustomizesetv1.GitRepositoryGeneratorDirectoryItem{
+				{Path: "files"},
+			},
+		},
+	}, &kustomizesetv1.KustomizationSet{})
+	test.AssertNoError(t, err)
+
+	sort.Slice(got, func(i, j int) bool { return got[i]["environment"].(string) < got[j]["environment"].(string) })
+	want := []map[string]any{
+		{"environment": "dev", "instances": 2.0},
+		{"environment": "production", "instances": 10.0},
+		{"environment": "staging", "instances": 5.0},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("failed to generate params:\n%s", diff)
+	}
+}
+
+func TestLocalGitRepositoryGenerator_no_directory(t *testing.T) {
+	gen := NewLocalGenerator("")
+
+	_, err := gen.Generate(context.TODO(), &kustomizesetv1.KustomizationSetGenerator{
+		GitRepository: &kustomizesetv1.GitRepositoryGenerator{RepositoryRef: "test-repository"},
+	}, &kustomizesetv1.KustomizationSet{})
+
+	test.AssertErrorMatch(t, "no local directory provided for GitRepository test-repository", err)
+}
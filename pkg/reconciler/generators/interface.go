@@ -30,6 +30,10 @@ type Generator interface {
 // EmptyKustomizationSetGeneratorError is returned when KustomizationSet is
 // empty.
 var EmptyKustomizationSetGeneratorError = errors.New("KustomizationSet is empty")
+
+// SourceNotReadyError is returned when a generator's source is not ready to
+// be used, e.g. a GitRepository without an artifact.
+var SourceNotReadyError = errors.New("source is not ready")
 var NoRequeueInterval time.Duration
 
 // DefaultInterval is used when Interval is not specified, it
@@ -0,0 +1,100 @@
+package pullrequest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+	"github.com/jenkins-x/go-scm/scm"
+	"sigs.k8s.io/yaml"
+)
+
+// PullRequestFixture is a recorded pull request.
+type PullRequestFixture struct {
+	// Repo is the repository the pull request was opened against, e.g.
+	// my-org/my-repo.
+	Repo    string   `json:"repo"`
+	Number  int      `json:"number"`
+	Branch  string   `json:"branch"`
+	HeadSHA string   `json:"headSHA"`
+	Labels  []string `json:"labels,omitempty"`
+}
+
+// FixtureGenerator generates from recorded pull requests rather than querying
+// the Git hosting service.
+type FixtureGenerator struct {
+	fixtures []PullRequestFixture
+}
+
+// NewFixtureGenerator creates and returns a new generator that generates from
+// the recorded pull requests.
+func NewFixtureGenerator(fixtures []PullRequestFixture) *FixtureGenerator {
+	return &FixtureGenerator{fixtures: fixtures}
+}
+
+// LoadFixtures parses the recorded pull requests from a YAML or JSON file.
+func LoadFixtures(filename string) ([]PullRequestFixture, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pull request fixtures: %w", err)
+	}
+	fixtures := []PullRequestFixture{}
+	if err := yaml.UnmarshalStrict(b, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse pull request fixtures from %s: %w", filename, err)
+	}
+
+	return fixtures, nil
+}
+
+func (g *FixtureGenerator) Generate(ctx context.Context, sg *sourcev1.KustomizationSetGenerator, ks *sourcev1.KustomizationSet) ([]map[string]any, error) {
+	if sg == nil {
+		return nil, generators.EmptyKustomizationSetGeneratorError
+	}
+
+	if sg.PullRequest == nil {
+		return nil, nil
+	}
+
+	if g.fixtures == nil {
+		return nil, fmt.Errorf("no pull request fixtures provided for repo %s", sg.PullRequest.Repo)
+	}
+
+	res := []map[string]any{}
+	for _, fixture := range g.fixtures {
+		if fixture.Repo != sg.PullRequest.Repo {
+			continue
+		}
+		pr := fixture.pullRequest()
+		if !prMatchesLabels(pr, sg.PullRequest.Labels) {
+			continue
+		}
+		res = append(res, pullRequestParams(pr))
+	}
+
+	return res, nil
+}
+
+// Interval is an implementation of the Generator interface.
+func (g *FixtureGenerator) Interval(sg *sourcev1.KustomizationSetGenerator) time.Duration {
+	return sg.PullRequest.Interval.Duration
+}
+
+// Template is an implementation of the Generator interface.
+func (g *FixtureGenerator) Template(sg *sourcev1.KustomizationSetGenerator) *sourcev1.KustomizationSetTemplate {
+	return sg.PullRequest.Template
+}
+
+func (f PullRequestFixture) pullRequest() *scm.PullRequest {
+	pr := &scm.PullRequest{
+		Number: f.Number,
+		Head:   scm.PullRequestBranch{Ref: f.Branch, Sha: f.HeadSHA},
+	}
+	for _, v := range f.Labels {
+		pr.Labels = append(pr.Labels, &scm.Label{Name: v})
+	}
+
+	return pr
+}
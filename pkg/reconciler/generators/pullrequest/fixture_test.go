@@ -0,0 +1,67 @@
+package pullrequest
+
+import (
+	"context"
+	"testing"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ generators.Generator = (*FixtureGenerator)(nil)
+
+func TestFixtureGenerator_Generate(t *testing.T) {
+	fixtures, err := LoadFixtures("testdata/pull_requests.yaml")
+	test.AssertNoError(t, err)
+
+	testCases := []struct {
+		name   string
+		labels []string
+		want   []map[string]any
+	}{
+		{
+			name: "unfiltered pull requests",
+			want: []map[string]any{
+				{"number": "1", "branch": "new-topic", "head_sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e"},
+				{"number": "2", "branch": "other-topic", "head_sha": "2b1d6ee1f6e2c3e5cb5e0c1e6c0c7c7f3f1d7a50"},
+			},
+		},
+		{
+			name:   "pull requests filtered by label",
+			labels: []string{"preview"},
+			want: []map[string]any{
+				{"number": "1", "branch": "new-topic", "head_sha": "6dcb09b5b57875f334f61aebed695e2e4193db5e"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			gen := NewFixtureGenerator(fixtures)
+			got, err := gen.Generate(context.TODO(), &sourcev1.KustomizationSetGenerator{
+				PullRequest: &sourcev1.PullRequestGenerator{
+					Driver: "github",
+					Repo:   "test-org/test-repo",
+					Labels: tt.labels,
+				},
+			}, &sourcev1.KustomizationSet{})
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("failed to generate params:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFixtureGenerator_no_fixtures(t *testing.T) {
+	gen := NewFixtureGenerator(nil)
+
+	_, err := gen.Generate(context.TODO(), &sourcev1.KustomizationSetGenerator{
+		PullRequest: &sourcev1.PullRequestGenerator{Driver: "github", Repo: "test-org/test-repo"},
+	}, &sourcev1.KustomizationSet{})
+
+	test.AssertErrorMatch(t, "no pull request fixtures provided for repo test-org/test-repo", err)
+}
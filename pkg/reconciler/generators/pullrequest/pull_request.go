@@ -3,48 +3,51 @@ package pullrequest
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"time"
 
 	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/logging"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/metrics"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
-	"github.com/go-logr/logr"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/tracing"
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/jenkins-x/go-scm/scm/factory"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// ParamKeys are the keys of the parameters generated for each pull request.
+var ParamKeys = paramKeys()
+
 type clientFactoryFunc func(driver, serverURL, oauthToken string, opts ...factory.ClientOptionFunc) (*scm.Client, error)
 
 // PullRequestGenerator generates from the open pull requests in a repository.
 type PullRequestGenerator struct {
 	client.Client
 	clientFactory clientFactoryFunc
-	logr.Logger
 }
 
 // NewGenerator creates and returns a new pull request generator.
-func NewGenerator(l logr.Logger, c client.Client) *PullRequestGenerator {
+func NewGenerator(c client.Client) *PullRequestGenerator {
 	return &PullRequestGenerator{
 		Client:        c,
-		Logger:        l,
 		clientFactory: factory.NewClient,
 	}
 }
 
 func (g *PullRequestGenerator) Generate(ctx context.Context, sg *sourcev1.KustomizationSetGenerator, ks *sourcev1.KustomizationSet) ([]map[string]any, error) {
-	g.Logger.Info("generating params", "repo", sg.PullRequest.Repo)
 	if sg == nil {
-		g.Logger.Info("no generator provided")
 		return nil, generators.EmptyKustomizationSetGeneratorError
 	}
 
 	if sg.PullRequest == nil {
-		g.Logger.Info("pull request configuration is nil")
 		return nil, nil
 	}
+	logger := log.FromContext(ctx)
 
 	authToken := ""
 	if sg.PullRequest.SecretRef != nil {
@@ -61,33 +64,54 @@ func (g *PullRequestGenerator) Generate(ctx context.Context, sg *sourcev1.Kustom
 		// for details of the standard flux Git repository secret.
 		authToken = string(secret.Data["password"])
 	}
-	g.Logger.Info("querying pull requests", "repo", sg.PullRequest.Repo, "driver", sg.PullRequest.Driver, "serverURL", sg.PullRequest.ServerURL)
+	logger.V(1).Info("querying pull requests")
 
 	scmClient, err := g.clientFactory(sg.PullRequest.Driver, sg.PullRequest.ServerURL, authToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
+	// This wraps the transport that authenticates the requests, so the
+	// requests are traced as children of the Generate span.
+	scmClient.Client = tracing.HTTPClient(scmClient.Client)
 
+	start := time.Now()
 	prs, _, err := scmClient.PullRequests.List(ctx, sg.PullRequest.Repo, listOptionsFromConfig(sg.PullRequest))
+	metrics.ObserveSCMRequest(sg.PullRequest.Driver, "list_pull_requests", start, err)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+		return nil, fmt.Errorf("failed to list pull requests: %w", logging.RedactURLError(err))
 	}
-	g.Logger.Info("queried pull requests", "repo", sg.PullRequest.Repo, "count", len(prs))
+	logger.V(1).Info("queried pull requests", "count", len(prs))
 	res := []map[string]any{}
 	for _, pr := range prs {
 		if !prMatchesLabels(pr, sg.PullRequest.Labels) {
 			continue
 		}
-		res = append(res, map[string]any{
-			"number":   strconv.Itoa(pr.Number),
-			"branch":   pr.Head.Ref,
-			"head_sha": pr.Head.Sha,
-		})
+		res = append(res, pullRequestParams(pr))
 	}
 
 	return res, nil
 }
 
+func pullRequestParams(pr *scm.PullRequest) map[string]any {
+	return map[string]any{
+		"number":   strconv.Itoa(pr.Number),
+		"branch":   pr.Head.Ref,
+		"head_sha": pr.Head.Sha,
+	}
+}
+
+// paramKeys returns the sorted keys of the parameters that are generated for
+// a pull request.
+func paramKeys() []string {
+	keys := []string{}
+	for k := range pullRequestParams(&scm.PullRequest{}) {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
 // Interval is an implementation of the Generator interface.
 func (g *PullRequestGenerator) Interval(sg *sourcev1.KustomizationSetGenerator) time.Duration {
 	return sg.PullRequest.Interval.Duration
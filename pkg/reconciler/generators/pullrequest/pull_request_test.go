@@ -1,15 +1,19 @@
 package pullrequest
 
 import (
+	"context"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
-	"github.com/go-logr/logr"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+	"github.com/go-logr/logr/funcr"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 var _ generators.Generator = (*PullRequestGenerator)(nil)
@@ -102,7 +106,7 @@ var _ generators.Generator = (*PullRequestGenerator)(nil)
 
 // 	for _, tt := range testCases {
 // 		t.Run(tt.name, func(t *testing.T) {
-// 			gen := NewGenerator(logr.Discard(), fake.NewFakeClient(tt.initObjs...))
+// 			gen := NewGenerator(fake.NewFakeClient(tt.initObjs...))
 // 			client, data := fakescm.NewDefault()
 // 			tt.dataFunc(data)
 // 			gen.clientFactory = tt.clientFactory(client)
@@ -124,9 +128,41 @@ var _ generators.Generator = (*PullRequestGenerator)(nil)
 // 	}
 // }
 
+func TestPullRequestGenerator_Generate_redacts_credentials(t *testing.T) {
+	var logs []string
+	logger := funcr.New(func(prefix, args string) {
+		logs = append(logs, args)
+	}, funcr.Options{Verbosity: 1})
+	// Nothing is listening on the port, so listing the pull requests fails
+	// with the URL of the request.
+	gen := NewGenerator(fake.NewFakeClient())
+	sg := &sourcev1.KustomizationSetGenerator{
+		PullRequest: &sourcev1.PullRequestGenerator{
+			Driver:    "github",
+			ServerURL: "http://secret-token@127.0.0.1:1/api/v3",
+			Repo:      "test-org/my-repo",
+		},
+	}
+
+	_, err := gen.Generate(log.IntoContext(context.TODO(), logger), sg, &sourcev1.KustomizationSet{})
+	test.AssertErrorMatch(t, `failed to list pull requests: .*"http://redacted@127.0.0.1:1/api/v3/`, err)
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Fatalf("returned credentials: %s", err)
+	}
+
+	if len(logs) == 0 {
+		t.Fatal("no messages were logged")
+	}
+	for _, v := range logs {
+		if strings.Contains(v, "secret-token") {
+			t.Fatalf("logged credentials: %s", v)
+		}
+	}
+}
+
 func TestPullRequestGenerator_GetInterval(t *testing.T) {
 	interval := time.Minute * 10
-	gen := NewGenerator(logr.Discard(), fake.NewFakeClient())
+	gen := NewGenerator(fake.NewFakeClient())
 	sg := &sourcev1.KustomizationSetGenerator{
 		PullRequest: &sourcev1.PullRequestGenerator{
 			Driver:    "fake",
@@ -151,7 +187,7 @@ func TestPullRequestGenerator_GetTemplate(t *testing.T) {
 			},
 		},
 	}
-	gen := NewGenerator(logr.Discard(), fake.NewFakeClient())
+	gen := NewGenerator(fake.NewFakeClient())
 	sg := &sourcev1.KustomizationSetGenerator{
 		PullRequest: &sourcev1.PullRequestGenerator{
 			Driver:    "fake",
@@ -168,3 +204,10 @@ func TestPullRequestGenerator_GetTemplate(t *testing.T) {
 		t.Fatalf("got %#v want %#v", tpl, template)
 	}
 }
+
+func TestParamKeys(t *testing.T) {
+	want := []string{"branch", "head_sha", "number"}
+	if !reflect.DeepEqual(ParamKeys, want) {
+		t.Fatalf("got %#v want %#v", ParamKeys, want)
+	}
+}
@@ -0,0 +1,171 @@
+package reconciler
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+	"text/template/parse"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+// TemplateParams parses the templates that are rendered with the parameters
+// from each generator of the KustomizationSet, and returns the sorted
+// parameter keys that the templates reference, in the order of the
+// generators.
+//
+// Keys are only found where they are referenced from the parameters passed
+// to the template e.g. {{ .cluster }} or {{ $.cluster }}.
+func TemplateParams(r *sourcev1.KustomizationSet) ([][]string, error) {
+	if r.Spec.Template == nil {
+		keys, err := resourceTemplateParams(r)
+		if err != nil {
+			return nil, err
+		}
+		res := [][]string{}
+		for range r.Spec.Generators {
+			res = append(res, keys)
+		}
+		return res, nil
+	}
+
+	res := [][]string{}
+	for i, gen := range r.Spec.Generators {
+		merged, err := mergeTemplates(GeneratorTemplate(gen), *r.Spec.Template)
+		if err != nil {
+			return nil, &RenderError{Err: fmt.Errorf("failed to merge the template for generator %d of set %s: %w", i, r.GetName(), err)}
+		}
+		b, err := json.Marshal(makeKustomization(merged))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal Kustomization for template parsing: %w", err)
+		}
+		keys, err := parseParams(string(b))
+		if err != nil {
+			return nil, &RenderError{Err: fmt.Errorf("failed to parse the template for generator %d of set %s: %w", i, r.GetName(), err)}
+		}
+		res = append(res, keys)
+	}
+
+	return res, nil
+}
+
+func resourceTemplateParams(r *sourcev1.KustomizationSet) ([]string, error) {
+	templates := r.Spec.Templates
+	if r.Spec.ResourceTemplate != nil {
+		templates = []sourcev1.KustomizationSetResourceTemplate{
+			{Name: "resource", Resource: *r.Spec.ResourceTemplate},
+		}
+	}
+
+	keys := map[string]bool{}
+	for _, tmpl := range templates {
+		for _, text := range []string{tmpl.Condition, string(tmpl.Resource.Raw)} {
+			found, err := parseParams(text)
+			if err != nil {
+				return nil, &RenderError{Err: fmt.Errorf("failed to parse template %s for set %s: %w", tmpl.Name, r.GetName(), err)}
+			}
+			for _, k := range found {
+				keys[k] = true
+			}
+		}
+	}
+
+	return sortedKeys(keys), nil
+}
+
+// ParseTemplates returns a RenderError if the KustomizationSet doesn't have
+// exactly one kind of template, or if the templates can't be parsed.
+//
+// Unlike failures to render the templates with the generated parameters,
+// these errors can only be fixed by changing the KustomizationSet.
+func ParseTemplates(r *sourcev1.KustomizationSet) error {
+	if templateCount(r) != 1 {
+		return &RenderError{Err: fmt.Errorf("set %s must have exactly one of template, resourceTemplate or templates", r.GetName())}
+	}
+	_, err := TemplateParams(r)
+
+	return err
+}
+
+// GeneratorTemplate returns the template of the configured generator.
+func GeneratorTemplate(gen sourcev1.KustomizationSetGenerator) *sourcev1.KustomizationSetTemplate {
+	switch {
+	case gen.List != nil:
+		return gen.List.Template
+	case gen.PullRequest != nil:
+		return gen.PullRequest.Template
+	case gen.GitRepository != nil:
+		return gen.GitRepository.Template
+	}
+	return nil
+}
+
+func parseParams(text string) ([]string, error) {
+	t, err := template.New("kustomization").Funcs(funcMap).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	keys := map[string]bool{}
+	walkParams(t.Tree.Root, false, keys)
+
+	return sortedKeys(keys), nil
+}
+
+// walkParams records the parameter keys referenced by the nodes, in nested
+// range and with blocks the dot is another value, and only keys referenced
+// from the root with $ are recorded.
+func walkParams(node parse.Node, nested bool, keys map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, v := range n.Nodes {
+			walkParams(v, nested, keys)
+		}
+	case *parse.ActionNode:
+		walkParams(n.Pipe, nested, keys)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkParams(cmd, nested, keys)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			walkParams(arg, nested, keys)
+		}
+	case *parse.FieldNode:
+		if !nested {
+			keys[n.Ident[0]] = true
+		}
+	case *parse.VariableNode:
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			keys[n.Ident[1]] = true
+		}
+	case *parse.IfNode:
+		walkParams(n.Pipe, nested, keys)
+		walkParams(n.List, nested, keys)
+		walkParams(n.ElseList, nested, keys)
+	case *parse.RangeNode:
+		walkParams(n.Pipe, nested, keys)
+		walkParams(n.List, true, keys)
+		walkParams(n.ElseList, nested, keys)
+	case *parse.WithNode:
+		walkParams(n.Pipe, nested, keys)
+		walkParams(n.List, true, keys)
+		walkParams(n.ElseList, nested, keys)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
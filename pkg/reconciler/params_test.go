@@ -0,0 +1,124 @@
+package reconciler
+
+import (
+	"errors"
+	"testing"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+func TestTemplateParams(t *testing.T) {
+	paramsTests := []struct {
+		name string
+		opts []func(*sourcev1.KustomizationSet)
+		want [][]string
+	}{
+		{
+			name: "kustomization template",
+			opts: []func(*sourcev1.KustomizationSet){withListElements(nil, nil)},
+			want: [][]string{{"cluster"}},
+		},
+		{
+			name: "generator template",
+			opts: []func(*sourcev1.KustomizationSet){
+				withListElements(nil, nil),
+				withListElements(nil, &sourcev1.KustomizationSetTemplate{
+					KustomizationSetTemplateMeta: sourcev1.KustomizationSetTemplateMeta{
+						Namespace: "{{ sanitize .team }}",
+					},
+				}),
+			},
+			want: [][]string{{"cluster"}, {"cluster", "team"}},
+		},
+		{
+			name: "resource templates",
+			opts: []func(*sourcev1.KustomizationSet){
+				withListElements(nil, nil),
+				withResourceTemplates(
+					sourcev1.KustomizationSetResourceTemplate{
+						Name:      "config",
+						Condition: `{{ eq $.env "production" }}`,
+						Resource:  apiextensionsv1.JSON{Raw: []byte(`{"metadata": {"name": "{{ .cluster }}"{{ range .regions }}, "{{ .name }}": "{{ $.zone }}"{{ end }}}}`)},
+					},
+				),
+			},
+			want: [][]string{{"cluster", "env", "regions", "zone"}},
+		},
+		{
+			name: "conditionals",
+			opts: []func(*sourcev1.KustomizationSet){
+				withListElements(nil, nil),
+				withResourceTemplate(`{"metadata": {"name": "{{ if .name }}{{ .name }}{{ else }}{{ .cluster }}{{ end }}"}}`),
+			},
+			want: [][]string{{"cluster", "name"}},
+		},
+	}
+
+	for _, tt := range paramsTests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := TemplateParams(makeTestKustomizationSet(tt.opts...))
+			test.AssertNoError(t, err)
+
+			if diff := cmp.Diff(tt.want, keys); diff != "" {
+				t.Fatalf("failed to get params:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTemplateParams_errors(t *testing.T) {
+	kset := makeTestKustomizationSet(
+		withListElements(nil, nil),
+		withResourceTemplate(`{"metadata": {"name": "{{ .cluster }"}}`),
+	)
+
+	_, err := TemplateParams(kset)
+
+	test.AssertErrorMatch(t, "failed to parse template resource for set test-kustomizations: failed to parse template", err)
+}
+
+func TestParseTemplates(t *testing.T) {
+	parseTests := []struct {
+		name    string
+		opts    []func(*sourcev1.KustomizationSet)
+		wantErr string
+	}{
+		{
+			name: "valid template",
+			opts: []func(*sourcev1.KustomizationSet){withListElements(nil, nil)},
+		},
+		{
+			name: "template that fails to parse",
+			opts: []func(*sourcev1.KustomizationSet){
+				withListElements(nil, nil),
+				withResourceTemplate(`{"metadata": {"name": "{{ .cluster }"}}`),
+			},
+			wantErr: "failed to parse template resource",
+		},
+		{
+			name: "no template",
+			opts: []func(*sourcev1.KustomizationSet){
+				withListElements(nil, nil),
+				func(ks *sourcev1.KustomizationSet) { ks.Spec.Template = nil },
+			},
+			wantErr: "must have exactly one of template, resourceTemplate or templates",
+		},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ParseTemplates(makeTestKustomizationSet(tt.opts...))
+
+			if !test.MatchErrorString(t, tt.wantErr, err) {
+				t.Fatalf("failed to match error: got %s, want %s", err, tt.wantErr)
+			}
+			var renderErr *RenderError
+			if tt.wantErr != "" && !errors.As(err, &renderErr) {
+				t.Fatalf("got %T, want a RenderError", err)
+			}
+		})
+	}
+}
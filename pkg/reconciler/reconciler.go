@@ -3,36 +3,227 @@ package reconciler
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
+// NamespacedFunc returns true if resources of the provided kind are
+// namespaced.
+type NamespacedFunc func(schema.GroupVersionKind) (bool, error)
+
+// GenerateOptions configures the generation of resources.
+type GenerateOptions struct {
+	// IsNamespaced is used to determine whether or not generated resources
+	// should be defaulted to the namespace of the KustomizationSet.
+	IsNamespaced NamespacedFunc
+
+	// KustomizationVersion is the API version of Kustomizations generated
+	// from the Kustomization template, when the KustomizationSet doesn't
+	// specify a version.
+	KustomizationVersion string
+}
+
+// GeneratedResource is a resource generated from a KustomizationSet.
+type GeneratedResource struct {
+	Resource *unstructured.Unstructured
+
+	// Policy is the policy of the generator that generated the resource.
+	Policy string
+
+	// Generator identifies the generator that generated the resource, see
+	// GeneratorID.
+	Generator string
+}
+
 // GenerateKustomizations parses the KustomizationSet and creates a
 // Kustomization using the configured generators and templates.
 func GenerateKustomizations(ctx context.Context, r *sourcev1.KustomizationSet, configuredGenerators map[string]generators.Generator) ([]kustomizev1.Kustomization, error) {
+	res, _, err := generateKustomizations(ctx, r, configuredGenerators)
+	return res, err
+}
+
+// generateKustomizations returns the generated Kustomizations, and the
+// generator that generated each Kustomization.
+func generateKustomizations(ctx context.Context, r *sourcev1.KustomizationSet, configuredGenerators map[string]generators.Generator) ([]kustomizev1.Kustomization, []generatorRef, error) {
+	if r.Spec.Template == nil {
+		return nil, nil, &RenderError{Err: fmt.Errorf("failed to generate Kustomizations for set %s: no template provided", r.GetName())}
+	}
+
 	var res []kustomizev1.Kustomization
-	for _, gen := range r.Spec.Generators {
-		t, err := transform(ctx, gen, configuredGenerators, r.Spec.Template, r)
+	var sources []generatorRef
+	var keys []generatedKey
+	for i, gen := range r.Spec.Generators {
+		source := generatorRef{id: GeneratorID(i, gen), policy: gen.Policy}
+		t, err := transform(ctx, gen, configuredGenerators, *r.Spec.Template, r)
 		if err != nil {
-			return nil, fmt.Errorf("failed to transform template for set %s: %w", r.GetName(), err)
+			return nil, nil, &GenerateError{Err: fmt.Errorf("failed to transform template for set %s: %w", r.GetName(), err)}
 		}
 		for _, a := range t {
 			tmplKustomization := makeKustomization(a.Template)
 			for _, p := range a.Params {
-				app, err := renderTemplateParams(tmplKustomization, p)
+				app, err := renderTemplateParams(ctx, tmplKustomization, p)
 				if err != nil {
-					return nil, fmt.Errorf("failed to render template params for set %s: %w", r.GetName(), err)
+					return nil, nil, &RenderError{Err: fmt.Errorf("failed to render template params for set %s: %w", r.GetName(), err)}
+				}
+				if app.GetNamespace() == "" {
+					app.SetNamespace(r.GetNamespace())
+				}
+				if !namespaceAllowed(r, app.GetNamespace()) {
+					return nil, nil, &RenderError{Err: fmt.Errorf("generated Kustomization %s in namespace %s is not permitted by set %s", app.GetName(), app.GetNamespace(), r.GetName())}
 				}
-				app.SetNamespace(r.GetNamespace())
 				res = append(res, *app)
+				sources = append(sources, source)
+				keys = append(keys, generatedKey{kind: kustomizev1.KustomizationKind, key: app.GetNamespace() + "/" + app.GetName(), params: p})
+			}
+		}
+	}
+
+	keep, err := resolveDuplicates(r, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	unique := make([]kustomizev1.Kustomization, 0, len(keep))
+	uniqueSources := make([]generatorRef, 0, len(keep))
+	for _, i := range keep {
+		unique = append(unique, res[i])
+		uniqueSources = append(uniqueSources, sources[i])
+	}
+
+	return unique, uniqueSources, nil
+}
+
+// generatorRef is the generator that generated a resource.
+type generatorRef struct {
+	id     string
+	policy string
+}
+
+// GeneratorID identifies a generator by its index in the generators of the
+// KustomizationSet and the kind of generator, e.g. 1/PullRequest.
+func GeneratorID(index int, generator sourcev1.KustomizationSetGenerator) string {
+	return fmt.Sprintf("%d/%s", index, strings.Join(generatorNames(&generator), "+"))
+}
+
+// GenerateResources parses the KustomizationSet and creates the resources
+// from either the Kustomization template, or the resource templates.
+func GenerateResources(ctx context.Context, r *sourcev1.KustomizationSet, configuredGenerators map[string]generators.Generator, opts GenerateOptions) ([]GeneratedResource, error) {
+	if templateCount(r) != 1 {
+		return nil, &RenderError{Err: fmt.Errorf("set %s must have exactly one of template, resourceTemplate or templates", r.GetName())}
+	}
+
+	if r.Spec.Template != nil {
+		kustomizations, sources, err := generateKustomizations(ctx, r, configuredGenerators)
+		if err != nil {
+			return nil, err
+		}
+		version := KustomizationVersion(r, opts.KustomizationVersion)
+		res := []GeneratedResource{}
+		for i := range kustomizations {
+			u, err := kustomizationToUnstructured(&kustomizations[i], version)
+			if err != nil {
+				return nil, &RenderError{Err: fmt.Errorf("failed to generate Kustomizations for set %s: %w", r.GetName(), err)}
+			}
+			res = append(res, GeneratedResource{Resource: u, Policy: sources[i].policy, Generator: sources[i].id})
+		}
+		return res, nil
+	}
+
+	templates := r.Spec.Templates
+	if r.Spec.ResourceTemplate != nil {
+		templates = []sourcev1.KustomizationSetResourceTemplate{
+			{Name: "resource", Resource: *r.Spec.ResourceTemplate},
+		}
+	}
+
+	var res []GeneratedResource
+	var keys []generatedKey
+	for i, gen := range r.Spec.Generators {
+		id := GeneratorID(i, gen)
+		params, err := generateParams(ctx, gen, configuredGenerators, r)
+		if err != nil {
+			return nil, &GenerateError{Err: fmt.Errorf("failed to generate params for set %s: %w", r.GetName(), err)}
+		}
+		for _, p := range params {
+			for _, tmpl := range templates {
+				resource, err := renderResourceSetTemplate(ctx, tmpl, p)
+				if err != nil {
+					return nil, &RenderError{Err: fmt.Errorf("failed to render template %s for set %s: %w", tmpl.Name, r.GetName(), err)}
+				}
+				if resource == nil {
+					continue
+				}
+				namespaced, err := opts.IsNamespaced(resource.GroupVersionKind())
+				if err != nil {
+					return nil, fmt.Errorf("failed to determine scope of %s for set %s: %w", resource.GroupVersionKind().Kind, r.GetName(), err)
+				}
+				if !namespaced {
+					resource.SetNamespace("")
+				} else {
+					if resource.GetNamespace() == "" {
+						resource.SetNamespace(r.GetNamespace())
+					}
+					if !namespaceAllowed(r, resource.GetNamespace()) {
+						return nil, &RenderError{Err: fmt.Errorf("generated %s %s in namespace %s is not permitted by set %s", resource.GetKind(), resource.GetName(), resource.GetNamespace(), r.GetName())}
+					}
+				}
+				res = append(res, GeneratedResource{Resource: resource, Policy: gen.Policy, Generator: id})
+				keys = append(keys, generatedKey{kind: resource.GroupVersionKind().GroupKind().String(), key: resourceKey(resource), params: p})
 			}
 		}
 	}
 
-	return res, nil
+	keep, err := resolveDuplicates(r, keys)
+	if err != nil {
+		return nil, err
+	}
+	unique := make([]GeneratedResource, 0, len(keep))
+	for _, i := range keep {
+		unique = append(unique, res[i])
+	}
+
+	return unique, nil
+}
+
+func resourceKey(u *unstructured.Unstructured) string {
+	if u.GetNamespace() == "" {
+		return u.GetName()
+	}
+	return u.GetNamespace() + "/" + u.GetName()
+}
+
+func templateCount(r *sourcev1.KustomizationSet) int {
+	count := 0
+	if r.Spec.Template != nil {
+		count++
+	}
+	if r.Spec.ResourceTemplate != nil {
+		count++
+	}
+	if len(r.Spec.Templates) > 0 {
+		count++
+	}
+	return count
+}
+
+// namespaceAllowed returns true if the KustomizationSet is permitted to
+// generate Kustomizations in the provided namespace.
+func namespaceAllowed(r *sourcev1.KustomizationSet, ns string) bool {
+	if ns == r.GetNamespace() {
+		return true
+	}
+	for _, v := range r.Spec.AllowedNamespaces {
+		if v == ns {
+			return true
+		}
+	}
+	return false
 }
 
 func makeKustomization(template sourcev1.KustomizationSetTemplate) *kustomizev1.Kustomization {
@@ -41,8 +232,55 @@ func makeKustomization(template sourcev1.KustomizationSetTemplate) *kustomizev1.
 			Annotations: template.Annotations,
 			Labels:      template.Labels,
 			Name:        template.Name,
+			Namespace:   template.Namespace,
 			Finalizers:  template.Finalizers,
 		},
 		Spec: template.Spec,
 	}
 }
+
+// KustomizationVersion returns the version of the Kustomization API used for
+// the Kustomizations generated by the KustomizationSet, the version in the
+// KustomizationSet takes precedence over the default version, and v1beta2 is
+// used if neither is set.
+func KustomizationVersion(r *sourcev1.KustomizationSet, defaultVersion string) string {
+	if r.Spec.KustomizationVersion != "" {
+		return r.Spec.KustomizationVersion
+	}
+	if defaultVersion != "" {
+		return defaultVersion
+	}
+	return sourcev1.KustomizationVersionV1Beta2
+}
+
+// kustomizationToUnstructured converts the Kustomization to the requested
+// version of the Kustomization API.
+//
+// The v1 API is the v1beta2 API without the deprecated fields, so this fails
+// if the Kustomization uses fields that were removed from v1.
+func kustomizationToUnstructured(k *kustomizev1.Kustomization, version string) (*unstructured.Unstructured, error) {
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Kustomization %s: %w", k.GetName(), err)
+	}
+	u := &unstructured.Unstructured{Object: raw}
+	u.SetGroupVersionKind(kustomizev1.GroupVersion.WithKind(kustomizev1.KustomizationKind))
+	unstructured.RemoveNestedField(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+
+	switch version {
+	case "", sourcev1.KustomizationVersionV1Beta2:
+		return u, nil
+	case sourcev1.KustomizationVersionV1:
+		for _, field := range []string{"patchesStrategicMerge", "patchesJson6902"} {
+			if _, ok, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", field); ok {
+				return nil, fmt.Errorf("failed to convert Kustomization %s: %s is not supported by the Kustomization %s API, use patches", k.GetName(), field, version)
+			}
+		}
+		unstructured.RemoveNestedField(u.Object, "spec", "validation")
+		u.SetAPIVersion(kustomizev1.GroupVersion.Group + "/" + version)
+		return u, nil
+	}
+
+	return nil, fmt.Errorf("unsupported Kustomization version %q", version)
+}
@@ -8,8 +8,11 @@ import (
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel/attribute"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 
 	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
@@ -77,6 +80,484 @@ func TestGenerateKustomizations(t *testing.T) {
 	}
 }
 
+func TestGenerateKustomizations_namespaces(t *testing.T) {
+	testGenerators := map[string]generators.Generator{
+		"List": list.NewGenerator(),
+	}
+	namespaceTests := []struct {
+		name              string
+		namespace         string
+		allowedNamespaces []string
+		want              []kustomizev1.Kustomization
+		wantErr           string
+	}{
+		{
+			name: "no namespace in the template",
+			want: []kustomizev1.Kustomization{
+				makeTestKustomization(nsn("demo", "engineering-dev")),
+			},
+		},
+		{
+			name:      "templated namespace is the namespace of the set",
+			namespace: "{{.team}}",
+			want: []kustomizev1.Kustomization{
+				makeTestKustomization(nsn("demo", "engineering-dev")),
+			},
+		},
+		{
+			name:              "templated namespace in the allowed namespaces",
+			namespace:         "{{.team}}-ns",
+			allowedNamespaces: []string{"demo-ns"},
+			want: []kustomizev1.Kustomization{
+				makeTestKustomization(nsn("demo-ns", "engineering-dev")),
+			},
+		},
+		{
+			name:      "templated namespace not in the allowed namespaces",
+			namespace: "{{.team}}-ns",
+			wantErr:   "generated Kustomization engineering-dev-demo in namespace demo-ns is not permitted by set test-kustomizations",
+		},
+	}
+
+	for _, tt := range namespaceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			kset := makeTestKustomizationSet(withListElements([]apiextensionsv1.JSON{
+				{Raw: []byte(`{"cluster": "engineering-dev", "team": "demo"}`)},
+			}, nil), func(ks *sourcev1.KustomizationSet) {
+				ks.Spec.Template.Namespace = tt.namespace
+				ks.Spec.AllowedNamespaces = tt.allowedNamespaces
+			})
+			kusts, err := GenerateKustomizations(context.TODO(), kset, testGenerators)
+			if !test.MatchErrorString(t, tt.wantErr, err) {
+				t.Fatalf("failed to match error: got %s, want %s", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, kusts); diff != "" {
+				t.Fatalf("failed to generate kustomizations:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateKustomizations_duplicates(t *testing.T) {
+	testGenerators := map[string]generators.Generator{
+		"List": list.NewGenerator(),
+	}
+	withVersion := func(v string) func(*kustomizev1.Kustomization) {
+		return withLabels(map[string]string{"version": v})
+	}
+	duplicateTests := []struct {
+		name    string
+		policy  string
+		want    []kustomizev1.Kustomization
+		wantErr string
+	}{
+		{
+			name:    "default policy",
+			wantErr: `set test-kustomizations generated duplicate resources: Kustomization demo/engineering-dev-demo from parameters {"cluster":"engineering-dev","version":"1"}, {"cluster":"engineering-dev","version":"2"}`,
+		},
+		{
+			name:    "fail policy",
+			policy:  sourcev1.DuplicatePolicyFail,
+			wantErr: `set test-kustomizations generated duplicate resources: Kustomization demo/engineering-dev-demo from parameters .*`,
+		},
+		{
+			name:   "first wins policy",
+			policy: sourcev1.DuplicatePolicyFirstWins,
+			want: []kustomizev1.Kustomization{
+				makeTestKustomization(nsn("demo", "engineering-dev"), withVersion("1")),
+				makeTestKustomization(nsn("demo", "engineering-prod"), withVersion("1")),
+			},
+		},
+		{
+			name:   "last wins policy",
+			policy: sourcev1.DuplicatePolicyLastWins,
+			want: []kustomizev1.Kustomization{
+				makeTestKustomization(nsn("demo", "engineering-prod"), withVersion("1")),
+				makeTestKustomization(nsn("demo", "engineering-dev"), withVersion("2")),
+			},
+		},
+	}
+
+	for _, tt := range duplicateTests {
+		t.Run(tt.name, func(t *testing.T) {
+			kset := makeTestKustomizationSet(withListElements([]apiextensionsv1.JSON{
+				{Raw: []byte(`{"cluster": "engineering-dev", "version": "1"}`)},
+				{Raw: []byte(`{"cluster": "engineering-prod", "version": "1"}`)},
+				{Raw: []byte(`{"cluster": "engineering-dev", "version": "2"}`)},
+			}, &sourcev1.KustomizationSetTemplate{
+				KustomizationSetTemplateMeta: sourcev1.KustomizationSetTemplateMeta{
+					Labels: map[string]string{
+						"version": "{{ .version }}",
+					},
+				},
+			}), func(ks *sourcev1.KustomizationSet) {
+				ks.Spec.DuplicatePolicy = tt.policy
+			})
+			kusts, err := GenerateKustomizations(context.TODO(), kset, testGenerators)
+			if !test.MatchErrorString(t, tt.wantErr, err) {
+				t.Fatalf("failed to match error: got %s, want %s", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, kusts); diff != "" {
+				t.Fatalf("failed to generate kustomizations:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateResources(t *testing.T) {
+	testGenerators := map[string]generators.Generator{
+		"List": list.NewGenerator(),
+	}
+	isNamespaced := func(gvk schema.GroupVersionKind) (bool, error) {
+		return gvk.Kind != "Namespace", nil
+	}
+	elements := []apiextensionsv1.JSON{
+		{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+	}
+
+	resourceTests := []struct {
+		name    string
+		opts    []func(*sourcev1.KustomizationSet)
+		want    []*unstructured.Unstructured
+		wantErr string
+	}{
+		{
+			name: "kustomization template",
+			opts: []func(*sourcev1.KustomizationSet){
+				func(ks *sourcev1.KustomizationSet) {
+					ks.Spec.Template.Spec.KubeConfig = nil
+				},
+			},
+			want: []*unstructured.Unstructured{
+				{
+					Object: map[string]any{
+						"apiVersion": "kustomize.toolkit.fluxcd.io/v1beta2",
+						"kind":       "Kustomization",
+						"metadata": map[string]any{
+							"name":      "engineering-dev-demo",
+							"namespace": "demo",
+						},
+						"spec": map[string]any{
+							"interval": "5m0s",
+							"path":     "./clusters/engineering-dev/",
+							"prune":    true,
+							"sourceRef": map[string]any{
+								"kind": "GitRepository",
+								"name": "demo-repo",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "v1 kustomization template",
+			opts: []func(*sourcev1.KustomizationSet){
+				func(ks *sourcev1.KustomizationSet) {
+					ks.Spec.KustomizationVersion = sourcev1.KustomizationVersionV1
+					ks.Spec.Template.Spec.KubeConfig = nil
+					ks.Spec.Template.Spec.Validation = "client"
+				},
+			},
+			want: []*unstructured.Unstructured{
+				{
+					Object: map[string]any{
+						"apiVersion": "kustomize.toolkit.fluxcd.io/v1",
+						"kind":       "Kustomization",
+						"metadata": map[string]any{
+							"name":      "engineering-dev-demo",
+							"namespace": "demo",
+						},
+						"spec": map[string]any{
+							"interval": "5m0s",
+							"path":     "./clusters/engineering-dev/",
+							"prune":    true,
+							"sourceRef": map[string]any{
+								"kind": "GitRepository",
+								"name": "demo-repo",
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "v1 kustomization template with removed fields",
+			opts: []func(*sourcev1.KustomizationSet){
+				func(ks *sourcev1.KustomizationSet) {
+					ks.Spec.KustomizationVersion = sourcev1.KustomizationVersionV1
+					ks.Spec.Template.Spec.PatchesStrategicMerge = []apiextensionsv1.JSON{{Raw: []byte(`{}`)}}
+				},
+			},
+			wantErr: "patchesStrategicMerge is not supported by the Kustomization v1 API",
+		},
+		{
+			name: "namespaced resource template",
+			opts: []func(*sourcev1.KustomizationSet){
+				withResourceTemplate(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{.cluster}}-config"}}`),
+			},
+			want: []*unstructured.Unstructured{
+				{
+					Object: map[string]any{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata": map[string]any{
+							"name":      "engineering-dev-config",
+							"namespace": "demo",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "cluster-scoped resource template",
+			opts: []func(*sourcev1.KustomizationSet){
+				withResourceTemplate(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "{{.cluster}}", "namespace": "demo"}}`),
+			},
+			want: []*unstructured.Unstructured{
+				{
+					Object: map[string]any{
+						"apiVersion": "v1",
+						"kind":       "Namespace",
+						"metadata": map[string]any{
+							"name": "engineering-dev",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "resource template in a namespace that is not allowed",
+			opts: []func(*sourcev1.KustomizationSet){
+				withResourceTemplate(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{.cluster}}-config", "namespace": "other"}}`),
+			},
+			wantErr: "generated ConfigMap engineering-dev-config in namespace other is not permitted by set test-kustomizations",
+		},
+		{
+			name: "resource template with no kind",
+			opts: []func(*sourcev1.KustomizationSet){
+				withResourceTemplate(`{"metadata": {"name": "{{.cluster}}-config"}}`),
+			},
+			wantErr: "failed to parse rendered resource template",
+		},
+		{
+			name: "both templates",
+			opts: []func(*sourcev1.KustomizationSet){
+				func(ks *sourcev1.KustomizationSet) {
+					ks.Spec.ResourceTemplate = &apiextensionsv1.JSON{Raw: []byte(`{}`)}
+				},
+			},
+			wantErr: "set test-kustomizations must have exactly one of template, resourceTemplate or templates",
+		},
+		{
+			name: "multiple templates",
+			opts: []func(*sourcev1.KustomizationSet){
+				withResourceTemplates(
+					sourcev1.KustomizationSetResourceTemplate{
+						Name:     "namespace",
+						Resource: apiextensionsv1.JSON{Raw: []byte(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "{{.cluster}}"}}`)},
+					},
+					sourcev1.KustomizationSetResourceTemplate{
+						Name:     "config",
+						Resource: apiextensionsv1.JSON{Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{.cluster}}-config"}}`)},
+					},
+				),
+			},
+			want: []*unstructured.Unstructured{
+				{
+					Object: map[string]any{
+						"apiVersion": "v1",
+						"kind":       "Namespace",
+						"metadata": map[string]any{
+							"name": "engineering-dev",
+						},
+					},
+				},
+				{
+					Object: map[string]any{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata": map[string]any{
+							"name":      "engineering-dev-config",
+							"namespace": "demo",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "multiple templates with conditions",
+			opts: []func(*sourcev1.KustomizationSet){
+				withResourceTemplates(
+					sourcev1.KustomizationSetResourceTemplate{
+						Name:      "namespace",
+						Condition: `{{ eq .cluster "engineering-prod" }}`,
+						Resource:  apiextensionsv1.JSON{Raw: []byte(`{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "{{.cluster}}"}}`)},
+					},
+					sourcev1.KustomizationSetResourceTemplate{
+						Name:      "config",
+						Condition: `{{ eq .cluster "engineering-dev" }}`,
+						Resource:  apiextensionsv1.JSON{Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{.cluster}}-config"}}`)},
+					},
+				),
+			},
+			want: []*unstructured.Unstructured{
+				{
+					Object: map[string]any{
+						"apiVersion": "v1",
+						"kind":       "ConfigMap",
+						"metadata": map[string]any{
+							"name":      "engineering-dev-config",
+							"namespace": "demo",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "template with an invalid condition",
+			opts: []func(*sourcev1.KustomizationSet){
+				withResourceTemplates(
+					sourcev1.KustomizationSetResourceTemplate{
+						Name:      "config",
+						Condition: `{{ eq .cluster }}`,
+						Resource:  apiextensionsv1.JSON{Raw: []byte(`{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "{{.cluster}}-config"}}`)},
+					},
+				),
+			},
+			wantErr: "failed to render template config for set test-kustomizations: failed to render condition",
+		},
+	}
+
+	for _, tt := range resourceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			kset := makeTestKustomizationSet(append([]func(*sourcev1.KustomizationSet){withListElements(elements, nil)}, tt.opts...)...)
+			resources, err := GenerateResources(context.TODO(), kset, testGenerators, GenerateOptions{IsNamespaced: isNamespaced})
+			if !test.MatchErrorString(t, tt.wantErr, err) {
+				t.Fatalf("failed to match error: got %s, want %s", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, generatedObjects(resources)); diff != "" {
+				t.Fatalf("failed to generate resources:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGenerateResources_records_generator_policy(t *testing.T) {
+	kset := makeTestKustomizationSet(
+		withListElements([]apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "engineering-dev"}`)}}, nil),
+		withListElements([]apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "engineering-prod"}`)}}, nil),
+		func(ks *sourcev1.KustomizationSet) {
+			ks.Spec.Generators[1].Policy = sourcev1.GeneratorPolicyCreateOnly
+		},
+	)
+
+	testGenerators := map[string]generators.Generator{
+		"List": list.NewGenerator(),
+	}
+	resources, err := GenerateResources(context.TODO(), kset, testGenerators, GenerateOptions{})
+	test.AssertNoError(t, err)
+
+	policies := map[string]string{}
+	for _, v := range resources {
+		policies[v.Resource.GetName()] = v.Policy
+	}
+	want := map[string]string{
+		"engineering-dev-demo":  "",
+		"engineering-prod-demo": sourcev1.GeneratorPolicyCreateOnly,
+	}
+	if diff := cmp.Diff(want, policies); diff != "" {
+		t.Fatalf("failed to record policies:\n%s", diff)
+	}
+}
+
+func TestGenerateResources_records_generator(t *testing.T) {
+	kset := makeTestKustomizationSet(
+		withListElements([]apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "engineering-dev"}`)}}, nil),
+		withListElements([]apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "engineering-prod"}`)}}, nil),
+	)
+
+	testGenerators := map[string]generators.Generator{
+		"List": list.NewGenerator(),
+	}
+	resources, err := GenerateResources(context.TODO(), kset, testGenerators, GenerateOptions{})
+	test.AssertNoError(t, err)
+
+	generatorIDs := map[string]string{}
+	for _, v := range resources {
+		generatorIDs[v.Resource.GetName()] = v.Generator
+	}
+	want := map[string]string{
+		"engineering-dev-demo":  "0/List",
+		"engineering-prod-demo": "1/List",
+	}
+	if diff := cmp.Diff(want, generatorIDs); diff != "" {
+		t.Fatalf("failed to record generators:\n%s", diff)
+	}
+}
+
+func TestGenerateResources_with_disabled_generator(t *testing.T) {
+	kset := makeTestKustomizationSet(
+		withListElements([]apiextensionsv1.JSON{{Raw: []byte(`{"cluster": "engineering-dev"}`)}}, nil),
+	)
+
+	_, err := GenerateResources(context.TODO(), kset, map[string]generators.Generator{}, GenerateOptions{})
+	test.AssertErrorMatch(t, "the List generator is not enabled", err)
+}
+
+func TestGenerateResources_records_spans(t *testing.T) {
+	exporter := test.RecordSpans(t)
+	kset := makeTestKustomizationSet(
+		withListElements([]apiextensionsv1.JSON{
+			{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+			{Raw: []byte(`{"cluster": "engineering-prod"}`)},
+		}, nil),
+	)
+
+	testGenerators := map[string]generators.Generator{
+		"List": list.NewGenerator(),
+	}
+	_, err := GenerateResources(context.TODO(), kset, testGenerators, GenerateOptions{})
+	test.AssertNoError(t, err)
+
+	want := []string{"Generate", "RenderTemplate", "RenderTemplate"}
+	if diff := cmp.Diff(want, test.SpanNames(exporter)); diff != "" {
+		t.Fatalf("failed to record spans:\n%s", diff)
+	}
+	generate := exporter.GetSpans()[0]
+	if diff := cmp.Diff([]attribute.KeyValue{attribute.String("generator", "List"), attribute.Int("params", 2)}, generate.Attributes, cmp.AllowUnexported(attribute.Value{})); diff != "" {
+		t.Fatalf("failed to record generator attributes:\n%s", diff)
+	}
+}
+
+func generatedObjects(resources []GeneratedResource) []*unstructured.Unstructured {
+	if resources == nil {
+		return nil
+	}
+	objs := []*unstructured.Unstructured{}
+	for _, v := range resources {
+		objs = append(objs, v.Resource)
+	}
+	return objs
+}
+
+func withResourceTemplate(s string) func(*sourcev1.KustomizationSet) {
+	return func(ks *sourcev1.KustomizationSet) {
+		ks.Spec.Template = nil
+		ks.Spec.ResourceTemplate = &apiextensionsv1.JSON{Raw: []byte(s)}
+	}
+}
+
+func withResourceTemplates(templates ...sourcev1.KustomizationSetResourceTemplate) func(*sourcev1.KustomizationSet) {
+	return func(ks *sourcev1.KustomizationSet) {
+		ks.Spec.Template = nil
+		ks.Spec.Templates = templates
+	}
+}
+
 func withListElements(el []apiextensionsv1.JSON, tp *sourcev1.KustomizationSetTemplate) func(*sourcev1.KustomizationSet) {
 	return func(ks *sourcev1.KustomizationSet) {
 		if ks.Spec.Generators == nil {
@@ -99,7 +580,7 @@ func makeTestKustomizationSet(opts ...func(*sourcev1.KustomizationSet)) *sourcev
 			Namespace: testKustomizationSetNamespace,
 		},
 		Spec: sourcev1.KustomizationSetSpec{
-			Template: sourcev1.KustomizationSetTemplate{
+			Template: &sourcev1.KustomizationSetTemplate{
 				KustomizationSetTemplateMeta: sourcev1.KustomizationSetTemplateMeta{
 					Name: `{{.cluster}}-demo`,
 				},
@@ -165,3 +646,28 @@ func nsn(namespace, name string) types.NamespacedName {
 		Namespace: namespace,
 	}
 }
+
+func TestKustomizationVersion(t *testing.T) {
+	versionTests := []struct {
+		name           string
+		setVersion     string
+		defaultVersion string
+		want           string
+	}{
+		{name: "no versions", want: "v1beta2"},
+		{name: "default version", defaultVersion: "v1", want: "v1"},
+		{name: "set version", setVersion: "v1beta2", defaultVersion: "v1", want: "v1beta2"},
+	}
+
+	for _, tt := range versionTests {
+		t.Run(tt.name, func(t *testing.T) {
+			kset := makeTestKustomizationSet(func(ks *sourcev1.KustomizationSet) {
+				ks.Spec.KustomizationVersion = tt.setVersion
+			})
+
+			if got := KustomizationVersion(kset, tt.defaultVersion); got != tt.want {
+				t.Fatalf("got version %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,20 +2,31 @@ package reconciler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"text/template"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/tracing"
 	"github.com/gitops-tools/pkg/sanitize"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 var funcMap = template.FuncMap{
 	"sanitize": sanitize.SanitizeDNSName,
 }
 
-func renderTemplateParams(tmpl *kustomizev1.Kustomization, params map[string]any) (*kustomizev1.Kustomization, error) {
+func renderTemplateParams(ctx context.Context, tmpl *kustomizev1.Kustomization, params map[string]any) (_ *kustomizev1.Kustomization, err error) {
+	_, span := tracing.Start(ctx, "RenderTemplate")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	if tmpl == nil {
 		return nil, errors.New("application template is empty ")
 	}
@@ -43,6 +54,50 @@ func renderTemplateParams(tmpl *kustomizev1.Kustomization, params map[string]any
 	return &updated, nil
 }
 
+// renderResourceSetTemplate renders the template with the params, if the
+// template has a condition that doesn't render "true" then no resource is
+// returned.
+func renderResourceSetTemplate(ctx context.Context, tmpl sourcev1.KustomizationSetResourceTemplate, params map[string]any) (_ *unstructured.Unstructured, err error) {
+	_, span := tracing.Start(ctx, "RenderTemplate", attribute.String("template", tmpl.Name))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if tmpl.Condition != "" {
+		rendered, err := render([]byte(tmpl.Condition), params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render condition: %w", err)
+		}
+		if strings.TrimSpace(string(rendered)) != "true" {
+			return nil, nil
+		}
+	}
+
+	return renderResourceTemplate(tmpl.Resource.Raw, params)
+}
+
+func renderResourceTemplate(tmpl []byte, params map[string]any) (*unstructured.Unstructured, error) {
+	if len(tmpl) == 0 {
+		return nil, errors.New("resource template is empty")
+	}
+
+	rendered, err := render(tmpl, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var updated unstructured.Unstructured
+	if err := updated.UnmarshalJSON(rendered); err != nil {
+		return nil, fmt.Errorf("failed to parse rendered resource template: %w", err)
+	}
+
+	if updated.GetAPIVersion() == "" || updated.GetKind() == "" {
+		return nil, errors.New("rendered resource template must have an apiVersion and kind")
+	}
+
+	return &updated, nil
+}
+
 func render(b []byte, params map[string]any) ([]byte, error) {
 	t, err := template.New("kustomization").Funcs(funcMap).Parse(string(b))
 	if err != nil {
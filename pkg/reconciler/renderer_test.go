@@ -1,6 +1,7 @@
 package reconciler
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -61,7 +62,7 @@ func TestRenderTemplate(t *testing.T) {
 
 	for _, tt := range templateTests {
 		t.Run(tt.name, func(t *testing.T) {
-			rendered, err := renderTemplateParams(tt.tmpl, tt.params)
+			rendered, err := renderTemplateParams(context.TODO(), tt.tmpl, tt.params)
 			test.AssertNoError(t, err)
 
 			if diff := cmp.Diff(tt.want, rendered); diff != "" {
@@ -84,7 +85,7 @@ func TestRenderTemplate_errors(t *testing.T) {
 
 	for _, tt := range templateTests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := renderTemplateParams(tt.tmpl, tt.params)
+			_, err := renderTemplateParams(context.TODO(), tt.tmpl, tt.params)
 
 			test.AssertErrorMatch(t, tt.wantErr, err)
 		})
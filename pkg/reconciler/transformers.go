@@ -2,11 +2,17 @@ package reconciler
 
 import (
 	"context"
+	"fmt"
 	"reflect"
+	"time"
 
 	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/metrics"
 	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler/generators"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/tracing"
 	"github.com/imdario/mergo"
+	"go.opentelemetry.io/otel/attribute"
+	"sigs.k8s.io/controller-runtime/pkg/log"
 )
 
 type transformResult struct {
@@ -16,14 +22,17 @@ type transformResult struct {
 
 func transform(ctx context.Context, generator sourcev1.KustomizationSetGenerator, allGenerators map[string]generators.Generator, baseTemplate sourcev1.KustomizationSetTemplate, kustomizeSet *sourcev1.KustomizationSet) ([]transformResult, error) {
 	res := []transformResult{}
-	generators := findRelevantGenerators(&generator, allGenerators)
+	generators, err := findRelevantGenerators(&generator, allGenerators)
+	if err != nil {
+		return nil, err
+	}
 	for _, g := range generators {
 		mergedTemplate, err := mergeGeneratorTemplate(g, &generator, baseTemplate)
 		if err != nil {
 			return nil, err
 		}
 
-		params, err := g.Generate(ctx, &generator, kustomizeSet)
+		params, err := g.generate(ctx, &generator, kustomizeSet)
 		if err != nil {
 			return nil, err
 		}
@@ -36,26 +45,91 @@ func transform(ctx context.Context, generator sourcev1.KustomizationSetGenerator
 	return res, nil
 }
 
-func findRelevantGenerators(setGenerator *sourcev1.KustomizationSetGenerator, allGenerators map[string]generators.Generator) []generators.Generator {
-	var res []generators.Generator
+// generateParams returns the parameters from the generators configured for
+// the generator, without any templates.
+func generateParams(ctx context.Context, generator sourcev1.KustomizationSetGenerator, allGenerators map[string]generators.Generator, kustomizeSet *sourcev1.KustomizationSet) ([]map[string]any, error) {
+	res := []map[string]any{}
+	generators, err := findRelevantGenerators(&generator, allGenerators)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range generators {
+		params, err := g.generate(ctx, &generator, kustomizeSet)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, params...)
+	}
+	return res, nil
+}
+
+// namedGenerator is a configured generator, and the name of the field that
+// configures it, which is used to label metrics.
+type namedGenerator struct {
+	generators.Generator
+	name string
+}
+
+// generate generates the parameters and records the metrics and a span for
+// the generator, the generator is added to the context logger.
+func (g namedGenerator) generate(ctx context.Context, setGenerator *sourcev1.KustomizationSetGenerator, kustomizeSet *sourcev1.KustomizationSet) ([]map[string]any, error) {
+	logger := log.FromContext(ctx).WithValues("generator", g.name)
+	ctx = log.IntoContext(ctx, logger)
+	ctx, span := tracing.Start(ctx, "Generate", attribute.String("generator", g.name))
+	start := time.Now()
+	params, err := g.Generate(ctx, setGenerator, kustomizeSet)
+	metrics.ObserveGenerate(g.name, start, err)
+	span.SetAttributes(attribute.Int("params", len(params)))
+	if err == nil {
+		logger.V(1).Info("generated parameters", "count", len(params))
+	}
+	tracing.End(span, err)
+
+	return params, err
+}
+
+// findRelevantGenerators returns the generators that are configured for the
+// generator, it's an error if a configured generator isn't enabled.
+func findRelevantGenerators(setGenerator *sourcev1.KustomizationSetGenerator, allGenerators map[string]generators.Generator) ([]namedGenerator, error) {
+	var res []namedGenerator
+	for _, name := range generatorNames(setGenerator) {
+		g, ok := allGenerators[name]
+		if !ok {
+			return nil, fmt.Errorf("the %s generator is not enabled", name)
+		}
+		res = append(res, namedGenerator{Generator: g, name: name})
+	}
+	return res, nil
+}
+
+// generatorNames returns the names of the fields that configure generators.
+func generatorNames(setGenerator *sourcev1.KustomizationSetGenerator) []string {
+	var names []string
 	v := reflect.Indirect(reflect.ValueOf(setGenerator))
 	for i := 0; i < v.NumField(); i++ {
 		field := v.Field(i)
-		if !field.CanInterface() {
+		// Only the pointer fields configure generators.
+		if !field.CanInterface() || field.Kind() != reflect.Pointer {
 			continue
 		}
 
 		if !reflect.ValueOf(field.Interface()).IsNil() {
-			res = append(res, allGenerators[v.Type().Field(i).Name])
+			names = append(names, v.Type().Field(i).Name)
 		}
 	}
-	return res
+	return names
 }
 
 func mergeGeneratorTemplate(g generators.Generator, setGenerator *sourcev1.KustomizationSetGenerator, kustomizationSetTemplate sourcev1.KustomizationSetTemplate) (sourcev1.KustomizationSetTemplate, error) {
+	return mergeTemplates(g.Template(setGenerator), kustomizationSetTemplate)
+}
+
+// mergeTemplates merges the KustomizationSet template into the generator
+// template, fields set in the generator template take precedence.
+func mergeTemplates(generatorTemplate *sourcev1.KustomizationSetTemplate, kustomizationSetTemplate sourcev1.KustomizationSetTemplate) (sourcev1.KustomizationSetTemplate, error) {
 	// Make a copy of the value from `Template()` before merge, rather than copying directly into
 	// the provided parameter (which will touch the original resource object returned by client-go)
-	dest := g.Template(setGenerator).DeepCopy()
+	dest := generatorTemplate.DeepCopy()
 	if dest == nil {
 		return kustomizationSetTemplate, nil
 	}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the name of the tracer used for the controller's spans.
+const tracerName = "github.com/gitops-tools/kustomization-set-controller"
+
+const (
+	// ProtocolGRPC exports spans with OTLP over gRPC.
+	ProtocolGRPC = "grpc"
+
+	// ProtocolHTTP exports spans with OTLP over HTTP.
+	ProtocolHTTP = "http"
+)
+
+// Options configures the export of spans.
+type Options struct {
+	// Endpoint is the host and port of the OTLP receiver, if this is empty
+	// then spans are not exported.
+	Endpoint string
+
+	// Protocol is the protocol used to export spans, grpc or http.
+	Protocol string
+
+	// Insecure disables TLS when exporting spans.
+	Insecure bool
+
+	// SampleRatio is the fraction of traces that are sampled, traces with a
+	// sampled parent are always sampled.
+	SampleRatio float64
+
+	// ServiceName is recorded as the name of the service in exported spans.
+	ServiceName string
+}
+
+// BindFlags binds the flags that configure the export of spans.
+func (o *Options) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.Endpoint, "otlp-endpoint", "",
+		"The host:port of an OpenTelemetry OTLP receiver to export traces to, traces are not recorded if this is empty.")
+	fs.StringVar(&o.Protocol, "otlp-protocol", ProtocolGRPC,
+		"The protocol used to export traces to the OTLP receiver (grpc or http).")
+	fs.BoolVar(&o.Insecure, "otlp-insecure", false,
+		"Disable TLS when exporting traces to the OTLP receiver.")
+	fs.Float64Var(&o.SampleRatio, "trace-sample-ratio", 1.0,
+		"The fraction of reconciliations that are traced, between 0 and 1.")
+}
+
+// Setup configures the global TracerProvider to export spans with the
+// options.
+//
+// If no endpoint is configured, the global TracerProvider is left as the
+// default no-op provider, and the returned shutdown function does nothing.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	if opts.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := newExporter(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(opts.ServiceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return tp.Shutdown, nil
+}
+
+func newExporter(ctx context.Context, opts Options) (*otlptrace.Exporter, error) {
+	switch opts.Protocol {
+	case ProtocolGRPC:
+		clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, clientOpts...)
+	case ProtocolHTTP:
+		clientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(opts.Endpoint)}
+		if opts.Insecure {
+			clientOpts = append(clientOpts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, clientOpts...)
+	}
+
+	return nil, fmt.Errorf("unsupported protocol %q", opts.Protocol)
+}
+
+// Start starts a span from the global TracerProvider, the returned context
+// carries the span.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error on the span, if there is one, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// HTTPClient returns a copy of the client that records spans for requests,
+// and propagates the trace context in the request headers.
+//
+// A nil client is treated as http.DefaultClient.
+func HTTPClient(c *http.Client) *http.Client {
+	if c == nil {
+		c = http.DefaultClient
+	}
+	traced := *c
+	transport := traced.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	traced.Transport = otelhttp.NewTransport(transport)
+
+	return &traced
+}
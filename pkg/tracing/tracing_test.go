@@ -0,0 +1,97 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+func TestSetup_without_an_endpoint(t *testing.T) {
+	shutdown, err := Setup(context.TODO(), Options{Protocol: ProtocolGRPC})
+	test.AssertNoError(t, err)
+	defer func() {
+		test.AssertNoError(t, shutdown(context.TODO()))
+	}()
+
+	_, span := Start(context.TODO(), "test-span")
+	defer span.End()
+
+	if span.SpanContext().IsValid() || span.IsRecording() {
+		t.Fatal("span was recorded without an endpoint")
+	}
+}
+
+func TestSetup_with_an_unsupported_protocol(t *testing.T) {
+	_, err := Setup(context.TODO(), Options{Endpoint: "localhost:4317", Protocol: "unknown"})
+
+	test.AssertErrorMatch(t, `unsupported protocol "unknown"`, err)
+}
+
+func TestEnd(t *testing.T) {
+	endTests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{name: "without an error", want: codes.Unset},
+		{name: "with an error", err: errors.New("test failure"), want: codes.Error},
+	}
+
+	for _, tt := range endTests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := test.RecordSpans(t)
+
+			_, span := Start(context.TODO(), "test-span")
+			End(span, tt.err)
+
+			spans := exporter.GetSpans()
+			if len(spans) != 1 {
+				t.Fatalf("got %d spans, want 1", len(spans))
+			}
+			if spans[0].Status.Code != tt.want {
+				t.Fatalf("got status %v, want %v", spans[0].Status.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	exporter := test.RecordSpans(t)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer ts.Close()
+
+	ctx, span := Start(context.TODO(), "test-span")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL, nil)
+	test.AssertNoError(t, err)
+	resp, err := HTTPClient(nil).Do(req)
+	test.AssertNoError(t, err)
+	resp.Body.Close()
+	span.End()
+
+	if traceparent == "" {
+		t.Fatal("trace context was not propagated")
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Parent.SpanID() != span.SpanContext().SpanID() {
+		t.Fatal("request span is not a child of the parent span")
+	}
+}
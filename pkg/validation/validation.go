@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	"github.com/gitops-tools/kustomization-set-controller/pkg/reconciler"
+)
+
+// ValidateKustomizationSet checks the KustomizationSet for problems that would
+// prevent it from being reconciled, and that are not checked by the schema.
+func ValidateKustomizationSet(set *sourcev1.KustomizationSet) field.ErrorList {
+	errs := validateTemplateCount(set)
+	for i, gen := range set.Spec.Generators {
+		errs = append(errs, validateGenerator(field.NewPath("spec", "generators").Index(i), gen)...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if _, err := reconciler.TemplateParams(set); err != nil {
+		errs = append(errs, field.Invalid(templatePath(set), field.OmitValueType{}, err.Error()))
+	}
+
+	return errs
+}
+
+// GeneratorKinds returns the kinds of generator that are configured in the
+// generator.
+func GeneratorKinds(gen sourcev1.KustomizationSetGenerator) []string {
+	kinds := []string{}
+	if gen.List != nil {
+		kinds = append(kinds, "list")
+	}
+	if gen.PullRequest != nil {
+		kinds = append(kinds, "pullRequest")
+	}
+	if gen.GitRepository != nil {
+		kinds = append(kinds, "gitRepository")
+	}
+
+	return kinds
+}
+
+func validateTemplateCount(set *sourcev1.KustomizationSet) field.ErrorList {
+	templates := 0
+	for _, configured := range []bool{set.Spec.Template != nil, set.Spec.ResourceTemplate != nil, len(set.Spec.Templates) > 0} {
+		if configured {
+			templates++
+		}
+	}
+	if templates != 1 {
+		return field.ErrorList{field.Invalid(field.NewPath("spec"), field.OmitValueType{}, "exactly one of template, resourceTemplate or templates must be provided")}
+	}
+
+	return nil
+}
+
+func validateGenerator(path *field.Path, gen sourcev1.KustomizationSetGenerator) field.ErrorList {
+	switch kinds := GeneratorKinds(gen); len(kinds) {
+	case 0:
+		return field.ErrorList{field.Required(path, "one of list, pullRequest or gitRepository must be configured")}
+	case 1:
+	default:
+		return field.ErrorList{field.Forbidden(path, fmt.Sprintf("only one of list, pullRequest or gitRepository can be configured, got %s", strings.Join(kinds, " and ")))}
+	}
+
+	errs := field.ErrorList{}
+	if pr := gen.PullRequest; pr != nil {
+		if pr.ServerURL != "" && !strings.HasPrefix(pr.ServerURL, "https://") {
+			errs = append(errs, field.Invalid(path.Child("pullRequest", "serverURL"), pr.ServerURL, "must be an https:// URL"))
+		}
+		if pr.Repo == "" {
+			errs = append(errs, field.Required(path.Child("pullRequest", "repo"), "the repository to query for pull requests must be provided, e.g. my-org/my-repo"))
+		}
+	}
+	if repo := gen.GitRepository; repo != nil && repo.RepositoryRef == "" {
+		errs = append(errs, field.Required(path.Child("gitRepository", "repositoryRef"), "the name of a GitRepository in the namespace of the KustomizationSet must be provided"))
+	}
+
+	return errs
+}
+
+func templatePath(set *sourcev1.KustomizationSet) *field.Path {
+	switch {
+	case set.Spec.Template != nil:
+		return field.NewPath("spec", "template")
+	case set.Spec.ResourceTemplate != nil:
+		return field.NewPath("spec", "resourceTemplate")
+	}
+	return field.NewPath("spec", "templates")
+}
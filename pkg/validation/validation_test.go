@@ -0,0 +1,161 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/google/go-cmp/cmp"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+func TestValidateKustomizationSet(t *testing.T) {
+	validationTests := []struct {
+		name string
+		opts []func(*sourcev1.KustomizationSet)
+		want []string
+	}{
+		{
+			name: "valid set",
+		},
+		{
+			name: "template that can't be parsed",
+			opts: []func(*sourcev1.KustomizationSet){
+				func(ks *sourcev1.KustomizationSet) {
+					ks.Spec.Template.Name = "{{ .cluster }"
+				},
+			},
+			want: []string{`spec.template: Invalid value: failed to parse the template for generator 0 of set test-set: failed to parse template: template: kustomization:1: unexpected "}" in operand`},
+		},
+		{
+			name: "no templates",
+			opts: []func(*sourcev1.KustomizationSet){
+				func(ks *sourcev1.KustomizationSet) {
+					ks.Spec.Template = nil
+				},
+			},
+			want: []string{"spec: Invalid value: exactly one of template, resourceTemplate or templates must be provided"},
+		},
+		{
+			name: "generator with no generator types",
+			opts: []func(*sourcev1.KustomizationSet){
+				withGenerators(sourcev1.KustomizationSetGenerator{}),
+			},
+			want: []string{"spec.generators[1]: Required value: one of list, pullRequest or gitRepository must be configured"},
+		},
+		{
+			name: "generator with multiple generator types",
+			opts: []func(*sourcev1.KustomizationSet){
+				withGenerators(sourcev1.KustomizationSetGenerator{
+					PullRequest: &sourcev1.PullRequestGenerator{
+						Driver: "github",
+						Repo:   "test-org/test-repo",
+					},
+					GitRepository: &sourcev1.GitRepositoryGenerator{
+						RepositoryRef: "demo-repo",
+					},
+				}),
+			},
+			want: []string{"spec.generators[1]: Forbidden: only one of list, pullRequest or gitRepository can be configured, got pullRequest and gitRepository"},
+		},
+		{
+			name: "pull request with an insecure server URL",
+			opts: []func(*sourcev1.KustomizationSet){
+				withGenerators(sourcev1.KustomizationSetGenerator{
+					PullRequest: &sourcev1.PullRequestGenerator{
+						Driver:    "gitlab",
+						ServerURL: "http://gitlab.example.com",
+						Repo:      "test-org/test-repo",
+					},
+				}),
+			},
+			want: []string{`spec.generators[1].pullRequest.serverURL: Invalid value: "http://gitlab.example.com": must be an https:// URL`},
+		},
+		{
+			name: "pull request without a repo",
+			opts: []func(*sourcev1.KustomizationSet){
+				withGenerators(sourcev1.KustomizationSetGenerator{
+					PullRequest: &sourcev1.PullRequestGenerator{
+						Driver: "github",
+					},
+				}),
+			},
+			want: []string{"spec.generators[1].pullRequest.repo: Required value: the repository to query for pull requests must be provided, e.g. my-org/my-repo"},
+		},
+		{
+			name: "git repository without a repository ref",
+			opts: []func(*sourcev1.KustomizationSet){
+				withGenerators(sourcev1.KustomizationSetGenerator{
+					GitRepository: &sourcev1.GitRepositoryGenerator{},
+				}),
+			},
+			want: []string{"spec.generators[1].gitRepository.repositoryRef: Required value: the name of a GitRepository in the namespace of the KustomizationSet must be provided"},
+		},
+	}
+
+	for _, tt := range validationTests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateKustomizationSet(newKustomizationSet(tt.opts...))
+
+			var got []string
+			for _, v := range errs {
+				got = append(got, v.Error())
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("failed to validate:\n%s", diff)
+			}
+		})
+	}
+}
+
+func withGenerators(gens ...sourcev1.KustomizationSetGenerator) func(*sourcev1.KustomizationSet) {
+	return func(ks *sourcev1.KustomizationSet) {
+		ks.Spec.Generators = append(ks.Spec.Generators, gens...)
+	}
+}
+
+func newKustomizationSet(opts ...func(*sourcev1.KustomizationSet)) *sourcev1.KustomizationSet {
+	ks := &sourcev1.KustomizationSet{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "KustomizationSet",
+			APIVersion: "source.gitops.solutions/v1alpha1",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-set",
+			Namespace: "default",
+		},
+		Spec: sourcev1.KustomizationSetSpec{
+			Generators: []sourcev1.KustomizationSetGenerator{
+				{
+					List: &sourcev1.ListGenerator{
+						Elements: []apiextensionsv1.JSON{
+							{Raw: []byte(`{"cluster": "engineering-dev"}`)},
+						},
+					},
+				},
+			},
+			Template: &sourcev1.KustomizationSetTemplate{
+				KustomizationSetTemplateMeta: sourcev1.KustomizationSetTemplateMeta{
+					Name: "{{.cluster}}-demo",
+				},
+				Spec: kustomizev1.KustomizationSpec{
+					Interval: metav1.Duration{Duration: 5 * time.Minute},
+					Path:     "./clusters/{{.cluster}}/",
+					Prune:    true,
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind: "GitRepository",
+						Name: "demo-repo",
+					},
+				},
+			},
+		},
+	}
+	for _, o := range opts {
+		o(ks)
+	}
+
+	return ks
+}
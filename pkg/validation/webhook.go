@@ -0,0 +1,57 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-source-gitops-solutions-v1alpha1-kustomizationset,mutating=false,failurePolicy=fail,sideEffects=None,groups=source.gitops.solutions,resources=kustomizationsets,verbs=create;update,versions=v1alpha1,name=vkustomizationset.gitops.solutions,admissionReviewVersions=v1
+
+// KustomizationSetValidator rejects KustomizationSets that can't be
+// reconciled when they are created or updated.
+type KustomizationSetValidator struct{}
+
+// SetupWebhookWithManager registers the validating webhook with the manager.
+func (v *KustomizationSetValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&sourcev1.KustomizationSet{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator.
+func (v *KustomizationSetValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator.
+func (v *KustomizationSetValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	return v.validate(newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator.
+func (v *KustomizationSetValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	return nil
+}
+
+func (v *KustomizationSetValidator) validate(obj runtime.Object) error {
+	set, ok := obj.(*sourcev1.KustomizationSet)
+	if !ok {
+		return fmt.Errorf("expected a KustomizationSet, got %T", obj)
+	}
+	// Sets that are being deleted are updated to remove the finalizer.
+	if !set.GetDeletionTimestamp().IsZero() {
+		return nil
+	}
+	if errs := ValidateKustomizationSet(set); len(errs) > 0 {
+		return apierrors.NewInvalid(sourcev1.GroupVersion.WithKind("KustomizationSet").GroupKind(), set.GetName(), errs)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package validation
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+
+	sourcev1 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha1"
+	sourcev1alpha2 "github.com/gitops-tools/kustomization-set-controller/api/v1alpha2"
+	"github.com/gitops-tools/kustomization-set-controller/test"
+)
+
+func TestKustomizationSetValidator(t *testing.T) {
+	testEnv := &envtest.Environment{
+		ErrorIfCRDPathMissing: true,
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		WebhookInstallOptions: envtest.WebhookInstallOptions{
+			Paths: []string{filepath.Join("..", "..", "config", "webhook")},
+		},
+	}
+	// The versions must be registered before starting the environment to
+	// configure the conversion webhook in the CRD.
+	if err := sourcev1.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := sourcev1alpha2.AddToScheme(scheme.Scheme); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testEnv.Stop()
+
+	webhookOpts := testEnv.WebhookInstallOptions
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme.Scheme,
+		Host:               webhookOpts.LocalServingHost,
+		Port:               webhookOpts.LocalServingPort,
+		CertDir:            webhookOpts.LocalServingCertDir,
+		LeaderElection:     false,
+		MetricsBindAddress: "0",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := (&KustomizationSetValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			t.Error(err)
+		}
+	}()
+	waitForWebhookServer(t, webhookOpts)
+
+	k8sClient, err := client.New(cfg, client.Options{Scheme: scheme.Scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("creating a valid set", func(t *testing.T) {
+		ks := newKustomizationSet()
+		test.AssertNoError(t, k8sClient.Create(ctx, ks))
+		defer deleteKustomizationSet(t, k8sClient, ks)
+	})
+
+	t.Run("creating an invalid set", func(t *testing.T) {
+		ks := newKustomizationSet(withGenerators(sourcev1.KustomizationSetGenerator{
+			GitRepository: &sourcev1.GitRepositoryGenerator{},
+		}))
+		err := k8sClient.Create(ctx, ks)
+		test.AssertErrorMatch(t, `admission webhook "vkustomizationset.gitops.solutions" denied the request: KustomizationSet.source.gitops.solutions "test-set" is invalid: spec.generators\[1\].gitRepository.repositoryRef: Required value`, err)
+	})
+
+	t.Run("updating a set with an invalid template", func(t *testing.T) {
+		ks := newKustomizationSet()
+		test.AssertNoError(t, k8sClient.Create(ctx, ks))
+		defer deleteKustomizationSet(t, k8sClient, ks)
+
+		ks.Spec.Template.Name = "{{ .cluster }"
+		err := k8sClient.Update(ctx, ks)
+		test.AssertErrorMatch(t, `denied the request: .* spec.template: Invalid value: failed to parse the template`, err)
+	})
+
+	t.Run("converting between versions", func(t *testing.T) {
+		ks := &sourcev1alpha2.KustomizationSet{}
+		test.AssertNoError(t, ks.ConvertFrom(newKustomizationSet(withGenerators(sourcev1.KustomizationSetGenerator{
+			GitRepository: &sourcev1.GitRepositoryGenerator{RepositoryRef: "demo-repo"},
+		}))))
+		ks.Spec.SyncPolicy = &sourcev1alpha2.SyncPolicy{
+			DeletionPolicy:  sourcev1.DeletionPolicyOrphan,
+			PreserveOnEmpty: true,
+		}
+		test.AssertNoError(t, k8sClient.Create(ctx, ks))
+		defer deleteKustomizationSet(t, k8sClient, ks)
+
+		stored := &sourcev1.KustomizationSet{}
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(ks), stored))
+		if ref := stored.Spec.Generators[1].GitRepository.RepositoryRef; ref != "demo-repo" {
+			t.Errorf("got repositoryRef %q, want %q", ref, "demo-repo")
+		}
+		if stored.Spec.DeletionPolicy != sourcev1.DeletionPolicyOrphan || !stored.Spec.SyncPolicy.PreserveOnEmpty {
+			t.Errorf("failed to convert the sync policy, got %#v and %#v", stored.Spec.DeletionPolicy, stored.Spec.SyncPolicy)
+		}
+
+		converted := &sourcev1alpha2.KustomizationSet{}
+		test.AssertNoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(ks), converted))
+		if ref := converted.Spec.Generators[1].GitRepository.SourceRef; ref.Kind != "GitRepository" || ref.Name != "demo-repo" {
+			t.Errorf("got sourceRef %#v", ref)
+		}
+	})
+}
+
+func waitForWebhookServer(t *testing.T, opts envtest.WebhookInstallOptions) {
+	t.Helper()
+	addr := net.JoinHostPort(opts.LocalServingHost, fmt.Sprint(opts.LocalServingPort))
+	err := wait.PollImmediate(100*time.Millisecond, 10*time.Second, func() (bool, error) {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second}, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			return false, nil
+		}
+		return true, conn.Close()
+	})
+	if err != nil {
+		t.Fatalf("webhook server at %s was not ready: %s", addr, err)
+	}
+}
+
+func deleteKustomizationSet(t *testing.T, cl client.Client, ks client.Object) {
+	t.Helper()
+	if err := cl.Delete(context.TODO(), ks); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,34 @@
+package test
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RecordSpans configures the global TracerProvider to record spans in memory
+// for the duration of the test, after the test spans are no longer recorded.
+func RecordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+
+	return exporter
+}
+
+// SpanNames returns the names of the spans recorded by the exporter, in the
+// order that they ended.
+func SpanNames(exporter *tracetest.InMemoryExporter) []string {
+	names := []string{}
+	for _, v := range exporter.GetSpans() {
+		names = append(names, v.Name)
+	}
+
+	return names
+}